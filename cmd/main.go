@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -16,10 +21,18 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"crud/pkg/automod"
+	"crud/pkg/blob"
+	"crud/pkg/category"
+	"crud/pkg/common"
 	"crud/pkg/logger"
 	"crud/pkg/middleware"
 	"crud/pkg/post"
+	"crud/pkg/report"
 	"crud/pkg/sessions"
+	"crud/pkg/spam"
+	"crud/pkg/unfurl"
+	"crud/pkg/upload"
 	"crud/pkg/user"
 	"crud/pkg/user/api"
 )
@@ -62,22 +75,73 @@ func main() {
 		log.Fatalln("main: unable to connect to MongoDB,", err)
 	}
 	defer func() {
-		if err := mongoClient.Disconnect(mongoCtx); err != nil {
-			log.Fatalln("main: failed disconnecting from MongoDB, ", err)
+		if err := mongoClient.Disconnect(context.Background()); err != nil {
+			log.Println("main: failed disconnecting from MongoDB,", err)
 		}
 	}()
 
+	// Done on SIGINT and SIGTERM, the background jobs stop and the server shuts down.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	postsDB := mongoClient.Database("crud").Collection("posts")
 	postsRepo := post.NewPostRepo(postsDB)
+	if err := postsRepo.EnsureIndexes(mongoCtx); err != nil {
+		log.Fatalln("main:", err)
+	}
+	viewsRepo := post.NewViewRepo(postsDB, mongoClient.Database("crud").Collection("post_views"))
+	if err := viewsRepo.EnsureIndexes(mongoCtx); err != nil {
+		log.Fatalln("main:", err)
+	}
 	usersRepo := user.NewUserRepo(db)
 	sessionManager := sessions.NewSessionManager(cfg["SECRET_KEY"], redisConn)
-	postHandler := post.NewPostHandler(postsRepo)
-	userHandler := api.NewUserHanler(usersRepo, sessionManager)
+	sanctionsRepo := user.NewSanctionRepo(db)
+	blocksRepo := user.NewBlockRepo(db)
+	followsRepo := user.NewFollowRepo(db)
+	subscriptionsRepo := user.NewSubscriptionRepo(db)
+	categoriesRepo := category.NewCategoryRepo(db)
+	feed := post.NewFeed(postsRepo, followsRepo, subscriptionsRepo, time.Minute)
+	moderation := category.NewModeration(categoriesRepo, category.NewBanRepo(db), category.NewModLogRepo(db))
+	reportsRepo := report.NewReportRepo(db)
+	automodRepo := automod.NewAutomodRepo(db)
+	spamClassifier := spamClassifier(ctx, cfg, db)
+	postCfg := postConfig(cfg)
+	// VIEW_WINDOW_MINUTES is how long the repeated views of the same viewer count once.
+	viewCounter := post.NewViewCounter(viewsRepo, time.Duration(envInt(cfg, "VIEW_WINDOW_MINUTES", 30))*time.Minute)
+	viewsFlushed := make(chan struct{})
+	go func() {
+		viewCounter.Run(ctx, 10*time.Second, 5*time.Second)
+		close(viewsFlushed)
+	}()
+	blobStore := blobStore(cfg)
+	uploadsRepo := upload.NewUploadRepo(db)
+	prefsRepo := user.NewPrefsRepo(db)
+	flairRepo := category.NewFlairRepo(db)
+	postHandler := post.NewPostHandler(postsRepo, sanctionsRepo, blocksRepo, categoriesRepo,
+		moderation, reportsRepo, automodRepo, spamClassifier, postsRepo, feed, viewCounter,
+		user.NewPostMarkRepo(db), uploadsRepo, prefsRepo, flairRepo, postCfg)
+	uploadHandler := upload.NewUploadHandler(uploadsRepo, blobStore, uploadConfig(cfg))
+	if postCfg.ArchiveAfter > 0 {
+		go post.NewArchiver(postsRepo, postCfg.ArchiveAfter, time.Hour).Run(ctx)
+	}
+	go post.NewPurger(postsRepo, purgeAfter(cfg, postCfg), time.Hour).Run(ctx)
+	go post.NewUnfurler(postsRepo, unfurl.NewFetcher(unfurl.DefaultConfig), 10*time.Second).Run(ctx)
+	go post.NewScheduler(postsRepo, 10*time.Second).Run(ctx)
+	automodHandler := automod.NewAutomodHandler(automodRepo, moderation)
+	categoryHandler := category.NewCategoryHandler(categoriesRepo, subscriptionsRepo, feed)
+	modHandler := category.NewModHandler(moderation)
+	flairHandler := category.NewFlairHandler(flairRepo, moderation)
+	invitesRepo := user.NewInviteRepo(db)
+	userHandler := api.NewUserHanler(usersRepo, sessionManager, invitesRepo, registrationPolicy(cfg))
+	adminHandler := api.NewAdminHandler(sanctionsRepo)
+	blockHandler := api.NewBlockHandler(blocksRepo)
+	followHandler := api.NewFollowHandler(followsRepo, subscriptionsRepo, feed)
+	prefsHandler := api.NewPrefsHandler(prefsRepo)
 
 	r := mux.NewRouter()
 
 	// Generate fake content to have better UI experience
-	// seed(usersRepo, postsRepo)
+	// seed(usersRepo, categoriesRepo, postsRepo)
 
 	api := r.PathPrefix("/api").Subrouter()
 
@@ -85,22 +149,93 @@ func main() {
 	api.HandleFunc("/posts/", postHandler.List).Methods("GET")
 	api.HandleFunc("/posts", postHandler.Add).Methods("POST")
 	api.HandleFunc("/post/{post_id}", postHandler.Get).Methods("GET")
+	api.HandleFunc("/post/{post_id}", postHandler.EditPost).Methods("PUT")
 	api.HandleFunc("/post/{post_id}", postHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/post/{post_id}/status", postHandler.SetStatus).Methods("PUT")
 	// GET был сделан автором оригинального фронта, я пока не добрался форкнуть и поправить.
 	api.HandleFunc("/post/{post_id}/upvote", postHandler.Upvote).Methods("GET")
 	api.HandleFunc("/post/{post_id}/downvote", postHandler.Downvote).Methods("GET")
 	api.HandleFunc("/post/{post_id}/unvote", postHandler.Unvote).Methods("GET")
+	api.HandleFunc("/post/{post_id}/views", postHandler.ViewStats).Methods("GET")
+	api.HandleFunc("/post/{post_id}/poll", postHandler.VotePoll).Methods("POST")
+	api.HandleFunc("/post/{post_id}/save", postHandler.SavePost).Methods("POST")
+	api.HandleFunc("/post/{post_id}/save", postHandler.UnsavePost).Methods("DELETE")
+	api.HandleFunc("/post/{post_id}/hide", postHandler.HidePost).Methods("POST")
+	api.HandleFunc("/post/{post_id}/hide", postHandler.UnhidePost).Methods("DELETE")
+	api.HandleFunc("/post/{post_id}/label/{label:nsfw|spoiler}", postHandler.LabelPost).Methods("POST")
+	api.HandleFunc("/post/{post_id}/label/{label:nsfw|spoiler}", postHandler.UnlabelPost).Methods("DELETE")
 	api.HandleFunc("/user/{username}", postHandler.GetByUser).Methods("GET")
 	api.HandleFunc("/posts/{category}", postHandler.GetCategory).Methods("GET")
+	api.HandleFunc("/feed", postHandler.GetFeed).Methods("GET")
+	api.HandleFunc("/search", postHandler.Search).Methods("GET")
+	api.HandleFunc("/domain/{domain}", postHandler.GetDomain).Methods("GET")
+	api.HandleFunc("/uploads", uploadHandler.Upload).Methods("POST")
+
+	// Categories
+	api.HandleFunc("/r", categoryHandler.List).Methods("GET")
+	api.HandleFunc("/r", categoryHandler.Add).Methods("POST")
+	api.HandleFunc("/r/{category}", categoryHandler.Get).Methods("GET")
+	api.HandleFunc("/r/{category}/subscribe", categoryHandler.Subscribe).Methods("POST")
+	api.HandleFunc("/r/{category}/subscribe", categoryHandler.Unsubscribe).Methods("DELETE")
+	api.HandleFunc("/r/{category}/moderators", categoryHandler.AddModerator).Methods("POST")
+	api.HandleFunc("/r/{category}/moderators/{user_id}", categoryHandler.RemoveModerator).Methods("DELETE")
+	api.HandleFunc("/r/{category}/flair", flairHandler.List).Methods("GET")
+	api.HandleFunc("/r/{category}/flair", flairHandler.Save).Methods("PUT")
+	api.HandleFunc("/r/{category}/tags", postHandler.PopularTags).Methods("GET")
+
+	// Moderation
+	api.HandleFunc("/r/{category}/modlog", modHandler.ModLog).Methods("GET")
+	api.HandleFunc("/r/{category}/bans", modHandler.ListBans).Methods("GET")
+	api.HandleFunc("/r/{category}/bans", modHandler.Ban).Methods("POST")
+	api.HandleFunc("/r/{category}/bans/{user_id}", modHandler.Unban).Methods("DELETE")
+	api.HandleFunc("/r/{category}/automod", automodHandler.GetRules).Methods("GET")
+	api.HandleFunc("/r/{category}/automod", automodHandler.SaveRules).Methods("PUT")
+	api.HandleFunc("/r/{category}/automod/log", automodHandler.GetLog).Methods("GET")
+	api.HandleFunc("/post/{post_id}/mod/{action}", postHandler.ModeratePost).Methods("POST")
+	api.HandleFunc("/post/{post_id}/{comment_id}/mod/{action}", postHandler.ModerateComment).Methods("POST")
+
+	// Reports
+	api.HandleFunc("/post/{post_id}/report", postHandler.ReportPost).Methods("POST")
+	api.HandleFunc("/post/{post_id}/{comment_id}/report", postHandler.ReportComment).Methods("POST")
+	api.HandleFunc("/r/{category}/reports", postHandler.ReportQueue).Methods("GET")
+	api.HandleFunc("/r/{category}/reports/{target_type:post|comment}/{target_id}", postHandler.ReportDetails).Methods("GET")
+	api.HandleFunc("/r/{category}/reports/{target_type:post|comment}/{target_id}/resolve", postHandler.ResolveReports).Methods("POST")
 
 	// Comments
 	api.HandleFunc("/post/{post_id}", postHandler.AddComment).Methods("POST")
+	api.HandleFunc("/post/{post_id}/{comment_id}", postHandler.EditComment).Methods("PUT")
 	api.HandleFunc("/post/{post_id}/{comment_id}", postHandler.DeleteComment).Methods("DELETE")
+	api.HandleFunc("/post/{post_id}/restore", postHandler.RestorePost).Methods("POST")
+	api.HandleFunc("/post/{post_id}/{comment_id}/restore", postHandler.RestoreComment).Methods("POST")
 
 	// User
 	api.HandleFunc("/register", userHandler.Register).Methods("POST")
 	api.HandleFunc("/login", userHandler.LogIn).Methods("POST")
 
+	// Blocks and mutes of the current user
+	api.HandleFunc("/me/blocks", blockHandler.List).Methods("GET")
+	api.HandleFunc("/me/blocks", blockHandler.Add).Methods("POST")
+	api.HandleFunc("/me/blocks/{user_id}", blockHandler.Delete).Methods("DELETE")
+
+	// Follows and category subscriptions of the current user
+	api.HandleFunc("/me/following", followHandler.ListFollowing).Methods("GET")
+	api.HandleFunc("/me/followers", followHandler.ListFollowers).Methods("GET")
+	api.HandleFunc("/me/following", followHandler.Follow).Methods("POST")
+	api.HandleFunc("/me/following/{user_id}", followHandler.Unfollow).Methods("DELETE")
+	api.HandleFunc("/me/subscriptions", followHandler.ListSubscriptions).Methods("GET")
+	api.HandleFunc("/me/saved", postHandler.ListSaved).Methods("GET")
+	api.HandleFunc("/me/drafts", postHandler.ListDrafts).Methods("GET")
+
+	// Content preferences of the current user
+	api.HandleFunc("/me/preferences", prefsHandler.Get).Methods("GET")
+	api.HandleFunc("/me/preferences", prefsHandler.Save).Methods("PUT")
+
+	// Admin
+	api.HandleFunc("/admin/invites", userHandler.CreateInvite).Methods("POST")
+	api.HandleFunc("/admin/invites", userHandler.ListInvites).Methods("GET")
+	api.HandleFunc("/admin/users/{user_id}/{action:suspend|ban|shadowban|lift}", adminHandler.Sanction).Methods("POST")
+	api.HandleFunc("/admin/users/{user_id}/sanctions", adminHandler.ListSanctions).Methods("GET")
+
 	auth := middleware.NewAuthMiddleware(sessionManager, usersRepo)
 	r.Use(auth.Middleware)
 
@@ -109,12 +244,27 @@ func main() {
 	r.Use(logMiddleware.SetupLogging)
 	r.Use(logMiddleware.AccessLog)
 
+	r.PathPrefix(upload.PublicPath).Handler(uploadsHandler{store: blobStore}).Methods("GET", "HEAD")
+
 	// Template path is relative to the project root for running with Makefile
 	spa := spaHandler{staticPath: "template", indexPath: "index.html"}
 	r.PathPrefix("/").Handler(spa)
 
+	server := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println("main: failed shutting down the server,", err)
+		}
+	}()
+
 	log.Println("Serving at http://localhost:8080/")
-	log.Fatalln(http.ListenAndServe(":8080", r))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalln(err)
+	}
+	<-viewsFlushed
 }
 
 func readDotenv() EnvConfig {
@@ -124,3 +274,116 @@ func readDotenv() EnvConfig {
 	}
 	return env
 }
+
+// Registration rules are configured with the optional env variables:
+// REGISTRATION_INVITE_ONLY, RESERVED_USERNAMES (comma separated)
+// and BREACHED_PASSWORDS_FILE (one password per line).
+func registrationPolicy(cfg EnvConfig) *user.Policy {
+	policy := user.DefaultPolicy()
+	policy.InviteOnly = cfg["REGISTRATION_INVITE_ONLY"] == "true"
+	if reserved := cfg["RESERVED_USERNAMES"]; reserved != "" {
+		policy.AddReservedNames(strings.Split(reserved, ",")...)
+	}
+	if path := cfg["BREACHED_PASSWORDS_FILE"]; path != "" {
+		if err := policy.LoadBreachedPasswords(path); err != nil {
+			log.Fatalln("main:", err)
+		}
+	}
+	return policy
+}
+
+// Optional env variables: REPORT_COLLAPSE_THRESHOLD, REPORT_HIDE_THRESHOLD
+// ARCHIVE_AFTER_DAYS and DUPLICATE_LINK_DAYS, 0 disables the feature.
+// RESTORE_WINDOW_DAYS is how long the deleted content can be restored.
+func postConfig(cfg EnvConfig) post.Config {
+	return post.Config{
+		ReportThresholds: report.Thresholds{
+			Collapse: envInt(cfg, "REPORT_COLLAPSE_THRESHOLD", 3),
+			Hide:     envInt(cfg, "REPORT_HIDE_THRESHOLD", 5),
+		},
+		ArchiveAfter:    time.Duration(envInt(cfg, "ARCHIVE_AFTER_DAYS", 180)) * 24 * time.Hour,
+		RestoreWindow:   time.Duration(envInt(cfg, "RESTORE_WINDOW_DAYS", 7)) * 24 * time.Hour,
+		DuplicateWindow: time.Duration(envInt(cfg, "DUPLICATE_LINK_DAYS", 30)) * 24 * time.Hour,
+	}
+}
+
+// PURGE_AFTER_DAYS is how long the deleted content is kept,
+// it's never shorter than the restore window.
+func purgeAfter(cfg EnvConfig, postCfg post.Config) time.Duration {
+	retention := time.Duration(envInt(cfg, "PURGE_AFTER_DAYS", 30)) * 24 * time.Hour
+	if retention < postCfg.RestoreWindow {
+		return postCfg.RestoreWindow
+	}
+	return retention
+}
+
+// SPAM_THRESHOLD is the spam score in percent from which the new content
+// is held for review, 0 disables the classifier. The model is reloaded
+// periodically to pick up the training done by other instances and the CLI.
+func spamClassifier(ctx context.Context, cfg EnvConfig, db *sql.DB) *spam.Classifier {
+	threshold := float64(envInt(cfg, "SPAM_THRESHOLD", 90)) / 100
+	classifier := spam.NewClassifier(spam.NewModelRepo(db), threshold)
+	if err := classifier.Reload(ctx); err != nil {
+		log.Fatalln("main: can't load spam model,", err)
+	}
+	go common.RunPeriodically(ctx, 10*time.Minute, func(ctx context.Context, _ time.Time) {
+		if err := classifier.Reload(ctx); err != nil && ctx.Err() == nil {
+			log.Println("main: can't reload spam model,", err)
+		}
+	})
+	return classifier
+}
+
+// BLOB_STORE selects where the uploads are kept: "local" (default) keeps them
+// in UPLOADS_DIR, "s3" in S3_BUCKET at S3_ENDPOINT, e.g. AWS or MinIO,
+// with S3_REGION, S3_ACCESS_KEY and S3_SECRET_KEY.
+func blobStore(cfg EnvConfig) blob.Store {
+	switch cfg["BLOB_STORE"] {
+	case "", "local":
+		dir := cfg["UPLOADS_DIR"]
+		if dir == "" {
+			dir = "uploads"
+		}
+		store, err := blob.NewLocalStore(dir)
+		if err != nil {
+			log.Fatalln("main:", err)
+		}
+		return store
+	case "s3":
+		store, err := blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg["S3_ENDPOINT"],
+			Bucket:    cfg["S3_BUCKET"],
+			Region:    cfg["S3_REGION"],
+			AccessKey: cfg["S3_ACCESS_KEY"],
+			SecretKey: cfg["S3_SECRET_KEY"],
+		}, nil)
+		if err != nil {
+			log.Fatalln("main:", err)
+		}
+		return store
+	default:
+		log.Fatalf("main: BLOB_STORE must be local or s3, got %q", cfg["BLOB_STORE"])
+		return nil
+	}
+}
+
+// UPLOAD_MAX_MB limits the size of the uploaded images,
+// UPLOAD_MAX_PROCESSING how many of them are decoded at the same time.
+func uploadConfig(cfg EnvConfig) upload.Config {
+	uploadCfg := upload.DefaultConfig
+	uploadCfg.MaxSize = int64(envInt(cfg, "UPLOAD_MAX_MB", 10)) << 20
+	uploadCfg.MaxProcessing = envInt(cfg, "UPLOAD_MAX_PROCESSING", upload.DefaultConfig.MaxProcessing)
+	return uploadCfg
+}
+
+func envInt(cfg EnvConfig, key string, def int) int {
+	v, ok := cfg[key]
+	if !ok || v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("main: %s must be a number, got %q", key, v)
+	}
+	return n
+}
@@ -0,0 +1,87 @@
+// Command migrate backfills the fields of the posts stored before they were recorded.
+// It is run once after deploying the version that introduces the field.
+//
+// Usage:
+//
+//	go run ./cmd/migrate domains
+//	go run ./cmd/migrate html
+//	go run ./cmd/migrate search
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"crud/pkg/post"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		usage()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := connect(ctx)
+	defer client.Disconnect(context.Background())
+	repo := post.NewPostRepo(client.Database("crud").Collection("posts"))
+
+	switch os.Args[1] {
+	case "domains":
+		updated, err := repo.BackfillDomains(ctx)
+		if err != nil {
+			log.Fatalln("migrate:", err)
+		}
+		fmt.Printf("set the domain of %d link posts\n", updated)
+	case "html":
+		updated, err := repo.BackfillHTML(ctx)
+		if err != nil {
+			log.Fatalln("migrate:", err)
+		}
+		fmt.Printf("rendered the html of %d posts\n", updated)
+	case "search":
+		updated, err := repo.BackfillSearchText(ctx)
+		if err != nil {
+			log.Fatalln("migrate:", err)
+		}
+		fmt.Printf("indexed the comments of %d posts\n", updated)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate domains | migrate html | migrate search")
+	os.Exit(2)
+}
+
+func connect(ctx context.Context) *mongo.Client {
+	cfg := readDotenv()
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(cfg["MONGODB_URI"]))
+	if err != nil {
+		log.Fatalln("migrate: can't connect to MongoDB,", err)
+	}
+	if err := client.Ping(connectCtx, nil); err != nil {
+		log.Fatalln("migrate: can't reach MongoDB,", err)
+	}
+	return client
+}
+
+func readDotenv() map[string]string {
+	env, err := godotenv.Read()
+	if err != nil {
+		log.Fatal("migrate: failed reading .env file:", err)
+	}
+	return env
+}
@@ -10,8 +10,10 @@ import (
 
 	"github.com/jaswdr/faker"
 
+	"crud/pkg/category"
 	"crud/pkg/comment"
 	. "crud/pkg/common"
+	"crud/pkg/markdown"
 	"crud/pkg/post"
 	"crud/pkg/user"
 )
@@ -31,6 +33,13 @@ type IUserRepo interface {
 	GetAll() ([]*user.User, error)
 }
 
+type ICategoryRepo interface {
+	Add(context.Context, *category.Category) error
+	Exists(context.Context, string) (bool, error)
+}
+
+var categories = []string{"programming", "music", "videos", "funny", "news", "fashion"}
+
 func createAuthors(userRepo IUserRepo) {
 	// User for experiments (not random)
 	_, err := userRepo.Add(&user.User{
@@ -45,7 +54,30 @@ func createAuthors(userRepo IUserRepo) {
 	}
 }
 
-func seed(userRepo IUserRepo, postRepo *post.Repo) {
+func createCategories(categoryRepo ICategoryRepo, creator *user.User) {
+	for _, slug := range categories {
+		exists, err := categoryRepo.Exists(context.Background(), slug)
+		if err != nil {
+			log.Fatalln("seed: can't check category:", err)
+		}
+		if exists {
+			continue
+		}
+		err = categoryRepo.Add(context.Background(), &category.Category{
+			Slug:        slug,
+			Title:       strings.Title(slug),
+			Description: f.Lorem().Sentence(8),
+			Rules:       []string{"Be nice", "Stay on topic"},
+			CreatorId:   creator.Id,
+			Created:     time.Now(),
+		})
+		if err != nil {
+			log.Fatalln("seed: can't add category:", err)
+		}
+	}
+}
+
+func seed(userRepo IUserRepo, categoryRepo ICategoryRepo, postRepo *post.Repo) {
 	authors, err := userRepo.GetAll()
 	if err != nil {
 		log.Fatalln("seed: can't get all authors:", err)
@@ -53,8 +85,13 @@ func seed(userRepo IUserRepo, postRepo *post.Repo) {
 
 	if len(authors) == 0 {
 		createAuthors(userRepo)
+		if authors, err = userRepo.GetAll(); err != nil {
+			log.Fatalln("seed: can't get all authors:", err)
+		}
 	}
 
+	createCategories(categoryRepo, authors[0])
+
 	for i := 0; i <= 5; i++ {
 		_, err := postRepo.Add(context.Background(), genPost(authors))
 		if err != nil {
@@ -64,7 +101,6 @@ func seed(userRepo IUserRepo, postRepo *post.Repo) {
 }
 
 func randCategory() string {
-	categories := []string{"programming", "music", "videos", "funny", "news", "fashion"}
 	n := rand.Int() % len(categories)
 	return categories[n]
 }
@@ -93,12 +129,13 @@ func genComments(users []*user.User) []*comment.Comment {
 	comments := []*comment.Comment{}
 	author := randUser(users)
 	for i := 0; i <= n; i++ {
-		comments = append(comments, &comment.Comment{
+		c := &comment.Comment{
 			Id:      comment.CommentId(RandStringRunes(12)),
 			Author:  author,
 			Created: f.Time().Time(time.Now()),
-			Body:    genText(),
-		})
+		}
+		c.SetBody(genText())
+		comments = append(comments, c)
 	}
 	return comments
 }
@@ -127,6 +164,7 @@ func genPost(users []*user.User) *post.Post {
 		Title:    genTitle(),
 		Type:     postType,
 		Text:     postText,
+		TextHTML: markdown.Render(postText),
 		URL:      postURL,
 		Category: randCategory(),
 		Views:    rand.Intn(100),
@@ -0,0 +1,98 @@
+// Command spam trains and evaluates the spam classifier on the content
+// the moderators removed as spam and approved.
+//
+// Usage:
+//
+//	go run ./cmd/spam train
+//	go run ./cmd/spam eval [-folds 5] [-threshold 0.9]
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"crud/pkg/post"
+	"crud/pkg/spam"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	switch cmd {
+	case "train":
+		flag.NewFlagSet("train", flag.ExitOnError).Parse(args)
+		samples := loadSamples()
+		model := spam.Train(samples)
+		db := openDB()
+		defer db.Close()
+		if err := spam.NewModelRepo(db).Save(context.Background(), model); err != nil {
+			log.Fatalln("spam:", err)
+		}
+		fmt.Printf("trained on %d samples\n", len(samples))
+	case "eval":
+		fs := flag.NewFlagSet("eval", flag.ExitOnError)
+		folds := fs.Int("folds", 5, "number of cross-validation folds")
+		threshold := fs.Float64("threshold", 0.9, "spam score from which the content is held")
+		fs.Parse(args)
+		samples := loadSamples()
+		fmt.Println(spam.CrossValidate(samples, *folds, *threshold))
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: spam train | spam eval [-folds 5] [-threshold 0.9]")
+	os.Exit(2)
+}
+
+func openDB() *sql.DB {
+	cfg := readDotenv()
+	db, err := sql.Open("pgx", "postgresql://localhost/"+cfg["POSTGRES_DB"]+"?sslmode=disable")
+	if err != nil {
+		log.Fatalln("spam: unable to connect to database,", err)
+	}
+	if err := db.Ping(); err != nil {
+		log.Fatalln("spam: unable to reach PostgreSQL,", err)
+	}
+	return db
+}
+
+// Loads the moderated posts and comments from MongoDB.
+func loadSamples() []spam.Sample {
+	cfg := readDotenv()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg["MONGODB_URI"]))
+	if err != nil {
+		log.Fatalln("spam: can't connect to MongoDB,", err)
+	}
+	defer client.Disconnect(ctx)
+
+	posts, err := post.NewPostRepo(client.Database("crud").Collection("posts")).GetModerated(ctx)
+	if err != nil {
+		log.Fatalln("spam:", err)
+	}
+	return post.SpamSamples(posts)
+}
+
+func readDotenv() map[string]string {
+	env, err := godotenv.Read()
+	if err != nil {
+		log.Fatal("spam: failed reading .env file:", err)
+	}
+	return env
+}
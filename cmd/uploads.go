@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"crud/pkg/blob"
+	"crud/pkg/upload"
+)
+
+// uploadsHandler serves the uploaded files from the blob store. Upload keys
+// are random and never rewritten, so the files are cached forever.
+type uploadsHandler struct {
+	store blob.Store
+}
+
+func (h uploadsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, upload.PublicPath)
+	body, info, err := h.store.Get(r.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) || errors.Is(err, blob.ErrBadKey) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Println("main: can't load upload,", err)
+		http.Error(w, "failed loading file", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	w.Header().Set("Content-Type", contentType)
+	if info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Println("main: failed writing upload,", err)
+	}
+}
@@ -0,0 +1,134 @@
+package automod
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+// Max size of the rules document.
+const maxRulesSize = 64 << 10
+
+type IAutomodRepo interface {
+	GetRuleset(context.Context, string) (*Ruleset, error)
+	SaveRuleset(ctx context.Context, category string, rs *Ruleset, updatedBy string) error
+	GetHits(context.Context, string, Page) ([]*Hit, error)
+}
+
+type IModeration interface {
+	CanModerate(ctx context.Context, category string, u *user.User) (bool, error)
+}
+
+type AutomodHandler struct {
+	Repo       IAutomodRepo
+	Moderation IModeration
+}
+
+func NewAutomodHandler(repo IAutomodRepo, moderation IModeration) *AutomodHandler {
+	return &AutomodHandler{
+		Repo:       repo,
+		Moderation: moderation,
+	}
+}
+
+func (ah AutomodHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug, ok := ah.moderator(w, r)
+	if !ok {
+		return
+	}
+
+	rs, err := ah.Repo.GetRuleset(r.Context(), slug)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load automod rules of %s: %v", slug, err)
+		WriteMsg(w, "failed loading rules", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, rs)
+}
+
+// Replaces the category rules. Accepts JSON or YAML (`Content-Type: application/yaml`).
+func (ah AutomodHandler) SaveRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug, ok := ah.moderator(w, r)
+	if !ok {
+		return
+	}
+	mod, _ := sessions.GetAuthUser(r.Context())
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRulesSize+1))
+	if err != nil || len(data) > maxRulesSize {
+		WriteMsg(w, "bad request body", http.StatusBadRequest)
+		return
+	}
+	isYAML := strings.Contains(r.Header.Get("Content-Type"), "yaml")
+	rs, err := Parse(data, isYAML)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.Repo.SaveRuleset(r.Context(), slug, rs, mod.Id); err != nil {
+		logger.Log(r.Context()).Errorf("can't save automod rules of %s: %v", slug, err)
+		WriteMsg(w, "failed saving rules", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, rs)
+}
+
+func (ah AutomodHandler) GetLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug, ok := ah.moderator(w, r)
+	if !ok {
+		return
+	}
+	page, err := ParsePage(r)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hits, err := ah.Repo.GetHits(r.Context(), slug, page)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load automod log of %s: %v", slug, err)
+		WriteMsg(w, "failed loading automod log", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, hits)
+}
+
+// Returns the category from the path if the authenticated user moderates it,
+// otherwise writes the error response.
+func (ah AutomodHandler) moderator(w http.ResponseWriter, r *http.Request) (string, bool) {
+	slug := mux.Vars(r)["category"]
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return "", false
+	}
+	isMod, err := ah.Moderation.CanModerate(r.Context(), slug, authUser)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check moderator of %s: %v", slug, err)
+		WriteMsg(w, "failed checking permissions", http.StatusInternalServerError)
+		return "", false
+	}
+	if !isMod {
+		WriteMsg(w, "only moderators of "+slug+" can do this", http.StatusForbidden)
+		return "", false
+	}
+	return slug, true
+}
@@ -0,0 +1,101 @@
+package automod
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"crud/pkg/common"
+)
+
+// Hit is the log record of the rule matched the content.
+type Hit struct {
+	Id         int64      `json:"id"`
+	Category   string     `json:"category"`
+	Rule       string     `json:"rule"`
+	Action     Action     `json:"action"`
+	TargetType TargetType `json:"targetType"`
+	TargetId   string     `json:"targetId"`
+	AuthorId   string     `json:"authorId"`
+	Created    time.Time  `json:"created"`
+}
+
+type Repo struct {
+	db *sql.DB
+}
+
+func NewAutomodRepo(db *sql.DB) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+// Returns the category rules, empty ruleset if there are none.
+func (r *Repo) GetRuleset(ctx context.Context, category string) (*Ruleset, error) {
+	var data []byte
+	row := r.db.QueryRowContext(ctx, "SELECT rules FROM automod_rules WHERE category=$1", category)
+	err := row.Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Ruleset{Rules: []*Rule{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("automod/repo: could not scan row: %w", err)
+	}
+	rs, err := Parse(data, false)
+	if err != nil {
+		return nil, fmt.Errorf("automod/repo: stored rules are broken: %w", err)
+	}
+	return rs, nil
+}
+
+func (r *Repo) SaveRuleset(ctx context.Context, category string, rs *Ruleset, updatedBy string) error {
+	data, err := json.Marshal(rs)
+	if err != nil {
+		return fmt.Errorf("automod/repo: failed encoding rules: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO automod_rules(category, rules, updated_by, updated) VALUES($1, $2, $3, NOW())
+		 ON CONFLICT (category) DO UPDATE SET rules=EXCLUDED.rules, updated_by=EXCLUDED.updated_by, updated=NOW()`,
+		category, data, updatedBy)
+	if err != nil {
+		return fmt.Errorf("automod/repo: failed saving rules: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) LogHit(ctx context.Context, h *Hit) error {
+	row := r.db.QueryRowContext(ctx,
+		`INSERT INTO automod_log(category, rule, action, target_type, target_id, author_id, created)
+		 VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		h.Category, h.Rule, h.Action, h.TargetType, h.TargetId, h.AuthorId, h.Created)
+	if err := row.Scan(&h.Id); err != nil {
+		return fmt.Errorf("automod/repo: failed logging hit: %w", err)
+	}
+	return nil
+}
+
+// Returns the rule hits in the category, newest first.
+func (r *Repo) GetHits(ctx context.Context, category string, page common.Page) ([]*Hit, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, category, rule, action, target_type, target_id, author_id, created FROM automod_log
+		 WHERE category=$1 ORDER BY created DESC, id DESC LIMIT $2 OFFSET $3`,
+		category, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("automod/repo: failed querying hits: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []*Hit{}
+	for rows.Next() {
+		h := new(Hit)
+		err := rows.Scan(&h.Id, &h.Category, &h.Rule, &h.Action, &h.TargetType, &h.TargetId, &h.AuthorId, &h.Created)
+		if err != nil {
+			return nil, fmt.Errorf("automod/repo: could not scan row: %w", err)
+		}
+		hits = append(hits, h)
+	}
+	return hits, nil
+}
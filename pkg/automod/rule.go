@@ -0,0 +1,221 @@
+package automod
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Action string
+
+const (
+	ActionRemove Action = "remove"
+	// Adds the content to the moderation queue.
+	ActionFlag Action = "flag"
+	// Hides the content until a moderator approves it.
+	ActionRequireApproval Action = "require_approval"
+	// Replies with the rule message.
+	ActionReply Action = "reply"
+)
+
+type TargetType string
+
+const (
+	TargetPost    TargetType = "post"
+	TargetComment TargetType = "comment"
+	TargetAny     TargetType = "any"
+)
+
+const maxRules = 50
+
+var ErrInvalid = errors.New("automod: invalid rules")
+
+// Rule matches the content when all of its set conditions match.
+type Rule struct {
+	Name   string     `json:"name" yaml:"name"`
+	Target TargetType `json:"target" yaml:"target"`
+
+	// Matches authors with accounts younger than N days.
+	AccountAgeDays int `json:"accountAgeDays,omitempty" yaml:"accountAgeDays,omitempty"`
+	// Matches post titles.
+	TitleRegex string `json:"titleRegex,omitempty" yaml:"titleRegex,omitempty"`
+	// Matches post texts and comment bodies.
+	BodyRegex string `json:"bodyRegex,omitempty" yaml:"bodyRegex,omitempty"`
+	// Matches links to the domains and their subdomains.
+	Domains []string `json:"domains,omitempty" yaml:"domains,omitempty"`
+	// Matches posts without text.
+	RequireText bool `json:"requireText,omitempty" yaml:"requireText,omitempty"`
+
+	Action Action `json:"action" yaml:"action"`
+	// Reply text for ActionReply, the reason for other actions.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+
+	titleRe *regexp.Regexp
+	bodyRe  *regexp.Regexp
+}
+
+// Ruleset is the list of the category rules.
+type Ruleset struct {
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Parses rules from JSON or YAML and validates them.
+func Parse(data []byte, isYAML bool) (*Ruleset, error) {
+	rs := new(Ruleset)
+	var err error
+	if isYAML {
+		err = yaml.Unmarshal(data, rs)
+	} else {
+		err = json.Unmarshal(data, rs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := rs.compile(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (rs *Ruleset) compile() error {
+	if len(rs.Rules) > maxRules {
+		return fmt.Errorf("%w: at most %d rules allowed", ErrInvalid, maxRules)
+	}
+	for i, rule := range rs.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if rule.Target == "" {
+			rule.Target = TargetAny
+		}
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalid, rule.Name, err)
+		}
+	}
+	return nil
+}
+
+func (rule *Rule) compile() error {
+	switch rule.Target {
+	case TargetPost, TargetComment, TargetAny:
+	default:
+		return fmt.Errorf("unknown target %q", rule.Target)
+	}
+	switch rule.Action {
+	case ActionRemove, ActionFlag, ActionRequireApproval:
+	case ActionReply:
+		if rule.Message == "" {
+			return errors.New("reply needs a message")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+
+	var err error
+	if rule.TitleRegex != "" {
+		if rule.titleRe, err = regexp.Compile(rule.TitleRegex); err != nil {
+			return fmt.Errorf("bad title regex: %v", err)
+		}
+	}
+	if rule.BodyRegex != "" {
+		if rule.bodyRe, err = regexp.Compile(rule.BodyRegex); err != nil {
+			return fmt.Errorf("bad body regex: %v", err)
+		}
+	}
+	for i, d := range rule.Domains {
+		rule.Domains[i] = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "www.")
+	}
+	if rule.AccountAgeDays < 0 {
+		return errors.New("account age can't be negative")
+	}
+	if !rule.hasConditions() {
+		return errors.New("rule has no conditions")
+	}
+	return nil
+}
+
+func (rule *Rule) hasConditions() bool {
+	return rule.AccountAgeDays > 0 || rule.TitleRegex != "" || rule.BodyRegex != "" ||
+		len(rule.Domains) > 0 || rule.RequireText
+}
+
+// Item is the content checked by the rules.
+type Item struct {
+	Target        TargetType
+	Title         string
+	Text          string
+	URL           string
+	AuthorCreated time.Time
+}
+
+// Returns the rules matching the item.
+func (rs *Ruleset) Evaluate(item *Item, now time.Time) []*Rule {
+	hits := []*Rule{}
+	for _, rule := range rs.Rules {
+		if rule.matches(item, now) {
+			hits = append(hits, rule)
+		}
+	}
+	return hits
+}
+
+func (rule *Rule) matches(item *Item, now time.Time) bool {
+	if rule.Target != TargetAny && rule.Target != item.Target {
+		return false
+	}
+	if rule.AccountAgeDays > 0 {
+		minCreated := now.AddDate(0, 0, -rule.AccountAgeDays)
+		if !item.AuthorCreated.After(minCreated) {
+			return false
+		}
+	}
+	if rule.titleRe != nil && (item.Target != TargetPost || !rule.titleRe.MatchString(item.Title)) {
+		return false
+	}
+	if rule.bodyRe != nil && !rule.bodyRe.MatchString(item.Text) {
+		return false
+	}
+	if len(rule.Domains) > 0 && !matchesDomain(rule.Domains, linkedHosts(item)) {
+		return false
+	}
+	if rule.RequireText && (item.Target != TargetPost || strings.TrimSpace(item.Text) != "") {
+		return false
+	}
+	return true
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+// Hosts of the item link and the links in its text.
+func linkedHosts(item *Item) []string {
+	links := urlPattern.FindAllString(item.Text, -1)
+	if item.URL != "" {
+		links = append(links, item.URL)
+	}
+	hosts := make([]string, 0, len(links))
+	for _, l := range links {
+		u, err := url.Parse(l)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+	}
+	return hosts
+}
+
+func matchesDomain(domains, hosts []string) bool {
+	for _, h := range hosts {
+		for _, d := range domains {
+			if h == d || strings.HasSuffix(h, "."+d) {
+				return true
+			}
+		}
+	}
+	return false
+}
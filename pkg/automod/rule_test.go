@@ -0,0 +1,76 @@
+package automod
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should parse YAML rules", func(t *testing.T) {
+		rs, err := Parse([]byte(`
+rules:
+  - name: no shorteners
+    domains: [bit.ly]
+    action: remove
+`), true)
+		assert.Nil(t, err)
+		assert.Len(t, rs.Rules, 1)
+		assert.Equal(t, TargetAny, rs.Rules[0].Target)
+	})
+
+	t.Run("should reject invalid rules", func(t *testing.T) {
+		for _, data := range []string{
+			`{"rules": [{"action": "remove"}]}`,
+			`{"rules": [{"bodyRegex": "(", "action": "remove"}]}`,
+			`{"rules": [{"requireText": true, "action": "explode"}]}`,
+			`{"rules": [{"requireText": true, "action": "reply"}]}`,
+			`{"rules": [{"requireText": true, "target": "user", "action": "flag"}]}`,
+		} {
+			_, err := Parse([]byte(data), false)
+			assert.ErrorIs(t, err, ErrInvalid, data)
+		}
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	rs, err := Parse([]byte(`{"rules": [
+		{"name": "new accounts", "accountAgeDays": 3, "action": "require_approval"},
+		{"name": "spam links", "domains": ["spam.com"], "action": "remove"},
+		{"name": "empty posts", "target": "post", "requireText": true, "action": "reply", "message": "add text"},
+		{"name": "shouting", "titleRegex": "^[A-Z !]+$", "action": "flag"}
+	]}`), false)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	names := func(rules []*Rule) []string {
+		res := []string{}
+		for _, r := range rules {
+			res = append(res, r.Name)
+		}
+		return res
+	}
+	old := now.AddDate(-1, 0, 0)
+
+	t.Run("should match new accounts", func(t *testing.T) {
+		item := &Item{Target: TargetComment, Text: "hi", AuthorCreated: now.Add(-time.Hour)}
+		assert.Equal(t, []string{"new accounts"}, names(rs.Evaluate(item, now)))
+	})
+
+	t.Run("should match linked subdomains", func(t *testing.T) {
+		item := &Item{Target: TargetComment, Text: "see https://www.ads.spam.com/x", AuthorCreated: old}
+		assert.Equal(t, []string{"spam links"}, names(rs.Evaluate(item, now)))
+	})
+
+	t.Run("should match post-only conditions on posts", func(t *testing.T) {
+		item := &Item{Target: TargetPost, Title: "BUY NOW", URL: "https://spam.com", AuthorCreated: old}
+		assert.Equal(t, []string{"spam links", "empty posts", "shouting"}, names(rs.Evaluate(item, now)))
+	})
+
+	t.Run("should not match clean content", func(t *testing.T) {
+		item := &Item{Target: TargetPost, Title: "Hello", Text: "https://notspam.com", AuthorCreated: old}
+		assert.Empty(t, rs.Evaluate(item, now))
+	})
+}
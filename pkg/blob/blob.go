@@ -0,0 +1,38 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+var (
+	ErrNotFound = errors.New("blob: not found")
+	ErrBadKey   = errors.New("blob: bad key")
+)
+
+// Store keeps the uploaded files by their keys.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// The caller closes the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Info, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type Info struct {
+	Size        int64
+	ContentType string
+}
+
+// Keys are slash separated names without dots at the segment starts,
+// so they are safe both as file paths and object names.
+var keyPattern = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*(/[a-zA-Z0-9_-][a-zA-Z0-9_.-]*)*$`)
+
+func checkKey(key string) error {
+	if len(key) > 512 || !keyPattern.MatchString(key) {
+		return fmt.Errorf("%w: %q", ErrBadKey, key)
+	}
+	return nil
+}
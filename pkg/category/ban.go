@@ -0,0 +1,87 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"crud/pkg/common"
+	"crud/pkg/user"
+)
+
+// Ban forbids the user to post, comment and vote in the category.
+type Ban struct {
+	Category string     `json:"category"`
+	UserId   string     `json:"userId"`
+	ActorId  string     `json:"actorId"`
+	Reason   string     `json:"reason"`
+	Until    *time.Time `json:"until,omitempty"`
+	Created  time.Time  `json:"created"`
+}
+
+type BanRepo struct {
+	db *sql.DB
+}
+
+func NewBanRepo(db *sql.DB) *BanRepo {
+	return &BanRepo{
+		db: db,
+	}
+}
+
+// Adds the ban or replaces the existing one.
+func (r *BanRepo) Add(ctx context.Context, b *Ban) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO category_bans(category, user_id, actor_id, reason, until, created) VALUES($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (category, user_id) DO UPDATE
+		 SET actor_id=EXCLUDED.actor_id, reason=EXCLUDED.reason, until=EXCLUDED.until, created=EXCLUDED.created`,
+		b.Category, b.UserId, b.ActorId, b.Reason, b.Until, b.Created)
+	if err != nil {
+		if common.IsForeignKeyViolation(err) {
+			return fmt.Errorf("%w: %s", user.ErrUserNotFound, b.UserId)
+		}
+		return fmt.Errorf("category/ban: failed adding ban: %w", err)
+	}
+	return nil
+}
+
+func (r *BanRepo) Delete(ctx context.Context, category, userId string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM category_bans WHERE category=$1 AND user_id=$2", category, userId)
+	if err != nil {
+		return fmt.Errorf("category/ban: failed deleting ban: %w", err)
+	}
+	return nil
+}
+
+func (r *BanRepo) GetAll(ctx context.Context, category string) ([]*Ban, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT category, user_id, actor_id, reason, until, created FROM category_bans
+		 WHERE category=$1 ORDER BY created DESC`, category)
+	if err != nil {
+		return nil, fmt.Errorf("category/ban: failed querying bans: %w", err)
+	}
+	defer rows.Close()
+
+	bans := []*Ban{}
+	for rows.Next() {
+		b := new(Ban)
+		if err := rows.Scan(&b.Category, &b.UserId, &b.ActorId, &b.Reason, &b.Until, &b.Created); err != nil {
+			return nil, fmt.Errorf("category/ban: could not scan row: %w", err)
+		}
+		bans = append(bans, b)
+	}
+	return bans, nil
+}
+
+// Reports if the user has an active ban in the category.
+func (r *BanRepo) IsBanned(ctx context.Context, category, userId string) (bool, error) {
+	var banned bool
+	row := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM category_bans
+		 WHERE category=$1 AND user_id=$2 AND (until IS NULL OR until > NOW()))`, category, userId)
+	if err := row.Scan(&banned); err != nil {
+		return false, fmt.Errorf("category/ban: could not scan row: %w", err)
+	}
+	return banned, nil
+}
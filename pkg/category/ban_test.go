@@ -0,0 +1,48 @@
+package category
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/common"
+	"crud/pkg/user"
+)
+
+func TestBanAdd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	repo := NewBanRepo(db)
+	b := &Ban{Category: "golang", UserId: "2", ActorId: "1", Reason: "spam", Created: time.Now()}
+
+	t.Run("should add the ban", func(t *testing.T) {
+		mock.
+			ExpectExec("INSERT INTO category_bans").
+			WithArgs(b.Category, b.UserId, b.ActorId, b.Reason, b.Until, b.Created).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, repo.Add(context.TODO(), b))
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+		}
+	})
+
+	t.Run("should return ErrUserNotFound for unknown user", func(t *testing.T) {
+		mock.
+			ExpectExec("INSERT INTO category_bans").
+			WithArgs(b.Category, b.UserId, b.ActorId, b.Reason, b.Until, b.Created).
+			WillReturnError(&pgconn.PgError{Code: common.PgForeignKeyViolation})
+
+		assert.ErrorIs(t, repo.Add(context.TODO(), b), user.ErrUserNotFound)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+		}
+	})
+}
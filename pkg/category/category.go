@@ -0,0 +1,48 @@
+package category
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"crud/pkg/user"
+)
+
+var (
+	ErrNotFound = errors.New("category: category not found")
+	ErrExists   = errors.New("category: category already exists")
+	ErrInvalid  = errors.New("category: invalid category")
+
+	slugPattern = regexp.MustCompile(`^[a-z0-9_]{2,32}$`)
+)
+
+type Category struct {
+	Slug        string       `json:"slug"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Rules       []string     `json:"rules"`
+	CreatorId   string       `json:"creatorId"`
+	Moderators  []*user.User `json:"moderators"`
+	Subscribers int          `json:"subscribers"`
+	Created     time.Time    `json:"created"`
+}
+
+func (c *Category) Validate() error {
+	c.Slug = strings.ToLower(strings.TrimSpace(c.Slug))
+	c.Title = strings.TrimSpace(c.Title)
+	if !slugPattern.MatchString(c.Slug) {
+		return fmt.Errorf("%w: slug must be 2 to 32 lowercase letters, digits or underscores", ErrInvalid)
+	}
+	if c.Title == "" || len(c.Title) > 128 {
+		return fmt.Errorf("%w: title must be 1 to 128 characters long", ErrInvalid)
+	}
+	if len(c.Description) > 2048 {
+		return fmt.Errorf("%w: description is too long", ErrInvalid)
+	}
+	if len(c.Rules) > 15 {
+		return fmt.Errorf("%w: too many rules", ErrInvalid)
+	}
+	return nil
+}
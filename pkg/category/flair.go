@@ -0,0 +1,167 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+)
+
+const (
+	maxFlair        = 32
+	maxFlairTextLen = 64
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Flair is the post label defined by the category moderators, the authors pick one for their posts.
+type Flair struct {
+	Text string `json:"text"`
+	// Optional `#rrggbb` background of the label.
+	Color string `json:"color,omitempty"`
+}
+
+// Checks the flair list of the category, the texts must be unique ignoring the case.
+func ValidateFlair(flair []*Flair) error {
+	if len(flair) > maxFlair {
+		return fmt.Errorf("%w: at most %d flair allowed", ErrInvalid, maxFlair)
+	}
+	seen := map[string]bool{}
+	for _, f := range flair {
+		if f == nil {
+			return fmt.Errorf("%w: empty flair", ErrInvalid)
+		}
+		f.Text = strings.TrimSpace(f.Text)
+		if f.Text == "" || len([]rune(f.Text)) > maxFlairTextLen {
+			return fmt.Errorf("%w: flair text must be 1 to %d characters long", ErrInvalid, maxFlairTextLen)
+		}
+		if f.Color != "" && !colorPattern.MatchString(f.Color) {
+			return fmt.Errorf("%w: flair color must be #rrggbb", ErrInvalid)
+		}
+		key := strings.ToLower(f.Text)
+		if seen[key] {
+			return fmt.Errorf("%w: flair %q is given more than once", ErrInvalid, f.Text)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Returns the flair with the text ignoring the case, nil if there is none.
+func FindFlair(flair []*Flair, text string) *Flair {
+	text = strings.TrimSpace(text)
+	for _, f := range flair {
+		if strings.EqualFold(f.Text, text) {
+			return f
+		}
+	}
+	return nil
+}
+
+type FlairRepo struct {
+	db *sql.DB
+}
+
+func NewFlairRepo(db *sql.DB) *FlairRepo {
+	return &FlairRepo{
+		db: db,
+	}
+}
+
+// Returns the flair of the category, empty if there is none.
+func (r *FlairRepo) GetFlair(ctx context.Context, slug string) ([]*Flair, error) {
+	var data []byte
+	row := r.db.QueryRowContext(ctx, "SELECT flair FROM category_flair WHERE category=$1", slug)
+	err := row.Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []*Flair{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("category/flair: could not scan row: %w", err)
+	}
+	flair := []*Flair{}
+	if err := json.Unmarshal(data, &flair); err != nil {
+		return nil, fmt.Errorf("category/flair: failed decoding flair: %w", err)
+	}
+	return flair, nil
+}
+
+func (r *FlairRepo) SaveFlair(ctx context.Context, slug string, flair []*Flair, updatedBy string) error {
+	data, err := json.Marshal(flair)
+	if err != nil {
+		return fmt.Errorf("category/flair: failed encoding flair: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO category_flair(category, flair, updated_by, updated) VALUES($1, $2, $3, NOW())
+		 ON CONFLICT (category) DO UPDATE SET flair=EXCLUDED.flair, updated_by=EXCLUDED.updated_by, updated=NOW()`,
+		slug, data, updatedBy)
+	if err != nil {
+		return fmt.Errorf("category/flair: failed saving flair: %w", err)
+	}
+	return nil
+}
+
+// FlairHandler serves the flair of the categories, edited by their moderators.
+type FlairHandler struct {
+	Repo       *FlairRepo
+	Moderation *Moderation
+}
+
+func NewFlairHandler(repo *FlairRepo, m *Moderation) *FlairHandler {
+	return &FlairHandler{
+		Repo:       repo,
+		Moderation: m,
+	}
+}
+
+func (fh FlairHandler) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug := mux.Vars(r)["category"]
+	flair, err := fh.Repo.GetFlair(r.Context(), slug)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load flair of %s: %v", slug, err)
+		WriteMsg(w, "failed loading flair", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, flair)
+}
+
+// Replaces the flair of the category, body: `[{"text": "Question", "color": "#0079d3"}]`.
+// The posts keep the flair they already have.
+func (fh FlairHandler) Save(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	mod, slug, ok := ModHandler{Moderation: fh.Moderation}.moderator(w, r)
+	if !ok {
+		return
+	}
+
+	flair := []*Flair{}
+	if err := ParseReqBody(r.Body, &flair); err != nil {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	if err := ValidateFlair(flair); err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.Repo.SaveFlair(r.Context(), slug, flair, mod.Id); err != nil {
+		logger.Log(r.Context()).Errorf("can't save flair of %s: %v", slug, err)
+		WriteMsg(w, "failed saving flair", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, flair)
+}
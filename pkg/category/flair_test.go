@@ -0,0 +1,64 @@
+package category
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFlair(t *testing.T) {
+	t.Run("should trim the texts", func(t *testing.T) {
+		flair := []*Flair{{Text: " Question "}, {Text: "News", Color: "#0079d3"}}
+		assert.Nil(t, ValidateFlair(flair))
+		assert.Equal(t, "Question", flair[0].Text)
+		assert.Equal(t, flair[1], FindFlair(flair, "news"))
+		assert.Nil(t, FindFlair(flair, "Meta"))
+	})
+
+	t.Run("should reject bad flair", func(t *testing.T) {
+		for _, flair := range [][]*Flair{
+			{{Text: ""}},
+			{{Text: "News", Color: "blue"}},
+			{{Text: "News"}, {Text: "news"}},
+			{nil},
+		} {
+			assert.ErrorIs(t, ValidateFlair(flair), ErrInvalid)
+		}
+	})
+}
+
+func TestGetFlair(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	repo := NewFlairRepo(db)
+
+	t.Run("should decode the flair", func(t *testing.T) {
+		mock.
+			ExpectQuery("SELECT flair FROM category_flair").
+			WithArgs("music").
+			WillReturnRows(sqlmock.NewRows([]string{"flair"}).AddRow([]byte(`[{"text":"Jazz","color":"#ff4500"}]`)))
+
+		flair, err := repo.GetFlair(context.TODO(), "music")
+		assert.Nil(t, err)
+		assert.Equal(t, []*Flair{{Text: "Jazz", Color: "#ff4500"}}, flair)
+	})
+
+	t.Run("should return no flair for the category without it", func(t *testing.T) {
+		mock.
+			ExpectQuery("SELECT flair FROM category_flair").
+			WithArgs("books").
+			WillReturnRows(sqlmock.NewRows([]string{"flair"}))
+
+		flair, err := repo.GetFlair(context.TODO(), "books")
+		assert.Nil(t, err)
+		assert.Equal(t, []*Flair{}, flair)
+	})
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations unfulfilled: %s", err)
+	}
+}
@@ -0,0 +1,232 @@
+package category
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+)
+
+type ICategoryRepo interface {
+	Add(context.Context, *Category) error
+	GetAll(context.Context) ([]*Category, error)
+	GetBySlug(context.Context, string) (*Category, error)
+	AddModerator(ctx context.Context, slug, userId string) error
+	RemoveModerator(ctx context.Context, slug, userId string) error
+}
+
+type ISubscriptionRepo interface {
+	Subscribe(ctx context.Context, userId, category string) error
+	Unsubscribe(ctx context.Context, userId, category string) error
+}
+
+// The cached home feeds built from the subscriptions.
+type IFeedCache interface {
+	Invalidate(userId string)
+}
+
+type CategoryHandler struct {
+	Repo          ICategoryRepo
+	Subscriptions ISubscriptionRepo
+	Feed          IFeedCache
+}
+
+func NewCategoryHandler(repo ICategoryRepo, subscriptions ISubscriptionRepo, feed IFeedCache) *CategoryHandler {
+	return &CategoryHandler{
+		Repo:          repo,
+		Subscriptions: subscriptions,
+		Feed:          feed,
+	}
+}
+
+func (ch CategoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	categories, err := ch.Repo.GetAll(r.Context())
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load categories: %v", err)
+		WriteMsg(w, "failed loading categories", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, categories)
+}
+
+func (ch CategoryHandler) Add(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	creator, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	if err := creator.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	c := new(Category)
+	if err := ParseReqBody(r.Body, c); err != nil {
+		logger.Log(r.Context()).Errorf("can't parse category from request body: %v", err)
+		WriteMsg(w, "can't parse category", http.StatusBadRequest)
+		return
+	}
+	if err := c.Validate(); err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if c.Rules == nil {
+		c.Rules = []string{}
+	}
+	c.CreatorId = creator.Id
+	c.Moderators = nil
+	c.Subscribers = 0
+	c.Created = time.Now()
+
+	err = ch.Repo.Add(r.Context(), c)
+	if errors.Is(err, ErrExists) {
+		WriteMsg(w, "category already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't add category: %v", err)
+		WriteMsg(w, "failed adding category", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	WriteRespJSON(w, c)
+}
+
+func (ch CategoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	c, ok := ch.category(w, r)
+	if !ok {
+		return
+	}
+
+	WriteRespJSON(w, c)
+}
+
+func (ch CategoryHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	ch.subscription(w, r, ch.Subscriptions.Subscribe)
+}
+
+func (ch CategoryHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	ch.subscription(w, r, ch.Subscriptions.Unsubscribe)
+}
+
+func (ch CategoryHandler) subscription(w http.ResponseWriter, r *http.Request,
+	change func(ctx context.Context, userId, category string) error,
+) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	c, ok := ch.category(w, r)
+	if !ok {
+		return
+	}
+
+	if err := change(r.Context(), authUser.Id, c.Slug); err != nil {
+		logger.Log(r.Context()).Errorf("can't change subscription to %s: %v", c.Slug, err)
+		WriteMsg(w, "failed changing subscription", http.StatusInternalServerError)
+		return
+	}
+	ch.Feed.Invalidate(authUser.Id)
+
+	WriteMsg(w, "success", http.StatusOK)
+}
+
+// Adds the moderator, body: `{"userId": "2"}`. Only for the creator and admins.
+func (ch CategoryHandler) AddModerator(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	c, ok := ch.ownedCategory(w, r)
+	if !ok {
+		return
+	}
+
+	mod := struct {
+		UserId string `json:"userId"`
+	}{}
+	if err := ParseReqBody(r.Body, &mod); err != nil || mod.UserId == "" {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+
+	if err := ch.Repo.AddModerator(r.Context(), c.Slug, mod.UserId); err != nil {
+		logger.Log(r.Context()).Errorf("can't add moderator to %s: %v", c.Slug, err)
+		WriteMsg(w, "failed adding moderator", http.StatusInternalServerError)
+		return
+	}
+
+	WriteMsg(w, "success", http.StatusCreated)
+}
+
+func (ch CategoryHandler) RemoveModerator(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	c, ok := ch.ownedCategory(w, r)
+	if !ok {
+		return
+	}
+
+	userId := mux.Vars(r)["user_id"]
+	if userId == c.CreatorId {
+		WriteMsg(w, "the creator can't be removed from moderators", http.StatusBadRequest)
+		return
+	}
+	if err := ch.Repo.RemoveModerator(r.Context(), c.Slug, userId); err != nil {
+		logger.Log(r.Context()).Errorf("can't remove moderator from %s: %v", c.Slug, err)
+		WriteMsg(w, "failed removing moderator", http.StatusInternalServerError)
+		return
+	}
+
+	WriteMsg(w, "success", http.StatusOK)
+}
+
+// Loads the category from the `category` path variable or writes the error response.
+func (ch CategoryHandler) category(w http.ResponseWriter, r *http.Request) (*Category, bool) {
+	slug := mux.Vars(r)["category"]
+	c, err := ch.Repo.GetBySlug(r.Context(), slug)
+	if errors.Is(err, ErrNotFound) {
+		WriteMsg(w, "category not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load category %s: %v", slug, err)
+		WriteMsg(w, "failed loading category", http.StatusInternalServerError)
+		return nil, false
+	}
+	return c, true
+}
+
+// Like category, but only the creator of the category or an admin gets it.
+func (ch CategoryHandler) ownedCategory(w http.ResponseWriter, r *http.Request) (*Category, bool) {
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	c, ok := ch.category(w, r)
+	if !ok {
+		return nil, false
+	}
+	if c.CreatorId != authUser.Id && !authUser.Admin {
+		WriteMsg(w, "only the creator can manage moderators", http.StatusForbidden)
+		return nil, false
+	}
+	return c, true
+}
@@ -0,0 +1,221 @@
+package category
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+// Moderation answers who moderates and who is banned in the categories
+// and records the moderator actions to the mod log.
+type Moderation struct {
+	Categories *Repo
+	Bans       *BanRepo
+	ModLog     *ModLogRepo
+}
+
+func NewModeration(categories *Repo, bans *BanRepo, modLog *ModLogRepo) *Moderation {
+	return &Moderation{
+		Categories: categories,
+		Bans:       bans,
+		ModLog:     modLog,
+	}
+}
+
+// Site admins can moderate every category.
+func (m *Moderation) CanModerate(ctx context.Context, slug string, u *user.User) (bool, error) {
+	if u == nil {
+		return false, nil
+	}
+	if u.Admin {
+		return true, nil
+	}
+	return m.Categories.IsModerator(ctx, slug, u.Id)
+}
+
+func (m *Moderation) IsBanned(ctx context.Context, slug, userId string) (bool, error) {
+	return m.Bans.IsBanned(ctx, slug, userId)
+}
+
+func (m *Moderation) Ban(ctx context.Context, b *Ban) error {
+	return m.Bans.Add(ctx, b)
+}
+
+func (m *Moderation) Record(ctx context.Context, a *ModAction) error {
+	if a.Created.IsZero() {
+		a.Created = time.Now()
+	}
+	return m.ModLog.Add(ctx, a)
+}
+
+// ModHandler serves the category bans and the mod log.
+type ModHandler struct {
+	Moderation *Moderation
+}
+
+func NewModHandler(m *Moderation) *ModHandler {
+	return &ModHandler{
+		Moderation: m,
+	}
+}
+
+// Bans the user in the category, body: `{"userId": "2", "reason": "spam", "until": "2030-01-01T00:00:00Z"}`.
+// Without `until` the ban is permanent.
+func (mh ModHandler) Ban(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	mod, slug, ok := mh.moderator(w, r)
+	if !ok {
+		return
+	}
+
+	ban := new(Ban)
+	if err := ParseReqBody(r.Body, ban); err != nil || ban.UserId == "" {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	if ban.Reason == "" {
+		WriteMsg(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	if ban.UserId == mod.Id {
+		WriteMsg(w, "can't ban yourself", http.StatusBadRequest)
+		return
+	}
+	ban.Category = slug
+	ban.ActorId = mod.Id
+	ban.Created = time.Now()
+	if ban.Until != nil && !ban.Until.After(ban.Created) {
+		WriteMsg(w, "ban must end in the future", http.StatusBadRequest)
+		return
+	}
+
+	if err := mh.Moderation.Bans.Add(r.Context(), ban); err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			WriteMsg(w, "user not found", http.StatusNotFound)
+			return
+		}
+		logger.Log(r.Context()).Errorf("can't ban user %s in %s: %v", ban.UserId, slug, err)
+		WriteMsg(w, "failed banning user", http.StatusInternalServerError)
+		return
+	}
+	mh.record(r, &ModAction{
+		Category:   slug,
+		ActorId:    mod.Id,
+		Action:     ActionBanUser,
+		TargetType: TargetUser,
+		TargetId:   ban.UserId,
+		Reason:     ban.Reason,
+	})
+
+	w.WriteHeader(http.StatusCreated)
+	WriteRespJSON(w, ban)
+}
+
+func (mh ModHandler) Unban(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	mod, slug, ok := mh.moderator(w, r)
+	if !ok {
+		return
+	}
+
+	userId := mux.Vars(r)["user_id"]
+	if err := mh.Moderation.Bans.Delete(r.Context(), slug, userId); err != nil {
+		logger.Log(r.Context()).Errorf("can't unban user %s in %s: %v", userId, slug, err)
+		WriteMsg(w, "failed unbanning user", http.StatusInternalServerError)
+		return
+	}
+	mh.record(r, &ModAction{
+		Category:   slug,
+		ActorId:    mod.Id,
+		Action:     ActionUnbanUser,
+		TargetType: TargetUser,
+		TargetId:   userId,
+		Reason:     r.URL.Query().Get("reason"),
+	})
+
+	WriteMsg(w, "success", http.StatusOK)
+}
+
+func (mh ModHandler) ListBans(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_, slug, ok := mh.moderator(w, r)
+	if !ok {
+		return
+	}
+
+	bans, err := mh.Moderation.Bans.GetAll(r.Context(), slug)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load bans of %s: %v", slug, err)
+		WriteMsg(w, "failed loading bans", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, bans)
+}
+
+// Public mod log of the category, filtered with `?actor=&action=&target=`.
+func (mh ModHandler) ModLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug := mux.Vars(r)["category"]
+	page, err := ParsePage(r)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	filter := ModLogFilter{
+		ActorId:  q.Get("actor"),
+		Action:   ModActionKind(q.Get("action")),
+		TargetId: q.Get("target"),
+	}
+
+	actions, err := mh.Moderation.ModLog.GetCategoryLog(r.Context(), slug, filter, page)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load mod log of %s: %v", slug, err)
+		WriteMsg(w, "failed loading mod log", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, actions)
+}
+
+// Returns the authenticated moderator of the category from the `category`
+// path variable, otherwise writes the error response.
+func (mh ModHandler) moderator(w http.ResponseWriter, r *http.Request) (*user.User, string, bool) {
+	slug := mux.Vars(r)["category"]
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return nil, "", false
+	}
+	isMod, err := mh.Moderation.CanModerate(r.Context(), slug, authUser)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check moderator of %s: %v", slug, err)
+		WriteMsg(w, "failed checking permissions", http.StatusInternalServerError)
+		return nil, "", false
+	}
+	if !isMod {
+		WriteMsg(w, fmt.Sprintf("only moderators of %s can do this", slug), http.StatusForbidden)
+		return nil, "", false
+	}
+	return authUser, slug, true
+}
+
+func (mh ModHandler) record(r *http.Request, a *ModAction) {
+	if err := mh.Moderation.Record(r.Context(), a); err != nil {
+		logger.Log(r.Context()).Errorf("can't record mod action %s: %v", a.Action, err)
+	}
+}
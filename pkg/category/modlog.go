@@ -0,0 +1,114 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"crud/pkg/common"
+)
+
+type ModActionKind string
+
+const (
+	ActionRemovePost     ModActionKind = "remove_post"
+	ActionApprovePost    ModActionKind = "approve_post"
+	ActionPinPost        ModActionKind = "pin_post"
+	ActionUnpinPost      ModActionKind = "unpin_post"
+	ActionLockPost       ModActionKind = "lock_post"
+	ActionUnlockPost     ModActionKind = "unlock_post"
+	ActionRemoveComment  ModActionKind = "remove_comment"
+	ActionApproveComment ModActionKind = "approve_comment"
+	ActionBanUser        ModActionKind = "ban_user"
+	ActionUnbanUser      ModActionKind = "unban_user"
+	ActionDismissReports ModActionKind = "dismiss_reports"
+	ActionLabelPost      ModActionKind = "label_post"
+	ActionUnlabelPost    ModActionKind = "unlabel_post"
+)
+
+type TargetType string
+
+const (
+	TargetPost    TargetType = "post"
+	TargetComment TargetType = "comment"
+	TargetUser    TargetType = "user"
+)
+
+// ModAction is a mod log record of the moderator action in the category.
+type ModAction struct {
+	Id         int64         `json:"id"`
+	Category   string        `json:"category"`
+	ActorId    string        `json:"actorId"`
+	Action     ModActionKind `json:"action"`
+	TargetType TargetType    `json:"targetType"`
+	TargetId   string        `json:"targetId"`
+	Reason     string        `json:"reason"`
+	Created    time.Time     `json:"created"`
+}
+
+// ModLogFilter narrows down the mod log, empty fields match everything.
+type ModLogFilter struct {
+	ActorId  string
+	Action   ModActionKind
+	TargetId string
+}
+
+type ModLogRepo struct {
+	db *sql.DB
+}
+
+func NewModLogRepo(db *sql.DB) *ModLogRepo {
+	return &ModLogRepo{
+		db: db,
+	}
+}
+
+func (r *ModLogRepo) Add(ctx context.Context, a *ModAction) error {
+	row := r.db.QueryRowContext(ctx,
+		`INSERT INTO modlog(category, actor_id, action, target_type, target_id, reason, created)
+		 VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		a.Category, a.ActorId, a.Action, a.TargetType, a.TargetId, a.Reason, a.Created)
+	if err := row.Scan(&a.Id); err != nil {
+		return fmt.Errorf("category/modlog: failed adding action: %w", err)
+	}
+	return nil
+}
+
+// Returns the mod log of the category, newest first.
+func (r *ModLogRepo) GetCategoryLog(ctx context.Context, category string, f ModLogFilter, page common.Page) ([]*ModAction, error) {
+	where := []string{"category=$1"}
+	args := []interface{}{category}
+	addCond := func(column string, value string) {
+		if value != "" {
+			args = append(args, value)
+			where = append(where, fmt.Sprintf("%s=$%d", column, len(args)))
+		}
+	}
+	addCond("actor_id", f.ActorId)
+	addCond("action", string(f.Action))
+	addCond("target_id", f.TargetId)
+	args = append(args, page.Limit, page.Offset)
+
+	query := fmt.Sprintf(
+		`SELECT id, category, actor_id, action, target_type, target_id, reason, created
+		 FROM modlog WHERE %s ORDER BY created DESC, id DESC LIMIT $%d OFFSET $%d`,
+		strings.Join(where, " AND "), len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("category/modlog: failed querying mod log: %w", err)
+	}
+	defer rows.Close()
+
+	actions := []*ModAction{}
+	for rows.Next() {
+		a := new(ModAction)
+		err := rows.Scan(&a.Id, &a.Category, &a.ActorId, &a.Action, &a.TargetType, &a.TargetId, &a.Reason, &a.Created)
+		if err != nil {
+			return nil, fmt.Errorf("category/modlog: could not scan row: %w", err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
@@ -0,0 +1,46 @@
+package category
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/common"
+)
+
+func TestGetCategoryLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	repo := NewModLogRepo(db)
+	columns := []string{"id", "category", "actor_id", "action", "target_type", "target_id", "reason", "created"}
+
+	t.Run("should filter by the given fields only", func(t *testing.T) {
+		created := time.Now()
+		mock.
+			ExpectQuery(`FROM modlog WHERE category=\$1 AND action=\$2 ORDER BY (.+) LIMIT \$3 OFFSET \$4`).
+			WithArgs("music", string(ActionRemovePost), 10, 20).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, "music", "1", ActionRemovePost, TargetPost, "abc", "spam", created))
+
+		page := common.Page{Limit: 10, Offset: 20}
+		actions, err := repo.GetCategoryLog(context.TODO(), "music", ModLogFilter{Action: ActionRemovePost}, page)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+		assert.Equal(t, []*ModAction{{
+			Id: 1, Category: "music", ActorId: "1", Action: ActionRemovePost,
+			TargetType: TargetPost, TargetId: "abc", Reason: "spam", Created: created,
+		}}, actions)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"crud/pkg/common"
+	"crud/pkg/user"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func NewCategoryRepo(db *sql.DB) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+// Adds the category, its creator becomes the first moderator.
+func (r *Repo) Add(ctx context.Context, c *Category) error {
+	rules, err := json.Marshal(c.Rules)
+	if err != nil {
+		return fmt.Errorf("category/repo: failed encoding rules: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("category/repo: failed starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO categories(slug, title, description, rules, creator_id, created) VALUES($1, $2, $3, $4, $5, $6)",
+		c.Slug, c.Title, c.Description, rules, c.CreatorId, c.Created)
+	if err != nil {
+		if common.IsUniqueViolation(err) {
+			return ErrExists
+		}
+		return fmt.Errorf("category/repo: failed inserting category: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO category_moderators(slug, user_id) VALUES($1, $2)", c.Slug, c.CreatorId)
+	if err != nil {
+		return fmt.Errorf("category/repo: failed adding moderator: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("category/repo: failed commiting category: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) GetAll(ctx context.Context) ([]*Category, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.slug, c.title, c.description, c.rules, c.creator_id, c.created,
+			(SELECT COUNT(*) FROM subscriptions s WHERE s.category = c.slug)
+		FROM categories c ORDER BY c.slug`)
+	if err != nil {
+		return nil, fmt.Errorf("category/repo: failed querying categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []*Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// Returns the category with its moderators.
+func (r *Repo) GetBySlug(ctx context.Context, slug string) (*Category, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT c.slug, c.title, c.description, c.rules, c.creator_id, c.created,
+			(SELECT COUNT(*) FROM subscriptions s WHERE s.category = c.slug)
+		FROM categories c WHERE c.slug=$1`, slug)
+	c, err := scanCategory(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT u.id, u.username FROM category_moderators m JOIN users u ON u.id = m.user_id WHERE m.slug=$1 ORDER BY u.username",
+		slug)
+	if err != nil {
+		return nil, fmt.Errorf("category/repo: failed querying moderators: %w", err)
+	}
+	defer rows.Close()
+
+	c.Moderators = []*user.User{}
+	for rows.Next() {
+		u := new(user.User)
+		if err := rows.Scan(&u.Id, &u.Username); err != nil {
+			return nil, fmt.Errorf("category/repo: could not scan row: %w", err)
+		}
+		c.Moderators = append(c.Moderators, u)
+	}
+	return c, nil
+}
+
+func (r *Repo) Exists(ctx context.Context, slug string) (bool, error) {
+	var exists bool
+	row := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE slug=$1)", slug)
+	if err := row.Scan(&exists); err != nil {
+		return false, fmt.Errorf("category/repo: could not scan row: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *Repo) IsModerator(ctx context.Context, slug, userId string) (bool, error) {
+	var exists bool
+	row := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM category_moderators WHERE slug=$1 AND user_id=$2)", slug, userId)
+	if err := row.Scan(&exists); err != nil {
+		return false, fmt.Errorf("category/repo: could not scan row: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *Repo) AddModerator(ctx context.Context, slug, userId string) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO category_moderators(slug, user_id) VALUES($1, $2) ON CONFLICT DO NOTHING", slug, userId)
+	if err != nil {
+		return fmt.Errorf("category/repo: failed adding moderator: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) RemoveModerator(ctx context.Context, slug, userId string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM category_moderators WHERE slug=$1 AND user_id=$2", slug, userId)
+	if err != nil {
+		return fmt.Errorf("category/repo: failed removing moderator: %w", err)
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+func scanCategory(row scanner) (*Category, error) {
+	c := new(Category)
+	var rules []byte
+	err := row.Scan(&c.Slug, &c.Title, &c.Description, &rules, &c.CreatorId, &c.Created, &c.Subscribers)
+	if err != nil {
+		return nil, fmt.Errorf("category/repo: could not scan row: %w", err)
+	}
+	if err := json.Unmarshal(rules, &c.Rules); err != nil {
+		return nil, fmt.Errorf("category/repo: failed decoding rules: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,100 @@
+package category
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/common"
+)
+
+func TestCategoryAdd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+	c := &Category{Slug: "music", Title: "Music", Rules: []string{"Be nice"}, CreatorId: "1", Created: time.Now()}
+
+	t.Run("should add category with the creator as moderator", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("INSERT INTO categories").
+			WithArgs(c.Slug, c.Title, c.Description, []byte(`["Be nice"]`), c.CreatorId, c.Created).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectExec("INSERT INTO category_moderators").
+			WithArgs(c.Slug, c.CreatorId).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, repo.Add(context.TODO(), c))
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+
+	t.Run("should return ErrExists on unique violation", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("INSERT INTO categories").
+			WillReturnError(&pgconn.PgError{Code: common.PgUniqueViolation})
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.Add(context.TODO(), c), ErrExists)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+}
+
+func TestCategoryGetBySlug(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	repo := NewCategoryRepo(db)
+	columns := []string{"slug", "title", "description", "rules", "creator_id", "created", "count"}
+
+	t.Run("should return category with moderators", func(t *testing.T) {
+		created := time.Now()
+		mock.
+			ExpectQuery("SELECT (.+) FROM categories c WHERE c.slug").
+			WithArgs("music").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("music", "Music", "", []byte(`[]`), "1", created, 3))
+		mock.
+			ExpectQuery("SELECT u.id, u.username FROM category_moderators").
+			WithArgs("music").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow("1", "pike"))
+
+		c, err := repo.GetBySlug(context.TODO(), "music")
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+		assert.Equal(t, 3, c.Subscribers)
+		assert.Equal(t, "pike", c.Moderators[0].Username)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+
+	t.Run("should return ErrNotFound", func(t *testing.T) {
+		mock.
+			ExpectQuery("SELECT (.+) FROM categories c WHERE c.slug").
+			WithArgs("nope").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.GetBySlug(context.TODO(), "nope")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -3,6 +3,7 @@ package comment
 import (
 	"time"
 
+	"crud/pkg/markdown"
 	"crud/pkg/user"
 )
 
@@ -12,5 +13,27 @@ type Comment struct {
 	Id      CommentId  `json:"id"`
 	Author  *user.User `json:"author"`
 	Created time.Time  `json:"created"`
-	Body    string     `json:"body"`
+	// Markdown source and its sanitized HTML, see SetBody.
+	Body     string     `json:"body"`
+	BodyHTML string     `json:"bodyHtml"`
+	Edited   *time.Time `json:"edited,omitempty"`
+	Removed  bool       `json:"removed"`
+	// Set when a moderator approves the comment.
+	Approved bool `json:"approved"`
+	// Set when the comment gets too many reports.
+	Collapsed bool `json:"collapsed"`
+	Hidden    bool `json:"hidden"`
+	// Held until a moderator approves it.
+	Pending bool `json:"pending"`
+	// The label the spam classifier learned the comment with.
+	SpamLabel string `json:"-"`
+
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
+}
+
+// Sets the body and renders it, the only way the body should change.
+func (c *Comment) SetBody(body string) {
+	c.Body = body
+	c.BodyHTML = markdown.Render(body)
 }
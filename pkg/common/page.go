@@ -0,0 +1,51 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultPageLimit = 25
+	MaxPageLimit     = 100
+)
+
+// Page is the pagination window from `?limit=&offset=` query params.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+func ParsePage(r *http.Request) (Page, error) {
+	page := Page{Limit: DefaultPageLimit}
+	q := r.URL.Query()
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return page, fmt.Errorf("limit must be a number from 1 to %d", MaxPageLimit)
+		}
+		page.Limit = limit
+	}
+	if o := q.Get("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			return page, fmt.Errorf("offset must be a non-negative number")
+		}
+		page.Offset = offset
+	}
+	return page, nil
+}
+
+// Returns the bounds of the page within the slice of length n.
+func (p Page) Bounds(n int) (int, int) {
+	start := p.Offset
+	if start > n {
+		start = n
+	}
+	end := start + p.Limit
+	if end > n {
+		end = n
+	}
+	return start, end
+}
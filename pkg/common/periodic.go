@@ -0,0 +1,21 @@
+package common
+
+import (
+	"context"
+	"time"
+)
+
+// Runs the job right away and then every period until the context is done,
+// the job gets the current time. The background workers run this way.
+func RunPeriodically(ctx context.Context, every time.Duration, job func(context.Context, time.Time)) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		job(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
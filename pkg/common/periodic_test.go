@@ -0,0 +1,45 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPeriodically(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan time.Time, 10)
+	done := make(chan struct{})
+	go func() {
+		RunPeriodically(ctx, time.Millisecond, func(_ context.Context, now time.Time) {
+			select {
+			case runs <- now:
+			default:
+			}
+		})
+		close(done)
+	}()
+
+	first := <-runs
+	second := <-runs
+	assert.True(t, second.After(first))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the job is still running after the context is done")
+	}
+}
+
+func TestRunPeriodicallyStartsRightAway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runs := 0
+	RunPeriodically(ctx, time.Hour, func(context.Context, time.Time) {
+		runs++
+	})
+	assert.Equal(t, 1, runs)
+}
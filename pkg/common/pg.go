@@ -0,0 +1,25 @@
+package common
+
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+)
+
+// Postgres error code for unique constraint violations.
+const PgUniqueViolation = "23505"
+
+// Reports whether the Postgres query failed on a unique constraint.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == PgUniqueViolation
+}
+
+// Postgres error code for foreign key violations, e.g. a reference to a missing user.
+const PgForeignKeyViolation = "23503"
+
+// Reports whether the Postgres query failed on a foreign key constraint.
+func IsForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == PgForeignKeyViolation
+}
@@ -0,0 +1,399 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Nesting of the blockquotes and the inline spans deeper than this is rendered as text.
+const (
+	maxQuoteDepth  = 5
+	maxInlineDepth = 10
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	rulePattern    = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fencePattern   = regexp.MustCompile("^ {0,3}(```|~~~)[ \t]*([^`]*)$")
+	itemPattern    = regexp.MustCompile(`^ {0,3}([-*+]|(\d{1,9})[.)])[ \t]+(.*)$`)
+	quotePattern   = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	langPattern    = regexp.MustCompile(`^[a-zA-Z0-9_+-]{1,20}$`)
+)
+
+// Render converts the Markdown source to HTML. The supported subset is
+// paragraphs, headings, rules, fenced code, quotes, flat lists, emphasis,
+// strikethrough, code spans and links. Raw HTML is shown as text and
+// the output goes through Sanitize, so only the allowlisted markup remains.
+func Render(src string) string {
+	src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\r", "\n")
+	var out strings.Builder
+	renderBlocks(&out, strings.Split(src, "\n"), 0)
+	return Sanitize(out.String())
+}
+
+func renderBlocks(out *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			i++
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			i = renderFence(out, lines, i, m[1], strings.TrimSpace(m[2]))
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			fmt.Fprintf(out, "<h%d>%s</h%d>\n", len(m[1]), renderInline(m[2], false, 0), len(m[1]))
+			i++
+			continue
+		}
+		if rulePattern.MatchString(line) {
+			out.WriteString("<hr>\n")
+			i++
+			continue
+		}
+		if quotePattern.MatchString(line) {
+			quoted := []string{}
+			for ; i < len(lines); i++ {
+				m := quotePattern.FindStringSubmatch(lines[i])
+				if m == nil {
+					break
+				}
+				quoted = append(quoted, m[1])
+			}
+			out.WriteString("<blockquote>\n")
+			if depth < maxQuoteDepth {
+				renderBlocks(out, quoted, depth+1)
+			} else {
+				renderParagraph(out, quoted)
+			}
+			out.WriteString("</blockquote>\n")
+			continue
+		}
+		if itemPattern.MatchString(line) {
+			i = renderList(out, lines, i)
+			continue
+		}
+
+		start := i
+		for i++; i < len(lines) && !startsBlock(lines[i]); i++ {
+		}
+		renderParagraph(out, lines[start:i])
+	}
+}
+
+// Blank lines and the lines starting other blocks end the paragraph.
+func startsBlock(line string) bool {
+	return strings.TrimSpace(line) == "" || fencePattern.MatchString(line) || headingPattern.MatchString(line) ||
+		rulePattern.MatchString(line) || quotePattern.MatchString(line) || itemPattern.MatchString(line)
+}
+
+func renderParagraph(out *strings.Builder, lines []string) {
+	for i, l := range lines {
+		lines[i] = strings.TrimLeft(l, " \t")
+	}
+	out.WriteString("<p>" + renderInline(strings.Join(lines, "\n"), false, 0) + "</p>\n")
+}
+
+// Renders the code until the closing fence or the end of the source, returns the next line.
+func renderFence(out *strings.Builder, lines []string, i int, fence, lang string) int {
+	if fields := strings.Fields(lang); len(fields) > 0 && langPattern.MatchString(fields[0]) {
+		out.WriteString(`<pre><code class="language-` + fields[0] + `">`)
+	} else {
+		out.WriteString("<pre><code>")
+	}
+	for i++; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) && strings.Trim(strings.TrimSpace(lines[i]), fence[:1]) == "" {
+			i++
+			break
+		}
+		out.WriteString(html.EscapeString(lines[i]) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+	return i
+}
+
+// Renders the consecutive items of the same list kind, returns the next line.
+// Indented lines continue the item, a blank line ends the list
+// unless the next item follows it.
+func renderList(out *strings.Builder, lines []string, i int) int {
+	first := itemPattern.FindStringSubmatch(lines[i])
+	ordered := first[2] != ""
+	marker := first[1][len(first[1])-1:]
+	if ordered {
+		if n, _ := strconv.Atoi(first[2]); n != 1 {
+			fmt.Fprintf(out, "<ol start=\"%d\">\n", n)
+		} else {
+			out.WriteString("<ol>\n")
+		}
+	} else {
+		out.WriteString("<ul>\n")
+	}
+
+	items := [][]string{}
+	for i < len(lines) {
+		m := itemPattern.FindStringSubmatch(lines[i])
+		if m != nil {
+			if (m[2] != "") != ordered || m[1][len(m[1])-1:] != marker {
+				break
+			}
+			items = append(items, []string{m[3]})
+			i++
+			continue
+		}
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			if i+1 < len(lines) && itemPattern.MatchString(lines[i+1]) {
+				i++
+				continue
+			}
+			break
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		last := len(items) - 1
+		items[last] = append(items[last], strings.TrimSpace(line))
+		i++
+	}
+	for _, item := range items {
+		out.WriteString("<li>" + renderInline(strings.Join(item, "\n"), false, 0) + "</li>\n")
+	}
+	if ordered {
+		out.WriteString("</ol>\n")
+	} else {
+		out.WriteString("</ul>\n")
+	}
+	return i
+}
+
+var (
+	autolinkPattern = regexp.MustCompile(`^<(https?://[^\s<>]+)>`)
+	bareURLPattern  = regexp.MustCompile(`^https?://[^\s<>]+`)
+)
+
+// Renders the spans of the text. Links aren't nested into other links.
+func renderInline(s string, inLink bool, depth int) string {
+	if depth > maxInlineDepth {
+		return html.EscapeString(s)
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			out.WriteString("<br>\n")
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			out.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == ' ' && strings.HasPrefix(s[i:], "  \n"):
+			out.WriteString("<br>\n")
+			i += 3
+			continue
+		case c == '`':
+			if code, n := codeSpan(s[i:]); n > 0 {
+				out.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n
+				continue
+			}
+		case (c == '[' || (c == '!' && i+1 < len(s) && s[i+1] == '[')) && !inLink:
+			if text, href, n := link(s[i:]); n > 0 {
+				// Images are linked instead of embedded, so they can't track the readers.
+				if c == '!' && text == "" {
+					text = href
+				}
+				out.WriteString(anchor(href, renderInline(text, true, depth+1)))
+				i += n
+				continue
+			}
+		case c == '<' && !inLink:
+			if m := autolinkPattern.FindStringSubmatch(s[i:]); m != nil && safeURL(m[1]) {
+				out.WriteString(anchor(m[1], html.EscapeString(m[1])))
+				i += len(m[0])
+				continue
+			}
+		case c == 'h' && !inLink && (i == 0 || !isWordByte(s[i-1])):
+			if m := bareURLPattern.FindString(s[i:]); m != "" {
+				u := trimURLPunct(m)
+				if safeURL(u) {
+					out.WriteString(anchor(u, html.EscapeString(u)))
+					i += len(u)
+					continue
+				}
+			}
+		case c == '~' && strings.HasPrefix(s[i:], "~~"):
+			if inner, n := delimited(s, i, "~~"); n > 0 {
+				out.WriteString("<del>" + renderInline(inner, inLink, depth+1) + "</del>")
+				i += n
+				continue
+			}
+		case c == '*' || c == '_':
+			if strings.HasPrefix(s[i:], strings.Repeat(string(c), 2)) {
+				if inner, n := delimited(s, i, strings.Repeat(string(c), 2)); n > 0 {
+					out.WriteString("<strong>" + renderInline(inner, inLink, depth+1) + "</strong>")
+					i += n
+					continue
+				}
+			}
+			if inner, n := delimited(s, i, string(c)); n > 0 {
+				out.WriteString("<em>" + renderInline(inner, inLink, depth+1) + "</em>")
+				i += n
+				continue
+			}
+		}
+		out.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// Returns the code of the span starting the text and the span length, 0 if it's not closed.
+func codeSpan(s string) (string, int) {
+	run := leading(s, '`')
+	for j := run; j < len(s); {
+		k := strings.IndexByte(s[j:], '`')
+		if k < 0 {
+			return "", 0
+		}
+		k += j
+		// The closing run must be exactly as long as the opening one.
+		n := leading(s[k:], '`')
+		if n != run {
+			j = k + n
+			continue
+		}
+		code := strings.ReplaceAll(s[run:k], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+			code = code[1 : len(code)-1]
+		}
+		return code, k + n
+	}
+	return "", 0
+}
+
+func leading(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// Finds the span s[i:] opened by the delimiter, returns its content and length.
+// The content can't start or end with a space and "_" doesn't work inside words.
+func delimited(s string, i int, delim string) (string, int) {
+	start := i + len(delim)
+	if start >= len(s) || isSpace(s[start]) {
+		return "", 0
+	}
+	if delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0
+	}
+	for j := start + 1; j <= len(s)-len(delim); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == '`' {
+			if _, n := codeSpan(s[j:]); n > 0 {
+				j += n - 1
+				continue
+			}
+		}
+		// Single delimiters don't close on the doubled ones, e.g. "*a **b** c*".
+		if len(delim) == 1 && s[j] == delim[0] {
+			if n := leading(s[j:], delim[0]); n > 1 {
+				j += n - 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(s[j:], delim) || isSpace(s[j-1]) {
+			continue
+		}
+		end := j + len(delim)
+		if delim[0] == '_' && end < len(s) && isWordByte(s[end]) {
+			continue
+		}
+		return s[start:j], end - i
+	}
+	return "", 0
+}
+
+// Parses `[text](url)` or `![alt](url)` starting the text, the title is ignored.
+func link(s string) (string, string, int) {
+	open := 1
+	if s[0] == '!' {
+		open = 2
+	}
+	level := 0
+	closing := -1
+	for j := open; j < len(s) && closing < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			level++
+		case ']':
+			if level == 0 {
+				closing = j
+			}
+			level--
+		}
+	}
+	if closing < 0 || closing+1 >= len(s) || s[closing+1] != '(' {
+		return "", "", 0
+	}
+	end := strings.IndexByte(s[closing+2:], ')')
+	if end < 0 {
+		return "", "", 0
+	}
+	end += closing + 2
+	dest := strings.TrimSpace(s[closing+2 : end])
+	if sp := strings.IndexAny(dest, " \t\n"); sp >= 0 {
+		dest = dest[:sp]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	if !safeURL(dest) {
+		return "", "", 0
+	}
+	return s[open:closing], dest, end + 1
+}
+
+func anchor(href, content string) string {
+	return `<a href="` + html.EscapeString(href) + `" rel="nofollow ugc noopener noreferrer">` + content + "</a>"
+}
+
+// Drops the trailing punctuation and the unbalanced closing parens of the bare URL.
+func trimURLPunct(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		if strings.IndexByte(".,:;!?'\"*_~", last) >= 0 {
+			u = u[:len(u)-1]
+			continue
+		}
+		if last == ')' && strings.Count(u, "(") < strings.Count(u, ")") {
+			u = u[:len(u)-1]
+			continue
+		}
+		return u
+	}
+	return u
+}
+
+func isPunct(c byte) bool {
+	return c < 128 && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 128
+}
@@ -0,0 +1,120 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	for src, want := range map[string]string{
+		"Hello *world*":                             "<p>Hello <em>world</em></p>\n",
+		"**bold** and __bold__ ~~gone~~":            "<p><strong>bold</strong> and <strong>bold</strong> <del>gone</del></p>\n",
+		"snake_case_name and 2*3*4":                 "<p>snake_case_name and 2<em>3</em>4</p>\n",
+		"a * b * c":                                 "<p>a * b * c</p>\n",
+		"*a **b** c*":                               "<p><em>a <strong>b</strong> c</em></p>\n",
+		"`x < y` and ``a ` b``":                     "<p><code>x &lt; y</code> and <code>a ` b</code></p>\n",
+		"\\*not em\\*":                              "<p>*not em*</p>\n",
+		"line one\nline two":                        "<p>line one\nline two</p>\n",
+		"hard  \nbreak":                             "<p>hard<br>\nbreak</p>\n",
+		"# Title #\n\n### Sub":                      "<h1>Title</h1>\n<h3>Sub</h3>\n",
+		"---":                                       "<hr>\n",
+		"> quoted\n> *text*\n\nafter":               "<blockquote>\n<p>quoted\n<em>text</em></p>\n</blockquote>\n<p>after</p>\n",
+		"- one\n- two\n  more\n\n- three":           "<ul>\n<li>one</li>\n<li>two\nmore</li>\n<li>three</li>\n</ul>\n",
+		"3. three\n4. four":                         "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n",
+		"```go\nif a < b {}\n```\ntext":             "<pre><code class=\"language-go\">if a &lt; b {}\n</code></pre>\n<p>text</p>\n",
+		"[Go](https://go.dev/ \"title\")":           "<p><a href=\"https://go.dev/\" rel=\"nofollow ugc noopener noreferrer\">Go</a></p>\n",
+		"see https://go.dev/doc.":                   "<p>see <a href=\"https://go.dev/doc\" rel=\"nofollow ugc noopener noreferrer\">https://go.dev/doc</a>.</p>\n",
+		"(https://en.wikipedia.org/wiki/Go_(game))": "<p>(<a href=\"https://en.wikipedia.org/wiki/Go_(game)\" rel=\"nofollow ugc noopener noreferrer\">https://en.wikipedia.org/wiki/Go_(game)</a>)</p>\n",
+		"![cat](https://img.example/cat.png)":       "<p><a href=\"https://img.example/cat.png\" rel=\"nofollow ugc noopener noreferrer\">cat</a></p>\n",
+		"[a [nested](https://x.example) link](https://y.example)": "<p><a href=\"https://y.example\" rel=\"nofollow ugc noopener noreferrer\">a [nested](https://x.example) link</a></p>\n",
+		"Tom & Jerry &amp; \"quotes\"":                            "<p>Tom &amp; Jerry &amp;amp; &#34;quotes&#34;</p>\n",
+	} {
+		assert.Equal(t, want, Render(src), src)
+	}
+}
+
+func TestRenderUnsafe(t *testing.T) {
+	for src, want := range map[string]string{
+		"<script>alert(1)</script>":                "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		"<img src=x onerror=alert(1)>":             "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n",
+		"[click](javascript:alert(1))":             "<p>[click](javascript:alert(1))</p>\n",
+		"[click](JaVaScRiPt:alert(1))":             "<p>[click](JaVaScRiPt:alert(1))</p>\n",
+		"[x](https://a.example\" onclick=\"alert)": "<p><a href=\"https://a.example&#34;\" rel=\"nofollow ugc noopener noreferrer\">x</a></p>\n",
+		"<javascript:alert(1)>":                    "<p>&lt;javascript:alert(1)&gt;</p>\n",
+		"```\"><script>\n</script>\n```":           "<pre><code>&lt;/script&gt;\n</code></pre>\n",
+		"```js\" onmouseover=\"x\n1\n```":          "<pre><code>1\n</code></pre>\n",
+	} {
+		assert.Equal(t, want, Render(src), src)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	for src, want := range map[string]string{
+		`<p onclick="x">hi</p>`:                             `<p>hi</p>`,
+		`<a href="javascript:alert(1)" rel="opener">x</a>`:  `<a rel="nofollow ugc noopener noreferrer">x</a>`,
+		`<a href="https://a.example/?a=1&amp;b=2">x</a>`:    `<a href="https://a.example/?a=1&amp;b=2" rel="nofollow ugc noopener noreferrer">x</a>`,
+		`<a href="https://a"><a href="https://b">x</a></a>`: `<a href="https://a" rel="nofollow ugc noopener noreferrer">x</a>`,
+		`<iframe src="https://evil"></iframe>`:              `&lt;iframe src="https://evil"&gt;&lt;/iframe&gt;`,
+		`<em><strong>unclosed`:                              `<em><strong>unclosed</strong></em>`,
+		`<em>a</strong></em>`:                               `<em>a</em>`,
+		`<code class="x onclick">c</code>`:                  `<code>c</code>`,
+		`<ol start="-1 x">`:                                 `<ol></ol>`,
+		`a < b && c > d &copy;`:                             `a &lt; b &amp;&amp; c &gt; d &copy;`,
+		`<br/><hr />`:                                       `<br><hr>`,
+	} {
+		assert.Equal(t, want, Sanitize(src), src)
+	}
+}
+
+var (
+	renderedTag  = regexp.MustCompile(`<(/?)([a-z0-9]+)((?: [a-z]+="[^"<>]*")*)>`)
+	renderedAttr = regexp.MustCompile(` ([a-z]+)="([^"]*)"`)
+)
+
+var renderedAttrs = map[string]bool{"href": true, "rel": true, "start": true, "class": true}
+
+func FuzzRender(f *testing.F) {
+	for _, seed := range []string{
+		"Hello *world* and **bold** ~~gone~~ `code`",
+		"# title\n\n> quote\n\n- a\n- b\n\n3. c\n\n---\n\n```go\nx := 1\n```",
+		"[link](https://example.com/?a=1&b=2) <https://example.com> mail@example.com",
+		"[click](javascript:alert(1)) [x](https://a.example\" onclick=\"alert)",
+		"<script>alert(1)</script><img src=x onerror=alert(1)><a href=\"data:text/html,x\">x</a>",
+		"```js\" onmouseover=\"x\n1\n```",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		out := Render(src)
+		for _, m := range renderedTag.FindAllStringSubmatch(out, -1) {
+			if !allowedTags[m[2]] {
+				t.Fatalf("tag %q is not allowed in %q", m[0], out)
+			}
+			for _, a := range renderedAttr.FindAllStringSubmatch(m[3], -1) {
+				if !renderedAttrs[a[1]] {
+					t.Fatalf("attribute %q is not allowed in %q", a[0], out)
+				}
+				if a[1] != "href" {
+					continue
+				}
+				u, err := url.Parse(html.UnescapeString(a[2]))
+				if err != nil {
+					t.Fatalf("href %q is not a url in %q", a[2], out)
+				}
+				switch strings.ToLower(u.Scheme) {
+				case "http", "https", "mailto":
+				default:
+					t.Fatalf("href %q has scheme %q in %q", a[2], u.Scheme, out)
+				}
+			}
+		}
+		if rest := renderedTag.ReplaceAllString(out, ""); strings.Contains(rest, "<") {
+			t.Fatalf("unescaped < outside of the allowed tags in %q", out)
+		}
+	})
+}
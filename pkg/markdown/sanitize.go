@@ -0,0 +1,141 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Allowed tags, the void ones have no closing tag.
+var allowedTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"pre": true, "code": true, "blockquote": true, "ul": true, "ol": true, "li": true,
+	"em": true, "strong": true, "del": true, "a": true,
+	"br": true, "hr": true,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true}
+
+var (
+	tagPattern    = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[a-zA-Z_:-]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*)\s*/?>`)
+	attrPattern   = regexp.MustCompile(`([a-zA-Z_:-]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	entityPattern = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	classPattern  = regexp.MustCompile(`^language-[a-zA-Z0-9_+-]{1,20}$`)
+)
+
+// Sanitize keeps only the allowlisted tags and attributes of the HTML,
+// the rest of the markup is escaped to text. Links get only http(s) and mailto
+// URLs and are marked as user content. Unclosed tags are closed at the end.
+func Sanitize(s string) string {
+	var out strings.Builder
+	open := []string{}
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			m := tagPattern.FindStringSubmatch(s[i:])
+			if m == nil || !allowedTags[strings.ToLower(m[2])] {
+				out.WriteString("&lt;")
+				i++
+				continue
+			}
+			name := strings.ToLower(m[2])
+			i += len(m[0])
+			if m[1] == "/" {
+				open = closeTag(&out, open, name)
+				continue
+			}
+			if voidTags[name] {
+				out.WriteString("<" + name + ">")
+				continue
+			}
+			// Links can't be nested.
+			if name == "a" && contains(open, "a") {
+				continue
+			}
+			out.WriteString(openTag(name, m[3]))
+			open = append(open, name)
+		case '>':
+			out.WriteString("&gt;")
+			i++
+		case '&':
+			if e := entityPattern.FindString(s[i:]); e != "" {
+				out.WriteString(e)
+				i += len(e)
+				continue
+			}
+			out.WriteString("&amp;")
+			i++
+		default:
+			out.WriteByte(s[i])
+			i++
+		}
+	}
+	for j := len(open) - 1; j >= 0; j-- {
+		out.WriteString("</" + open[j] + ">")
+	}
+	return out.String()
+}
+
+// Writes the closing tag with the tags opened after it, the unmatched ones are dropped.
+func closeTag(out *strings.Builder, open []string, name string) []string {
+	for j := len(open) - 1; j >= 0; j-- {
+		if open[j] != name {
+			continue
+		}
+		for k := len(open) - 1; k >= j; k-- {
+			out.WriteString("</" + open[k] + ">")
+		}
+		return open[:j]
+	}
+	return open
+}
+
+// Rebuilds the tag with the allowed attributes only.
+func openTag(name, attrs string) string {
+	values := map[string]string{}
+	for _, m := range attrPattern.FindAllStringSubmatch(attrs, -1) {
+		values[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	tag := "<" + name
+	switch name {
+	case "a":
+		if safeURL(values["href"]) {
+			tag += ` href="` + html.EscapeString(values["href"]) + `"`
+		}
+		tag += ` rel="nofollow ugc noopener noreferrer"`
+	case "ol":
+		if n, err := strconv.Atoi(values["start"]); err == nil && n >= 0 {
+			tag += ` start="` + strconv.Itoa(n) + `"`
+		}
+	case "code":
+		if classPattern.MatchString(values["class"]) {
+			tag += ` class="` + values["class"] + `"`
+		}
+	}
+	return tag + ">"
+}
+
+func safeURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
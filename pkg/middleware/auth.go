@@ -56,6 +56,18 @@ func (auth Auth) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if user.Banned {
+			WriteMsg(w, "account is banned", http.StatusForbidden)
+			return
+		}
+		// Suspended users can only read
+		if r.Method != http.MethodGet {
+			if err := user.CanWrite(time.Now()); err != nil {
+				WriteMsg(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), sessions.SessionKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,50 @@
+package post
+
+import (
+	"context"
+	"time"
+
+	"crud/pkg/common"
+	"crud/pkg/logger"
+)
+
+type IArchiveRepo interface {
+	ArchiveBefore(context.Context, time.Time) error
+}
+
+// Archiver periodically archives the posts older than maxAge.
+type Archiver struct {
+	repo   IArchiveRepo
+	maxAge time.Duration
+	every  time.Duration
+}
+
+func NewArchiver(repo IArchiveRepo, maxAge, every time.Duration) *Archiver {
+	return &Archiver{
+		repo:   repo,
+		maxAge: maxAge,
+		every:  every,
+	}
+}
+
+// Archives the old posts until the context is done.
+func (a *Archiver) Run(ctx context.Context) {
+	common.RunPeriodically(ctx, a.every, a.archive)
+}
+
+func (a *Archiver) archive(ctx context.Context, now time.Time) {
+	if err := a.repo.ArchiveBefore(ctx, now.Add(-a.maxAge)); err != nil {
+		logger.Log(ctx).Errorf("can't archive old posts: %v", err)
+	}
+}
+
+// The post may be old enough before the Archiver gets to it, the drafts are never archived.
+func (ph *PostHandler) isArchived(p *Post, now time.Time) bool {
+	if p.Archived {
+		return true
+	}
+	if !p.IsPublished() {
+		return false
+	}
+	return ph.Config.ArchiveAfter > 0 && p.Created.Before(now.Add(-ph.Config.ArchiveAfter))
+}
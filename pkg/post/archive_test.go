@@ -0,0 +1,37 @@
+package post
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type archiveRepoStub struct {
+	before time.Time
+}
+
+func (r *archiveRepoStub) ArchiveBefore(_ context.Context, before time.Time) error {
+	r.before = before
+	return nil
+}
+
+func TestArchiver(t *testing.T) {
+	now := time.Now()
+	repo := &archiveRepoStub{}
+	NewArchiver(repo, 48*time.Hour, time.Hour).archive(context.Background(), now)
+	assert.Equal(t, now.Add(-48*time.Hour), repo.before)
+}
+
+func TestIsArchived(t *testing.T) {
+	now := time.Now()
+	ph := &PostHandler{Config: Config{ArchiveAfter: 24 * time.Hour}}
+
+	assert.False(t, ph.isArchived(&Post{Created: now.Add(-time.Hour)}, now))
+	assert.True(t, ph.isArchived(&Post{Created: now.Add(-25 * time.Hour)}, now), "old enough")
+	assert.True(t, ph.isArchived(&Post{Created: now, Archived: true}, now), "archived by the job")
+
+	ph.Config.ArchiveAfter = 0
+	assert.False(t, ph.isArchived(&Post{Created: now.Add(-25 * time.Hour)}, now), "archiving disabled")
+}
@@ -0,0 +1,114 @@
+package post
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"crud/pkg/automod"
+	"crud/pkg/comment"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/report"
+	"crud/pkg/user"
+)
+
+// System user posting the AutoModerator replies.
+var automodUser = &user.User{Id: "0", Username: "AutoModerator"}
+
+type IAutomodRepo interface {
+	GetRuleset(context.Context, string) (*automod.Ruleset, error)
+	LogHit(context.Context, *automod.Hit) error
+}
+
+// The outcome of the category rules for the new content.
+type automodVerdict struct {
+	hits    []*automod.Rule
+	remove  bool
+	pending bool
+	flag    bool
+	replies []string
+}
+
+// Evaluates the category rules. Broken or unavailable rules don't block the content.
+func (ph *PostHandler) checkAutomod(r *http.Request, category string, item *automod.Item) *automodVerdict {
+	verdict := new(automodVerdict)
+	rs, err := ph.Automod.GetRuleset(r.Context(), category)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load automod rules of %s: %v", category, err)
+		return verdict
+	}
+
+	verdict.hits = rs.Evaluate(item, time.Now())
+	for _, rule := range verdict.hits {
+		switch rule.Action {
+		case automod.ActionRemove:
+			verdict.remove = true
+		case automod.ActionRequireApproval:
+			verdict.pending = true
+		case automod.ActionFlag:
+			verdict.flag = true
+		case automod.ActionReply:
+			verdict.replies = append(verdict.replies, rule.Message)
+		}
+	}
+	return verdict
+}
+
+// Logs the rule hits, files the reports and posts the replies for the saved content.
+// Reports whether the post has changed.
+func (ph *PostHandler) applyAutomod(r *http.Request, verdict *automodVerdict, category string,
+	targetType report.TargetType, postId PostId, targetId, authorId string,
+) bool {
+	if len(verdict.hits) == 0 {
+		return false
+	}
+
+	names := make([]string, 0, len(verdict.hits))
+	for _, rule := range verdict.hits {
+		names = append(names, rule.Name)
+		hit := &automod.Hit{
+			Category:   category,
+			Rule:       rule.Name,
+			Action:     rule.Action,
+			TargetType: automod.TargetType(targetType),
+			TargetId:   targetId,
+			AuthorId:   authorId,
+			Created:    time.Now(),
+		}
+		logger.Log(r.Context()).Infof("automod rule %q (%s) hit %s %s in %s",
+			rule.Name, rule.Action, targetType, targetId, category)
+		if err := ph.Automod.LogHit(r.Context(), hit); err != nil {
+			logger.Log(r.Context()).Errorf("can't log automod hit: %v", err)
+		}
+	}
+
+	if verdict.flag || verdict.pending {
+		rep := &report.Report{
+			Category:   category,
+			TargetType: targetType,
+			TargetId:   targetId,
+			PostId:     string(postId),
+			Reason:     report.ReasonAutomod,
+			Text:       strings.Join(names, ", "),
+			Created:    time.Now(),
+		}
+		if err := ph.Reports.Add(r.Context(), rep); err != nil {
+			logger.Log(r.Context()).Errorf("can't file automod report for %s: %v", targetId, err)
+		}
+	}
+
+	for _, msg := range verdict.replies {
+		reply := &comment.Comment{
+			Id:      comment.CommentId(RandStringRunes(12)),
+			Author:  automodUser,
+			Created: time.Now(),
+		}
+		reply.SetBody(msg)
+		if _, err := ph.PostRepo.AddComment(r.Context(), postId, reply); err != nil {
+			logger.Log(r.Context()).Errorf("can't post automod reply to %s: %v", postId, err)
+		}
+	}
+	return len(verdict.replies) > 0
+}
@@ -0,0 +1,131 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"crud/pkg/logger"
+	"crud/pkg/user"
+)
+
+// CrosspostRef is the crossposted post as it was when crossposted.
+// The client sends only its id.
+type CrosspostRef struct {
+	Id       PostId     `json:"id"`
+	Category string     `json:"category,omitempty"`
+	Title    string     `json:"title,omitempty"`
+	Author   *user.User `json:"author,omitempty"`
+	// Set when the original is deleted, the crosspost keeps its own votes and comments.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// Replaces the reference sent by the client with the original post. The crossposts
+// of the crossposts refer to their original, the title and labels default to its ones.
+func (ph *PostHandler) attachCrosspost(ctx context.Context, p *Post) error {
+	if p.Type != PostCrosspost || p.CrosspostOf == nil {
+		return nil
+	}
+	orig, err := ph.PostRepo.GetById(ctx, p.CrosspostOf.Id)
+	if err != nil {
+		return fmt.Errorf("%w: unknown post %q to crosspost", ErrInvalidPost, p.CrosspostOf.Id)
+	}
+	if orig.CrosspostOf != nil {
+		if orig.CrosspostOf.Removed {
+			return fmt.Errorf("%w: the original post is deleted", ErrInvalidPost)
+		}
+		if orig, err = ph.PostRepo.GetById(ctx, orig.CrosspostOf.Id); err != nil {
+			return fmt.Errorf("%w: the original post is deleted", ErrInvalidPost)
+		}
+	}
+	if !orig.isLive() {
+		return fmt.Errorf("%w: unknown post %q to crosspost", ErrInvalidPost, p.CrosspostOf.Id)
+	}
+	if orig.Category == p.Category {
+		return fmt.Errorf("%w: can't crosspost to the category of the original", ErrInvalidPost)
+	}
+
+	if p.Title == "" {
+		p.Title = orig.Title
+	}
+	p.NSFW = p.NSFW || orig.NSFW
+	p.Spoiler = p.Spoiler || orig.Spoiler
+	p.CrosspostOf = &CrosspostRef{
+		Id:       orig.Id,
+		Category: orig.Category,
+		Title:    orig.Title,
+		Author:   orig.Author,
+	}
+	return nil
+}
+
+// The post can be crossposted, and its crossposts published, while it's live.
+func (p *Post) isLive() bool {
+	return p.IsPublished() && !p.Removed && !p.Hidden && !p.Pending
+}
+
+// The crosspost count leaves out the drafts and the posts removed by the moderators.
+func (p *Post) countsAsCrosspost() bool {
+	return p.IsPublished() && !p.Removed
+}
+
+// Reports if the original of the crosspost is still live, the other posts have none to check.
+func originalLive(ctx context.Context, getById func(context.Context, PostId) (*Post, error), p *Post) (bool, error) {
+	if p.CrosspostOf == nil {
+		return true, nil
+	}
+	if p.CrosspostOf.Removed {
+		return false, nil
+	}
+	orig, err := getById(ctx, p.CrosspostOf.Id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return orig.isLive(), nil
+}
+
+// Changes the crosspost count of the original, the count is only shown so the errors are logged.
+func (ph *PostHandler) countCrosspost(ctx context.Context, p *Post, delta int) {
+	if p.CrosspostOf == nil {
+		return
+	}
+	if err := ph.PostRepo.AddCrossposts(ctx, p.CrosspostOf.Id, delta); err != nil {
+		logger.Log(ctx).Errorf("can't count crossposts of post %s: %v", p.CrosspostOf.Id, err)
+	}
+}
+
+// Marks the references to the deleted or restored original in its crossposts,
+// the drafts and scheduled ones too, which the crosspost count leaves out.
+func (ph *PostHandler) markCrossposts(ctx context.Context, p *Post, removed bool) {
+	if err := ph.PostRepo.SetCrosspostsRemoved(ctx, p.Id, removed); err != nil {
+		logger.Log(ctx).Errorf("can't mark crossposts of post %s: %v", p.Id, err)
+	}
+}
+
+// Follows the moderators removing or approving the post: the removed crosspost
+// leaves the count and the crossposts of the removed original mark it like a deleted one.
+func (ph *PostHandler) moderateCrossposts(ctx context.Context, p *Post, flags map[PostFlag]bool) {
+	removed, ok := flags[FlagRemoved]
+	if !ok || removed == p.Removed || !p.IsPublished() {
+		return
+	}
+	delta := 1
+	if removed {
+		delta = -1
+	}
+	ph.countCrosspost(ctx, p, delta)
+	ph.markCrossposts(ctx, p, removed)
+}
+
+// The deleted original is shown without its title and author.
+func (ref *CrosspostRef) visible() *CrosspostRef {
+	if ref == nil || !ref.Removed {
+		return ref
+	}
+	return &CrosspostRef{Id: ref.Id, Category: ref.Category, Removed: true}
+}
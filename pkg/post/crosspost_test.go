@@ -0,0 +1,123 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"crud/pkg/user"
+)
+
+type crosspostRepoStub struct {
+	IPostRepo
+	posts  map[PostId]*Post
+	marked map[PostId]bool
+}
+
+func (r *crosspostRepoStub) GetById(_ context.Context, id PostId) (*Post, error) {
+	if p, ok := r.posts[id]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("post: post not found: %w", mongo.ErrNoDocuments)
+}
+
+func (r *crosspostRepoStub) SetCrosspostsRemoved(_ context.Context, id PostId, removed bool) error {
+	r.marked[id] = removed
+	return nil
+}
+
+func TestAttachCrosspost(t *testing.T) {
+	author := &user.User{Id: "1", Username: "pike"}
+	repo := &crosspostRepoStub{posts: map[PostId]*Post{
+		"orig":    {Id: "orig", Category: "golang", Title: "Go 2", Author: author, NSFW: true},
+		"xpost":   {Id: "xpost", Category: "music", CrosspostOf: &CrosspostRef{Id: "orig"}},
+		"gone":    {Id: "gone", Category: "music", CrosspostOf: &CrosspostRef{Id: "deleted", Removed: true}},
+		"removed": {Id: "removed", Category: "golang", Removed: true},
+		"draft":   {Id: "draft", Category: "golang", Status: StatusDraft},
+	}}
+	ph := &PostHandler{PostRepo: repo}
+
+	t.Run("should copy the original", func(t *testing.T) {
+		p := &Post{Type: PostCrosspost, Category: "programming", CrosspostOf: &CrosspostRef{Id: "orig", Title: "fake"}}
+		assert.Nil(t, ph.attachCrosspost(context.Background(), p))
+		assert.Equal(t, &CrosspostRef{Id: "orig", Category: "golang", Title: "Go 2", Author: author}, p.CrosspostOf)
+		assert.Equal(t, "Go 2", p.Title)
+		assert.True(t, p.NSFW)
+	})
+
+	t.Run("should refer to the original of the crosspost", func(t *testing.T) {
+		p := &Post{Type: PostCrosspost, Title: "Again", Category: "programming", CrosspostOf: &CrosspostRef{Id: "xpost"}}
+		assert.Nil(t, ph.attachCrosspost(context.Background(), p))
+		assert.Equal(t, PostId("orig"), p.CrosspostOf.Id)
+		assert.Equal(t, "Again", p.Title)
+	})
+
+	t.Run("should reject the unavailable originals", func(t *testing.T) {
+		for _, id := range []PostId{"unknown", "gone", "removed", "draft", "orig"} {
+			category := "programming"
+			if id == "orig" {
+				category = "golang"
+			}
+			p := &Post{Type: PostCrosspost, Category: category, CrosspostOf: &CrosspostRef{Id: id}}
+			assert.ErrorIs(t, ph.attachCrosspost(context.Background(), p), ErrInvalidPost, id)
+		}
+	})
+
+	t.Run("should hide the deleted original", func(t *testing.T) {
+		p := &Post{Id: "1", CrosspostOf: &CrosspostRef{Id: "orig", Category: "golang", Title: "Go 2", Author: author, Removed: true}}
+		assert.True(t, (&visibility{}).post(p))
+		assert.Equal(t, &CrosspostRef{Id: "orig", Category: "golang", Removed: true}, p.CrosspostOf)
+	})
+}
+
+func TestMarkCrossposts(t *testing.T) {
+	repo := &crosspostRepoStub{marked: map[PostId]bool{}}
+	ph := &PostHandler{PostRepo: repo}
+
+	ph.markCrossposts(context.Background(), &Post{Id: "orig"}, true)
+	assert.Equal(t, map[PostId]bool{"orig": true}, repo.marked, "the uncounted drafts are marked too")
+}
+
+func TestOriginalLive(t *testing.T) {
+	repo := &crosspostRepoStub{posts: map[PostId]*Post{
+		"orig":    {Id: "orig"},
+		"removed": {Id: "removed", Removed: true},
+	}}
+	ctx := context.Background()
+
+	for p, want := range map[*Post]bool{
+		{Id: "1"}: true,
+		{Id: "2", CrosspostOf: &CrosspostRef{Id: "orig"}}:                true,
+		{Id: "3", CrosspostOf: &CrosspostRef{Id: "orig", Removed: true}}: false,
+		{Id: "4", CrosspostOf: &CrosspostRef{Id: "removed"}}:             false,
+		{Id: "5", CrosspostOf: &CrosspostRef{Id: "deleted"}}:             false,
+	} {
+		live, err := originalLive(ctx, repo.GetById, p)
+		assert.Nil(t, err, p.Id)
+		assert.Equal(t, want, live, p.Id)
+	}
+
+	failing := func(context.Context, PostId) (*Post, error) { return nil, fmt.Errorf("down") }
+	_, err := originalLive(ctx, failing, &Post{CrosspostOf: &CrosspostRef{Id: "orig"}})
+	assert.NotNil(t, err, "the errors aren't taken for the deleted original")
+}
+
+func TestSetCrosspostsRemoved(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockUpdateResult := NewMockIMongoUpdateResult(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+
+	mockMongoColl.EXPECT().
+		UpdateMany(ctx, bson.M{"crosspostof.id": PostId("orig")}, bson.M{"$set": bson.M{"crosspostof.removed": true}}).
+		Return(mockUpdateResult, nil)
+	assert.Nil(t, repo.SetCrosspostsRemoved(ctx, "orig", true))
+}
@@ -0,0 +1,213 @@
+package post
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/automod"
+	"crud/pkg/comment"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/report"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+// Edits the author's post, accepts `{"text": "...", "tags": ["go"], "flair": "Question"}`,
+// the missing fields are kept and the empty flair clears it. The new text is checked
+// by the automod rules and the spam classifier again.
+func (ph *PostHandler) EditPost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	postId := PostId(mux.Vars(r)["post_id"])
+	author, post, ok := ph.editable(w, r, postId)
+	if !ok {
+		return
+	}
+	if post.Author == nil || post.Author.Id != author.Id {
+		WriteMsg(w, "only the author can edit the post", http.StatusForbidden)
+		return
+	}
+
+	body := struct {
+		Text  *string
+		Tags  *[]string
+		Flair *string
+	}{}
+	if err := ParseReqBody(r.Body, &body); err != nil {
+		WriteMsg(w, "can't parse post", http.StatusBadRequest)
+		return
+	}
+	if body.Text == nil && body.Tags == nil && body.Flair == nil {
+		WriteMsg(w, "nothing to edit", http.StatusBadRequest)
+		return
+	}
+	if body.Text != nil && (post.Type == PostLink || post.Type == PostCrosspost) {
+		WriteMsg(w, "link posts and crossposts have no text to edit", http.StatusBadRequest)
+		return
+	}
+
+	if body.Tags != nil || body.Flair != nil {
+		tags, flair := post.Tags, post.Flair
+		var err error
+		if body.Tags != nil {
+			tags, err = normalizeTags(*body.Tags)
+		}
+		if err == nil && body.Flair != nil {
+			flair, err = ph.resolveFlair(r.Context(), post.Category, *body.Flair)
+		}
+		if errors.Is(err, ErrInvalidPost) {
+			WriteMsg(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == nil {
+			err = ph.PostRepo.EditTags(r.Context(), postId, tags, flair)
+		}
+		if err != nil {
+			logger.Log(r.Context()).Errorf("can't edit tags of post %s: %v", postId, err)
+			WriteMsg(w, "failed editing post", http.StatusInternalServerError)
+			return
+		}
+	}
+	if body.Text != nil && !ph.editText(w, r, author, post, *body.Text) {
+		return
+	}
+
+	if reloaded, err := ph.PostRepo.GetById(r.Context(), postId); err == nil {
+		post = reloaded
+	}
+	ph.writePost(w, r, post)
+}
+
+// Replaces the post text and checks it again, otherwise writes the error response.
+func (ph *PostHandler) editText(w http.ResponseWriter, r *http.Request, author *user.User, post *Post, text string) bool {
+	now := time.Now()
+	post.setText(text)
+	post.Edited = &now
+	if err := ph.PostRepo.EditText(r.Context(), post.Id, post.Text, post.TextHTML, now); err != nil {
+		logger.Log(r.Context()).Errorf("can't edit post %s: %v", post.Id, err)
+		WriteMsg(w, "failed editing post", http.StatusInternalServerError)
+		return false
+	}
+	verdict := ph.checkAutomod(r, post.Category, &automod.Item{
+		Target:        automod.TargetPost,
+		Title:         post.Title,
+		Text:          post.Text,
+		AuthorCreated: author.Created,
+	})
+	spamScore, isSpam := ph.Spam.Check(post.spamDoc())
+	flags := recheckFlags(verdict, isSpam)
+	if len(flags) > 0 {
+		if err := ph.PostRepo.SetFlags(r.Context(), post.Id, flags); err != nil {
+			logger.Log(r.Context()).Errorf("can't hold edited post %s: %v", post.Id, err)
+		} else {
+			ph.moderateCrossposts(r.Context(), post, flags)
+		}
+	}
+	if isSpam {
+		ph.holdSpam(r, post.Category, report.TargetPost, post.Id, string(post.Id), spamScore)
+	}
+	// The automod replied to the new post already.
+	verdict.replies = nil
+	ph.applyAutomod(r, verdict, post.Category, report.TargetPost, post.Id, string(post.Id), author.Id)
+	return true
+}
+
+// Replaces the body of the author's comment, accepts `{"comment": "..."}`
+// like AddComment and checks it the same way as EditPost.
+func (ph *PostHandler) EditComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	postId := PostId(vars["post_id"])
+	commentId := comment.CommentId(vars["comment_id"])
+	author, post, ok := ph.editable(w, r, postId)
+	if !ok {
+		return
+	}
+	c := findComment(post, commentId)
+	if c == nil || c.DeletedAt != nil {
+		WriteMsg(w, "comment not found", http.StatusNotFound)
+		return
+	}
+	if c.Author == nil || c.Author.Id != author.Id {
+		WriteMsg(w, "only the author can edit the comment", http.StatusForbidden)
+		return
+	}
+
+	body := struct{ Comment string }{}
+	if err := ParseReqBody(r.Body, &body); err != nil {
+		WriteMsg(w, "failed parsing comment body", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	c.SetBody(body.Comment)
+	err := ph.PostRepo.EditComment(r.Context(), postId, commentId, c.Body, c.BodyHTML, now)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't edit comment %s of post %s: %v", commentId, postId, err)
+		WriteMsg(w, "failed editing comment", http.StatusInternalServerError)
+		return
+	}
+	verdict := ph.checkAutomod(r, post.Category, &automod.Item{
+		Target:        automod.TargetComment,
+		Text:          c.Body,
+		AuthorCreated: author.Created,
+	})
+	spamScore, isSpam := ph.Spam.Check(commentSpamDoc(c))
+	flags := recheckFlags(verdict, isSpam)
+	if len(flags) > 0 {
+		if err := ph.PostRepo.SetCommentFlags(r.Context(), postId, commentId, flags); err != nil {
+			logger.Log(r.Context()).Errorf("can't hold edited comment %s: %v", commentId, err)
+		}
+	}
+	if isSpam {
+		ph.holdSpam(r, post.Category, report.TargetComment, postId, string(commentId), spamScore)
+	}
+	verdict.replies = nil
+	ph.applyAutomod(r, verdict, post.Category, report.TargetComment, postId, string(commentId), author.Id)
+
+	if reloaded, err := ph.PostRepo.GetById(r.Context(), postId); err == nil {
+		post = reloaded
+	}
+	ph.writePost(w, r, post)
+}
+
+// Loads the post whose content the authenticated user may edit,
+// otherwise writes the error response.
+func (ph *PostHandler) editable(w http.ResponseWriter, r *http.Request, postId PostId) (*user.User, *Post, bool) {
+	author, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	if err := author.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return nil, nil, false
+	}
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if post.Locked || ph.isArchived(post, time.Now()) {
+		WriteMsg(w, "the post is locked or archived, editing is not allowed", http.StatusForbidden)
+		return nil, nil, false
+	}
+	return author, post, true
+}
+
+// The edited content is held or removed like the new one, but never released.
+func recheckFlags(verdict *automodVerdict, isSpam bool) map[PostFlag]bool {
+	flags := map[PostFlag]bool{}
+	if verdict.remove {
+		flags[FlagRemoved] = true
+	}
+	if verdict.pending || isSpam {
+		flags[FlagPending] = true
+	}
+	return flags
+}
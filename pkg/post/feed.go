@@ -0,0 +1,138 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"crud/pkg/user"
+)
+
+// Max number of feeds cached, the ones expiring first are dropped to make room.
+const feedCacheSize = 10_000
+
+type IFollowRepo interface {
+	GetFollowing(context.Context, string) ([]*user.User, error)
+}
+
+type ISubscriptionRepo interface {
+	GetSubscriptions(context.Context, string) ([]string, error)
+}
+
+// Feed builds personalized home feeds from the posts of followed users
+// and subscribed categories. Built feeds are cached per user for ttl.
+type Feed struct {
+	posts         IPostRepo
+	follows       IFollowRepo
+	subscriptions ISubscriptionRepo
+	ttl           time.Duration
+
+	mu        sync.Mutex
+	cache     map[string]feedEntry
+	cacheSize int
+}
+
+type feedEntry struct {
+	posts   []*Post
+	expires time.Time
+}
+
+func NewFeed(posts IPostRepo, follows IFollowRepo, subscriptions ISubscriptionRepo, ttl time.Duration) *Feed {
+	return &Feed{
+		posts:         posts,
+		follows:       follows,
+		subscriptions: subscriptions,
+		ttl:           ttl,
+		cache:         map[string]feedEntry{},
+		cacheSize:     feedCacheSize,
+	}
+}
+
+// Returns ranked feed of the user, from the cache if it's fresh.
+func (f *Feed) Get(ctx context.Context, userId string) ([]*Post, error) {
+	now := time.Now()
+	f.mu.Lock()
+	entry, ok := f.cache[userId]
+	f.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return copyPosts(entry.posts), nil
+	}
+
+	posts, err := f.build(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	delete(f.cache, userId)
+	f.evict(now)
+	f.cache[userId] = feedEntry{posts: posts, expires: now.Add(f.ttl)}
+	f.mu.Unlock()
+
+	return copyPosts(posts), nil
+}
+
+// Drops the expired feeds and, if the cache is still full, the one expiring first.
+// Must be called with mu held.
+func (f *Feed) evict(now time.Time) {
+	first := ""
+	for id, e := range f.cache {
+		if now.After(e.expires) {
+			delete(f.cache, id)
+			continue
+		}
+		if first == "" || e.expires.Before(f.cache[first].expires) {
+			first = id
+		}
+	}
+	if len(f.cache) >= f.cacheSize {
+		delete(f.cache, first)
+	}
+}
+
+// Drops the cached feed of the user, e.g. when they follow someone.
+func (f *Feed) Invalidate(userId string) {
+	f.mu.Lock()
+	delete(f.cache, userId)
+	f.mu.Unlock()
+}
+
+// The visibility filtering changes the posts it gets, so every
+// request gets its own copies of the cached ones.
+func copyPosts(posts []*Post) []*Post {
+	copies := make([]*Post, len(posts))
+	for i, p := range posts {
+		cp := *p
+		if p.Poll != nil {
+			poll := *p.Poll
+			cp.Poll = &poll
+		}
+		copies[i] = &cp
+	}
+	return copies
+}
+
+func (f *Feed) build(ctx context.Context, userId string) ([]*Post, error) {
+	following, err := f.follows.GetFollowing(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("post/feed: can't load followed users: %w", err)
+	}
+	categories, err := f.subscriptions.GetSubscriptions(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("post/feed: can't load subscriptions: %w", err)
+	}
+
+	usernames := make([]string, len(following))
+	for i, u := range following {
+		usernames[i] = u.Username
+	}
+
+	posts, err := f.posts.GetFeedPosts(ctx, usernames, categories)
+	if err != nil {
+		return nil, fmt.Errorf("post/feed: can't load posts: %w", err)
+	}
+	sortHot(posts)
+
+	return posts, nil
+}
@@ -0,0 +1,123 @@
+package post
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"crud/pkg/user"
+)
+
+type feedRepoStub struct {
+	IPostRepo
+	posts      []*Post
+	loads      int
+	usernames  []string
+	categories []string
+}
+
+func (r *feedRepoStub) GetFeedPosts(_ context.Context, usernames, categories []string) ([]*Post, error) {
+	r.loads++
+	r.usernames, r.categories = usernames, categories
+	return r.posts, nil
+}
+
+type followStub []*user.User
+
+func (f followStub) GetFollowing(context.Context, string) ([]*user.User, error) {
+	return f, nil
+}
+
+type subscriptionStub []string
+
+func (s subscriptionStub) GetSubscriptions(context.Context, string) ([]string, error) {
+	return s, nil
+}
+
+func TestFeed(t *testing.T) {
+	pike := &user.User{Id: "2", Username: "pike"}
+	poll := &Poll{Voters: []*PollVote{{UserId: "1", OptionId: 1}}}
+	repo := &feedRepoStub{posts: []*Post{{Id: "1", Author: pike, NSFW: true, Poll: poll}, {Id: "2", Author: pike}}}
+	feed := NewFeed(repo, followStub{pike}, subscriptionStub{"golang"}, time.Minute)
+	ctx := context.Background()
+
+	t.Run("should load the posts of the followed users and subscribed categories at once", func(t *testing.T) {
+		posts, err := feed.Get(ctx, "1")
+		assert.Nil(t, err)
+		assert.Len(t, posts, 2)
+		assert.Equal(t, 1, repo.loads)
+		assert.Equal(t, []string{"pike"}, repo.usernames)
+		assert.Equal(t, []string{"golang"}, repo.categories)
+	})
+
+	t.Run("should keep the cached posts unchanged", func(t *testing.T) {
+		posts, _ := feed.Get(ctx, "1")
+		vis := &visibility{viewer: &user.User{Id: "1"}, prefs: user.ContentPrefs{NSFW: user.ContentBlur}}
+		visible := vis.listing(posts)
+		assert.True(t, visible[0].Blurred || visible[1].Blurred)
+
+		cached, _ := feed.Get(ctx, "1")
+		for _, p := range cached {
+			assert.False(t, p.Blurred)
+			if p.Poll != nil {
+				assert.Nil(t, p.Poll.MyVote)
+			}
+		}
+		assert.Equal(t, 1, repo.loads)
+	})
+
+	t.Run("should rebuild the invalidated feed", func(t *testing.T) {
+		feed.Invalidate("1")
+		_, err := feed.Get(ctx, "1")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, repo.loads)
+	})
+
+	t.Run("should drop the feed expiring first when the cache is full", func(t *testing.T) {
+		feed.cacheSize = 2
+		_, err := feed.Get(ctx, "2")
+		assert.Nil(t, err)
+		_, err = feed.Get(ctx, "3")
+		assert.Nil(t, err)
+		assert.Len(t, feed.cache, 2)
+		assert.NotContains(t, feed.cache, "1")
+	})
+}
+
+func TestRepoGetFeedPosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockCursor := NewMockIMongoCursor(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+
+	mockMongoColl.EXPECT().
+		Find(ctx, gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, filter interface{}, opts ...*options.FindOptions) {
+			query := filter.(bson.M)
+			assert.Equal(t, bson.A{
+				bson.M{"author.username": bson.M{"$in": []string{"pike"}}},
+				bson.M{"category": bson.M{"$in": []string{"golang"}}},
+			}, query["$or"])
+			assert.Contains(t, query, "created", "only the posts of the hot window")
+			assert.Equal(t, bson.D{{Key: "created", Value: -1}}, opts[0].Sort)
+			assert.Equal(t, int64(maxHotCandidates), *opts[0].Limit)
+		}).
+		Return(mockCursor, nil)
+	mockCursor.EXPECT().All(ctx, gomock.Any()).Return(nil)
+	mockCursor.EXPECT().Close(ctx).Return(nil)
+
+	_, err := repo.GetFeedPosts(ctx, []string{"pike"}, []string{"golang"})
+	assert.Nil(t, err)
+
+	posts, err := repo.GetFeedPosts(ctx, nil, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, posts, "nothing is followed")
+}
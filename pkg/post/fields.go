@@ -0,0 +1,129 @@
+package post
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// listField is the post field available in `?fields=` of the listings.
+type listField struct {
+	// Mongo projection of the field.
+	projection interface{}
+	value      func(*Post) interface{}
+}
+
+// The poll without its voters.
+var pollResults = bson.M{"$cond": bson.A{
+	bson.M{"$eq": bson.A{bson.M{"$type": "$poll"}, "object"}},
+	bson.M{"options": "$poll.options", "closesat": "$poll.closesat", "totalvotes": "$poll.totalvotes"},
+	"$$REMOVE",
+}}
+
+var listFields = map[string]listField{
+	"id":               {1, func(p *Post) interface{} { return p.Id }},
+	"author":           {1, func(p *Post) interface{} { return p.Author }},
+	"title":            {1, func(p *Post) interface{} { return p.Title }},
+	"type":             {1, func(p *Post) interface{} { return p.Type }},
+	"text":             {1, func(p *Post) interface{} { return p.Text }},
+	"textHtml":         {1, func(p *Post) interface{} { return p.TextHTML }},
+	"edited":           {1, func(p *Post) interface{} { return p.Edited }},
+	"url":              {1, func(p *Post) interface{} { return p.URL }},
+	"category":         {1, func(p *Post) interface{} { return p.Category }},
+	"views":            {1, func(p *Post) interface{} { return p.Views }},
+	"score":            {1, func(p *Post) interface{} { return p.Score }},
+	"upvotePercentage": {1, func(p *Post) interface{} { return p.UpvotePercentage }},
+	"created":          {1, func(p *Post) interface{} { return p.Created }},
+	"removed":          {1, func(p *Post) interface{} { return p.Removed }},
+	"approved":         {1, func(p *Post) interface{} { return p.Approved }},
+	"pinned":           {1, func(p *Post) interface{} { return p.Pinned }},
+	"locked":           {1, func(p *Post) interface{} { return p.Locked }},
+	"archived":         {1, func(p *Post) interface{} { return p.Archived }},
+	"collapsed":        {1, func(p *Post) interface{} { return p.Collapsed }},
+	"hidden":           {1, func(p *Post) interface{} { return p.Hidden }},
+	"pending":          {1, func(p *Post) interface{} { return p.Pending }},
+	"nsfw":             {1, func(p *Post) interface{} { return p.NSFW }},
+	"spoiler":          {1, func(p *Post) interface{} { return p.Spoiler }},
+	"blurred":          {1, func(p *Post) interface{} { return p.Blurred }},
+	"poll":             {pollResults, func(p *Post) interface{} { return p.Poll }},
+	"image":            {1, func(p *Post) interface{} { return p.Image }},
+	"domain":           {1, func(p *Post) interface{} { return p.Domain }},
+	"preview":          {1, func(p *Post) interface{} { return p.Preview }},
+	"tags":             {1, func(p *Post) interface{} { return p.Tags }},
+	"flair":            {1, func(p *Post) interface{} { return p.Flair }},
+	"crosspostOf":      {1, func(p *Post) interface{} { return p.CrosspostOf }},
+	"crossposts":       {1, func(p *Post) interface{} { return p.Crossposts }},
+	"comments":         {1, func(p *Post) interface{} { return p.Comments }},
+	"votes":            {1, func(p *Post) interface{} { return p.Votes }},
+	// Counted per viewer, see fieldsProjection.
+	"commentCount": {nil, func(p *Post) interface{} { return p.CommentCount }},
+	"voteCount": {
+		bson.M{"$size": bson.M{"$ifNull": bson.A{"$votes", bson.A{}}}},
+		func(p *Post) interface{} { return p.VoteCount },
+	},
+}
+
+// The default listing representation: everything but the comments and votes.
+var summaryFields = []string{
+	"id", "author", "title", "type", "text", "textHtml", "url", "domain", "preview", "poll",
+	"image", "crosspostOf", "crossposts", "category", "tags", "flair", "views", "score", "upvotePercentage",
+	"created", "edited", "removed", "approved", "pinned", "locked", "archived", "collapsed", "hidden", "pending",
+	"nsfw", "spoiler", "blurred", "commentCount", "voteCount",
+}
+
+// Fields needed to check the visibility and to sort, loaded even when not requested.
+var requiredFields = []string{
+	"id", "author", "score", "created", "removed", "hidden", "pending", "nsfw", "spoiler", "pinned",
+}
+
+// Parses the comma separated `?fields=`, the id is always included.
+func parseFields(value string) ([]string, error) {
+	fields := []string{"id"}
+	seen := map[string]bool{"id": true}
+	for _, f := range strings.Split(value, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		if _, ok := listFields[f]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q in fields", ErrBadFilter, f)
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Mongo projection loading the fields with the required ones.
+// The comments are counted as the viewer would see them listed.
+func fieldsProjection(fields []string, vis *visibility) bson.M {
+	projection := bson.M{}
+	for _, list := range [][]string{fields, requiredFields} {
+		for _, f := range list {
+			// Mongo field names are the lowercased ones of the struct.
+			projection[strings.ToLower(f)] = listFields[f].projection
+		}
+	}
+	if _, ok := projection["commentcount"]; ok {
+		projection["commentcount"] = bson.M{"$size": vis.comments()}
+	}
+	return projection
+}
+
+// Returns the post with only the requested fields.
+func (p *Post) sparse(fields []string) map[string]interface{} {
+	res := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		res[f] = listFields[f].value(p)
+	}
+	return res
+}
+
+func sparsePosts(posts []*Post, fields []string) []map[string]interface{} {
+	res := make([]map[string]interface{}, 0, len(posts))
+	for _, p := range posts {
+		res = append(res, p.sparse(fields))
+	}
+	return res
+}
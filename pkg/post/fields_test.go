@@ -0,0 +1,37 @@
+package post
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"crud/pkg/comment"
+)
+
+func TestSparseFields(t *testing.T) {
+	fields, err := parseFields("title, commentCount,title,")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "title", "commentCount"}, fields)
+
+	t.Run("should project the requested and required fields only", func(t *testing.T) {
+		projection := fieldsProjection(fields, nil)
+		assert.Equal(t, 1, projection["title"])
+		assert.Equal(t, 1, projection["author"], "required for visibility")
+		assert.Contains(t, projection, "commentcount")
+		assert.NotContains(t, projection, "comments")
+		assert.NotContains(t, projection, "votes")
+	})
+
+	t.Run("should render the requested fields only", func(t *testing.T) {
+		p := &Post{Id: "1", Title: "Go", Text: "secret", CommentCount: 2, Comments: []*comment.Comment{}}
+		assert.Equal(t, map[string]interface{}{"id": PostId("1"), "title": "Go", "commentCount": 2}, p.sparse(fields))
+	})
+
+	t.Run("summary should not load comments and votes", func(t *testing.T) {
+		vis := &visibility{moderator: true}
+		projection := fieldsProjection(summaryFields, vis)
+		assert.NotContains(t, projection, "comments")
+		assert.Equal(t, bson.M{"$size": vis.comments()}, projection["commentcount"])
+	})
+}
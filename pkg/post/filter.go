@@ -0,0 +1,159 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "crud/pkg/common"
+)
+
+var ErrBadFilter = errors.New("bad filter")
+
+type SortOrder string
+
+const (
+	SortNew SortOrder = "new"
+	SortTop SortOrder = "top"
+	SortHot SortOrder = "hot"
+)
+
+// Filter narrows the post listing, all the set conditions must match.
+type Filter struct {
+	Author string
+	Type   string
+	Since  time.Time
+	Until  time.Time
+	// nil doesn't limit the score, it may be negative.
+	MinScore    *int
+	MinComments int
+	// Link posts to the domain or its subdomains.
+	Domain   string
+	Category string
+	Tag      string
+	// Exact text of the category flair.
+	Flair string
+
+	Sort SortOrder
+	// Zero limit returns all the posts.
+	Page Page
+	// Fields of the sparse representation, the summary by default.
+	Fields []string
+
+	// The viewer whose invisible posts the query skips, so the pages come full.
+	visibility *visibility
+	// Puts the pinned posts before the sorted ones, in the category listing.
+	pinnedFirst bool
+}
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// Parses the filter from
+// `?author=&type=&since=&until=&min_score=&min_comments=&domain=&category=&tag=&flair=&sort=&fields=&limit=&offset=`.
+// Unknown and repeated params are rejected.
+func ParseFilter(params url.Values) (*Filter, error) {
+	f := &Filter{Sort: SortNew, Fields: summaryFields}
+	for key, values := range params {
+		if len(values) > 1 {
+			return nil, fmt.Errorf("%w: %s is given more than once", ErrBadFilter, key)
+		}
+		if err := f.set(key, values[0]); err != nil {
+			return nil, err
+		}
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return nil, fmt.Errorf("%w: since must be before until", ErrBadFilter)
+	}
+	return f, nil
+}
+
+func (f *Filter) set(key, value string) error {
+	var err error
+	switch key {
+	case "author":
+		f.Author = value
+	case "type":
+		if !IsPostType(value) {
+			return fmt.Errorf("%w: type must be %s, %s, %s, %s or %s", ErrBadFilter,
+				PostText, PostLink, PostPoll, PostImage, PostCrosspost)
+		}
+		f.Type = value
+	case "since":
+		f.Since, err = parseFilterTime(key, value)
+	case "until":
+		f.Until, err = parseFilterTime(key, value)
+	case "min_score":
+		n, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return fmt.Errorf("%w: min_score must be a number", ErrBadFilter)
+		}
+		f.MinScore = &n
+	case "min_comments":
+		f.MinComments, err = parseFilterInt(key, value, 0)
+	case "domain":
+		d := strings.TrimPrefix(strings.ToLower(value), "www.")
+		if !domainPattern.MatchString(d) {
+			return fmt.Errorf("%w: bad domain %q", ErrBadFilter, value)
+		}
+		f.Domain = d
+	case "category":
+		f.Category = strings.ToLower(value)
+	case "tag":
+		t, tagErr := normalizeTag(value)
+		if tagErr != nil {
+			return fmt.Errorf("%w: bad tag %q", ErrBadFilter, value)
+		}
+		f.Tag = t
+	case "flair":
+		f.Flair = strings.TrimSpace(value)
+		if f.Flair == "" {
+			return fmt.Errorf("%w: flair must not be empty", ErrBadFilter)
+		}
+	case "sort":
+		switch s := SortOrder(value); s {
+		case SortNew, SortTop, SortHot:
+			f.Sort = s
+		default:
+			return fmt.Errorf("%w: sort must be new, top or hot", ErrBadFilter)
+		}
+	case "fields":
+		f.Fields, err = parseFields(value)
+	case "limit":
+		f.Page.Limit, err = parseFilterInt(key, value, 1)
+		if err == nil && f.Page.Limit > MaxPageLimit {
+			err = fmt.Errorf("%w: limit must be at most %d", ErrBadFilter, MaxPageLimit)
+		}
+	case "offset":
+		f.Page.Offset, err = parseFilterInt(key, value, 0)
+		if err == nil && f.Page.Limit == 0 {
+			f.Page.Limit = DefaultPageLimit
+		}
+	default:
+		return fmt.Errorf("%w: unknown field %q", ErrBadFilter, key)
+	}
+	return err
+}
+
+func parseFilterInt(key, value string, min int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return 0, fmt.Errorf("%w: %s must be a number not less than %d", ErrBadFilter, key, min)
+	}
+	return n, nil
+}
+
+// Accepts RFC 3339 times and YYYY-MM-DD dates.
+func parseFilterTime(key, value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be YYYY-MM-DD or RFC 3339 time", ErrBadFilter, key)
+	}
+	return t, nil
+}
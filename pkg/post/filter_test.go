@@ -0,0 +1,192 @@
+package post
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"crud/pkg/common"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Run("should combine the filters", func(t *testing.T) {
+		params, _ := url.ParseQuery("author=pike&type=link&since=2022-01-01&until=2022-02-01T10:00:00Z" +
+			"&min_score=-3&min_comments=2&domain=WWW.Example.com&sort=top&offset=50")
+		f, err := ParseFilter(params)
+		assert.Nil(t, err)
+
+		minScore := -3
+		assert.Equal(t, &Filter{
+			Author:      "pike",
+			Type:        PostLink,
+			Since:       time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			Until:       time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC),
+			MinScore:    &minScore,
+			MinComments: 2,
+			Domain:      "example.com",
+			Sort:        SortTop,
+			Page:        common.Page{Limit: 25, Offset: 50},
+			Fields:      summaryFields,
+		}, f)
+	})
+
+	t.Run("should parse the category, tag and flair", func(t *testing.T) {
+		params, _ := url.ParseQuery("category=Music&tag=%23Jazz&flair=+Question+")
+		f, err := ParseFilter(params)
+		assert.Nil(t, err)
+		assert.Equal(t, "music", f.Category)
+		assert.Equal(t, "jazz", f.Tag)
+		assert.Equal(t, "Question", f.Flair)
+	})
+
+	t.Run("should list everything without params", func(t *testing.T) {
+		f, err := ParseFilter(url.Values{})
+		assert.Nil(t, err)
+		assert.Equal(t, &Filter{Sort: SortNew, Fields: summaryFields}, f)
+	})
+
+	t.Run("should reject bad filters", func(t *testing.T) {
+		for _, q := range []string{
+			"title=go", "type=video", "since=yesterday", "min_score=high", "min_comments=-1",
+			"domain=localhost", "sort=old", "limit=0", "limit=1000", "author=a&author=b",
+			"since=2022-02-01&until=2022-01-01", "fields=title,password", "tag=two+words", "flair=",
+		} {
+			params, _ := url.ParseQuery(q)
+			_, err := ParseFilter(params)
+			assert.ErrorIs(t, err, ErrBadFilter, q)
+		}
+	})
+}
+
+func TestFilterQuery(t *testing.T) {
+	minScore := 10
+	query := filterQuery(&Filter{Author: "pike", MinScore: &minScore, MinComments: 3, Domain: "example.com"})
+
+	assert.Equal(t, "pike", query["author.username"])
+	assert.Equal(t, bson.M{"$gte": 10}, query["score"])
+	assert.Equal(t, bson.M{"$gte": bson.A{bson.M{"$size": (*visibility)(nil).comments()}, 3}}, query["$expr"])
+	assert.Contains(t, query, "deletedat")
+	assert.NotContains(t, query, "tags")
+
+	domainOr := query["$or"].(bson.A)
+	assert.Equal(t, bson.M{"domain": "example.com"}, domainOr[0])
+	subdomain := regexp.MustCompile(domainOr[1].(bson.M)["domain"].(bson.M)["$regex"].(string))
+	assert.True(t, subdomain.MatchString("blog.example.com"))
+	assert.False(t, subdomain.MatchString("notexample.com"))
+	assert.False(t, subdomain.MatchString("example.com.evil.org"))
+
+	query = filterQuery(&Filter{Category: "music", Tag: "jazz", Flair: "Question"})
+	assert.Equal(t, "music", query["category"])
+	assert.Equal(t, "jazz", query["tags"])
+	assert.Equal(t, "Question", query["flair.text"])
+	assert.NotContains(t, query, "$and")
+
+	vis := &visibility{blocked: map[string]struct{}{"3": {}}}
+	query = filterQuery(&Filter{visibility: vis})
+	assert.Equal(t, vis.query(), query["$and"])
+}
+
+func TestRepoFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockCursor := NewMockIMongoCursor(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+
+	t.Run("should page the new posts in the query", func(t *testing.T) {
+		f := &Filter{Sort: SortNew, Page: common.Page{Limit: 10, Offset: 20}}
+		mockMongoColl.EXPECT().
+			Find(ctx, filterQuery(f), gomock.Any()).
+			Do(func(_ context.Context, _ interface{}, opts ...*options.FindOptions) {
+				assert.Equal(t, int64(20), *opts[0].Skip)
+				assert.Equal(t, int64(10), *opts[0].Limit)
+				assert.Equal(t, bson.D{{Key: "created", Value: -1}}, opts[0].Sort)
+			}).
+			Return(mockCursor, nil)
+		mockCursor.EXPECT().All(ctx, gomock.Any()).Return(nil)
+		mockCursor.EXPECT().Close(ctx).Return(nil)
+
+		_, err := repo.Filter(ctx, f)
+		assert.Nil(t, err)
+	})
+
+	t.Run("should rank the bounded newest posts for hot", func(t *testing.T) {
+		now := time.Now()
+		loaded := []*Post{
+			{Id: "old", Score: 100, Created: now.Add(-48 * time.Hour)},
+			{Id: "new", Score: 10, Created: now},
+			{Id: "newer", Score: 1, Created: now.Add(time.Minute)},
+		}
+		mockMongoColl.EXPECT().
+			Find(ctx, gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, query interface{}, opts ...*options.FindOptions) {
+				since := query.(bson.M)["created"].(bson.M)["$gte"].(time.Time)
+				assert.WithinDuration(t, now.Add(-hotWindow), since, time.Minute)
+				assert.Nil(t, opts[0].Skip)
+				assert.Equal(t, int64(maxHotCandidates), *opts[0].Limit)
+			}).
+			Return(mockCursor, nil)
+		mockCursor.EXPECT().
+			All(ctx, gomock.Any()).
+			SetArg(1, loaded).
+			Return(nil)
+		mockCursor.EXPECT().Close(ctx).Return(nil)
+
+		posts, err := repo.Filter(ctx, &Filter{Sort: SortHot, Page: common.Page{Limit: 2}})
+		assert.Nil(t, err)
+		assert.Equal(t, []PostId{"new", "newer"}, []PostId{posts[0].Id, posts[1].Id})
+	})
+
+	t.Run("should sort the pinned posts first in the query", func(t *testing.T) {
+		mockMongoColl.EXPECT().
+			Find(ctx, gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, _ interface{}, opts ...*options.FindOptions) {
+				assert.Equal(t, bson.D{{Key: "pinned", Value: -1}, {Key: "score", Value: -1}, {Key: "created", Value: -1}}, opts[0].Sort)
+			}).
+			Return(mockCursor, nil)
+		mockCursor.EXPECT().All(ctx, gomock.Any()).Return(nil)
+		mockCursor.EXPECT().Close(ctx).Return(nil)
+
+		_, err := repo.Filter(ctx, &Filter{Sort: SortTop, pinnedFirst: true})
+		assert.Nil(t, err)
+	})
+
+	t.Run("should put the pinned posts first however old when ranking hot", func(t *testing.T) {
+		now := time.Now()
+		pinnedCursor := NewMockIMongoCursor(ctrl)
+		hot := []*Post{{Id: "hot", Score: 100, Created: now}}
+		pinned := []*Post{{Id: "pinned", Pinned: true, Created: now.Add(-30 * 24 * time.Hour)}}
+		gomock.InOrder(
+			mockMongoColl.EXPECT().
+				Find(ctx, gomock.Any(), gomock.Any()).
+				Do(func(_ context.Context, query interface{}, _ ...*options.FindOptions) {
+					assert.Equal(t, bson.M{"$ne": true}, query.(bson.M)["pinned"])
+				}).
+				Return(mockCursor, nil),
+			mockMongoColl.EXPECT().
+				Find(ctx, gomock.Any(), gomock.Any()).
+				Do(func(_ context.Context, query interface{}, _ ...*options.FindOptions) {
+					assert.Equal(t, true, query.(bson.M)["pinned"])
+					assert.NotContains(t, query.(bson.M), "created", "no hot window")
+				}).
+				Return(pinnedCursor, nil),
+		)
+		mockCursor.EXPECT().All(ctx, gomock.Any()).SetArg(1, hot).Return(nil)
+		mockCursor.EXPECT().Close(ctx).Return(nil)
+		pinnedCursor.EXPECT().All(ctx, gomock.Any()).SetArg(1, pinned).Return(nil)
+		pinnedCursor.EXPECT().Close(ctx).Return(nil)
+
+		posts, err := repo.Filter(ctx, &Filter{Category: "golang", Sort: SortHot, pinnedFirst: true})
+		assert.Nil(t, err)
+		assert.Equal(t, []PostId{"pinned", "hot"}, []PostId{posts[0].Id, posts[1].Id})
+	})
+}
@@ -3,14 +3,18 @@ package post
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"crud/pkg/automod"
+	"crud/pkg/category"
 	"crud/pkg/comment"
 	. "crud/pkg/common"
 	"crud/pkg/logger"
+	"crud/pkg/report"
 	"crud/pkg/sessions"
 	"crud/pkg/user"
 	"crud/pkg/voting"
@@ -18,40 +22,160 @@ import (
 
 type IPostRepo interface {
 	GetAll(context.Context) ([]*Post, error)
+	Filter(context.Context, *Filter) ([]*Post, error)
 	GetById(context.Context, PostId) (*Post, error)
+	GetDeletedById(context.Context, PostId) (*Post, error)
+	GetByIds(context.Context, []string) ([]*Post, error)
 	GetCategoryPosts(context.Context, string) ([]*Post, error)
 	GetUserPosts(context.Context, string) ([]*Post, error)
+	GetFeedPosts(ctx context.Context, usernames, categories []string) ([]*Post, error)
+	FindByURL(ctx context.Context, category, url string, since time.Time) (*Post, error)
 
 	Add(context.Context, *Post) (PostId, error)
-	Update(context.Context, *Post) error
 
 	Vote(context.Context, *Post, *voting.Vote) error
 
-	Delete(context.Context, PostId) error
-	DeletePostComment(context.Context, PostId, comment.CommentId) (*Post, error)
+	SetFlags(context.Context, PostId, map[PostFlag]bool) error
+	SetCommentFlags(context.Context, PostId, comment.CommentId, map[PostFlag]bool) error
+	SetSpamLabel(ctx context.Context, id PostId, commentId comment.CommentId, label string) (bool, error)
 
-	AddComment(context.Context, PostId, *user.User, string) (*Post, error)
+	Delete(ctx context.Context, id PostId, deletedBy string) error
+	DeletePostComment(ctx context.Context, id PostId, commentId comment.CommentId, deletedBy string) (*Post, error)
+	Restore(context.Context, PostId) error
+	RestoreComment(context.Context, PostId, comment.CommentId) error
+
+	AddComment(context.Context, PostId, *comment.Comment) (*Post, error)
+
+	EditText(ctx context.Context, id PostId, text, textHTML string, edited time.Time) error
+	EditComment(ctx context.Context, id PostId, commentId comment.CommentId, body, bodyHTML string, edited time.Time) error
+	EditTags(ctx context.Context, id PostId, tags []string, flair *category.Flair) error
+	AddCrossposts(ctx context.Context, id PostId, delta int) error
+	SetCrosspostsRemoved(ctx context.Context, originalId PostId, removed bool) error
+	PopularTags(ctx context.Context, category string, page Page) ([]*TagCount, error)
+
+	GetDrafts(ctx context.Context, authorId string) ([]*Post, error)
+	SetStatus(ctx context.Context, id PostId, from, to PostStatus, now time.Time) (bool, error)
+
+	VotePoll(context.Context, PostId, *PollVote) error
+}
+
+type ISanctionRepo interface {
+	ShadowbannedIds(context.Context) (map[string]struct{}, error)
+}
+
+type IBlockRepo interface {
+	IsBlocked(ctx context.Context, blockerId, userId string) (bool, error)
+	FilteredIds(context.Context, string) (map[string]struct{}, error)
+}
+
+type ICategoryRepo interface {
+	Exists(context.Context, string) (bool, error)
+}
+
+type IModeration interface {
+	CanModerate(ctx context.Context, category string, u *user.User) (bool, error)
+	IsBanned(ctx context.Context, category, userId string) (bool, error)
+	Ban(context.Context, *category.Ban) error
+	Record(context.Context, *category.ModAction) error
+}
+
+type IReportRepo interface {
+	Add(context.Context, *report.Report) error
+	CountOpen(context.Context, report.TargetType, string) (int, error)
+	Queue(context.Context, string, Page) ([]*report.QueueItem, error)
+	GetOpen(context.Context, string, report.TargetType, string) ([]*report.Report, error)
+	Resolve(ctx context.Context, category string, targetType report.TargetType, targetId string,
+		resolution report.Resolution, resolverId string) (int64, error)
+}
+
+// Config holds the tunable behaviour of the post handlers.
+type Config struct {
+	ReportThresholds report.Thresholds
+	// Posts older than this are archived, 0 disables archiving.
+	ArchiveAfter time.Duration
+	// Deleted posts and comments can be restored during this window.
+	RestoreWindow time.Duration
+	// Links submitted to the category again during this window are refused, 0 allows them.
+	DuplicateWindow time.Duration
 }
 
 type PostHandler struct {
-	PostRepo IPostRepo
+	PostRepo   IPostRepo
+	Sanctions  ISanctionRepo
+	Blocks     IBlockRepo
+	Categories ICategoryRepo
+	Moderation IModeration
+	Reports    IReportRepo
+	Automod    IAutomodRepo
+	Spam       ISpamClassifier
+	Searcher   ISearchRepo
+	Feed       *Feed
+	Views      *ViewCounter
+	Marks      IPostMarkRepo
+	Uploads    IUploadRepo
+	Prefs      IPrefsRepo
+	Flair      IFlairRepo
+	Config     Config
 }
 
-func NewPostHandler(postRepo IPostRepo) *PostHandler {
+func NewPostHandler(postRepo IPostRepo, sanctionRepo ISanctionRepo, blockRepo IBlockRepo,
+	categoryRepo ICategoryRepo, moderation IModeration, reportRepo IReportRepo, automodRepo IAutomodRepo,
+	spamClassifier ISpamClassifier, searchRepo ISearchRepo, feed *Feed, views *ViewCounter,
+	markRepo IPostMarkRepo, uploadRepo IUploadRepo, prefsRepo IPrefsRepo,
+	flairRepo IFlairRepo, cfg Config,
+) *PostHandler {
 	return &PostHandler{
-		PostRepo: postRepo,
+		PostRepo:   postRepo,
+		Sanctions:  sanctionRepo,
+		Blocks:     blockRepo,
+		Categories: categoryRepo,
+		Moderation: moderation,
+		Reports:    reportRepo,
+		Automod:    automodRepo,
+		Spam:       spamClassifier,
+		Searcher:   searchRepo,
+		Feed:       feed,
+		Views:      views,
+		Marks:      markRepo,
+		Uploads:    uploadRepo,
+		Prefs:      prefsRepo,
+		Flair:      flairRepo,
+		Config:     cfg,
 	}
 }
 
+// Lists the posts matching the filter, see ParseFilter.
 func (ph PostHandler) List(w http.ResponseWriter, r *http.Request) {
-	posts, err := ph.PostRepo.GetAll(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	filter, err := ParseFilter(r.URL.Query())
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ph.list(w, r, filter)
+}
+
+func (ph PostHandler) list(w http.ResponseWriter, r *http.Request, filter *Filter) {
+	vis, err := ph.listingVisibility(r.Context())
+	if err == nil {
+		err = ph.moderateListing(r.Context(), vis, filter.Category)
+	}
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check posts visibility: %v", err)
+		WriteMsg(w, "failed loading posts", http.StatusInternalServerError)
+		return
+	}
+	filter.visibility = vis
+
+	posts, err := ph.PostRepo.Filter(r.Context(), filter)
 	if err != nil {
 		logger.Log(r.Context()).Errorf("can't load posts from the repo: %v", err)
 		WriteMsg(w, "failed loading posts", http.StatusInternalServerError)
 		return
 	}
 
-	WriteRespJSON(w, posts)
+	WriteRespJSON(w, sparsePosts(vis.listing(posts), filter.Fields))
 }
 
 func (ph *PostHandler) Add(w http.ResponseWriter, r *http.Request) {
@@ -63,6 +187,10 @@ func (ph *PostHandler) Add(w http.ResponseWriter, r *http.Request) {
 		WriteMsg(w, "user not found", http.StatusBadRequest)
 		return
 	}
+	if err := author.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	post := new(Post)
 	err = ParseReqBody(r.Body, post)
@@ -72,18 +200,87 @@ func (ph *PostHandler) Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	categoryExists, err := ph.Categories.Exists(r.Context(), post.Category)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check category %s: %v", post.Category, err)
+		WriteMsg(w, "failed adding post", http.StatusInternalServerError)
+		return
+	}
+	if !categoryExists {
+		WriteMsg(w, "unknown category", http.StatusBadRequest)
+		return
+	}
+	if !ph.checkCategoryBan(w, r, post.Category, author.Id) {
+		return
+	}
+
+	post.resetServerFields()
+	if err := ph.attachCrosspost(r.Context(), post); err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := post.Validate(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ph.checkDuplicate(w, r, post) {
+		return
+	}
+	if post.Flair != nil {
+		if post.Flair, err = ph.resolveFlair(r.Context(), post.Category, post.Flair.Text); err != nil {
+			if errors.Is(err, ErrInvalidPost) {
+				WriteMsg(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Log(r.Context()).Errorf("can't resolve post flair: %v", err)
+			WriteMsg(w, "failed adding post", http.StatusInternalServerError)
+			return
+		}
+	}
+	post.setText(post.Text)
 	post.Created = time.Now()
 	post.Id = PostId(RandStringRunes(12))
 	post.Author = author
+	if err := ph.attachImage(r.Context(), post); err != nil {
+		if errors.Is(err, ErrInvalidPost) {
+			WriteMsg(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Log(r.Context()).Errorf("can't attach image to post: %v", err)
+		WriteMsg(w, "failed adding post", http.StatusInternalServerError)
+		return
+	}
 	post.Votes = make([]*voting.Vote, 0)
 	post.Comments = make([]*comment.Comment, 0)
 
+	verdict := ph.checkAutomod(r, post.Category, &automod.Item{
+		Target:        automod.TargetPost,
+		Title:         post.Title,
+		Text:          post.Text,
+		URL:           post.URL,
+		AuthorCreated: author.Created,
+	})
+	spamScore, isSpam := ph.Spam.Check(post.spamDoc())
+	post.Removed = verdict.remove
+	post.Pending = verdict.pending || isSpam
+
 	_, err = ph.PostRepo.Add(r.Context(), post)
 	if err != nil {
 		logger.Log(r.Context()).Errorf("can't add post to the repo: %v", err)
 		WriteMsg(w, "failed adding post", http.StatusInternalServerError)
 		return
 	}
+	if post.countsAsCrosspost() {
+		ph.countCrosspost(r.Context(), post, 1)
+	}
+	if isSpam {
+		ph.holdSpam(r, post.Category, report.TargetPost, post.Id, string(post.Id), spamScore)
+	}
+	if ph.applyAutomod(r, verdict, post.Category, report.TargetPost, post.Id, string(post.Id), author.Id) {
+		if reloaded, err := ph.PostRepo.GetById(r.Context(), post.Id); err == nil {
+			post = reloaded
+		}
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	WriteRespJSON(w, post)
@@ -115,12 +312,16 @@ func (ph *PostHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ph.PostRepo.Delete(r.Context(), PostId(postId))
+	err = ph.PostRepo.Delete(r.Context(), PostId(postId), authUser.Id)
 	if err != nil {
 		logger.Log(r.Context()).Errorf("can't remove post: %v", err)
 		WriteMsg(w, "removing post failed", http.StatusInternalServerError)
 		return
 	}
+	if post.countsAsCrosspost() {
+		ph.countCrosspost(r.Context(), post, -1)
+	}
+	ph.markCrossposts(r.Context(), post, true)
 
 	WriteMsg(w, "success", http.StatusOK)
 }
@@ -132,7 +333,28 @@ func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	postId := PostId(vars["post_id"])
 	commentId := comment.CommentId(vars["comment_id"])
 
-	postWithoutComment, err := ph.PostRepo.DeletePostComment(r.Context(), postId, commentId)
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	c := findComment(post, commentId)
+	if c == nil || c.DeletedAt != nil {
+		WriteMsg(w, "comment not found", http.StatusNotFound)
+		return
+	}
+	if c.Author == nil || c.Author.Id != authUser.Id {
+		WriteMsg(w, "only the author can remove the comment", http.StatusForbidden)
+		return
+	}
+
+	postWithoutComment, err := ph.PostRepo.DeletePostComment(r.Context(), postId, commentId, authUser.Id)
 	if err != nil {
 		logger.Log(r.Context()).Errorf("can't remove comment %s from post %s: %v", commentId, postId, err)
 		WriteMsg(w, "removing comment failed", http.StatusInternalServerError)
@@ -140,7 +362,7 @@ func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	WriteRespJSON(w, postWithoutComment)
+	ph.writePost(w, r, postWithoutComment)
 }
 
 func (ph *PostHandler) AddComment(w http.ResponseWriter, r *http.Request) {
@@ -163,16 +385,71 @@ func (ph *PostHandler) AddComment(w http.ResponseWriter, r *http.Request) {
 		WriteMsg(w, "not authorized", http.StatusInternalServerError)
 		return
 	}
+	if err := commenter.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
-	postWithComment, err := ph.PostRepo.AddComment(r.Context(), PostId(postId), commenter, c.Comment)
+	post, err := ph.PostRepo.GetById(r.Context(), PostId(postId))
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if !post.IsPublished() {
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	blocked, err := ph.Blocks.IsBlocked(r.Context(), post.Author.Id, commenter.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check if %s is blocked: %v", commenter.Id, err)
+		WriteMsg(w, "failed adding comment", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		WriteMsg(w, "the author of the post has blocked you", http.StatusForbidden)
+		return
+	}
+	if post.Locked {
+		WriteMsg(w, "the post is locked, new comments are not allowed", http.StatusForbidden)
+		return
+	}
+	if !ph.checkCategoryBan(w, r, post.Category, commenter.Id) {
+		return
+	}
+
+	cmt := &comment.Comment{
+		Id:      comment.CommentId(RandStringRunes(12)),
+		Author:  commenter,
+		Created: time.Now(),
+	}
+	cmt.SetBody(c.Comment)
+	verdict := ph.checkAutomod(r, post.Category, &automod.Item{
+		Target:        automod.TargetComment,
+		Text:          cmt.Body,
+		AuthorCreated: commenter.Created,
+	})
+	spamScore, isSpam := ph.Spam.Check(commentSpamDoc(cmt))
+	cmt.Removed = verdict.remove
+	cmt.Pending = verdict.pending || isSpam
+
+	postWithComment, err := ph.PostRepo.AddComment(r.Context(), PostId(postId), cmt)
 	if err != nil {
 		logger.Log(r.Context()).Errorf("can't add comment %s: %v", postId, err)
 		WriteMsg(w, "post not found", http.StatusNotFound)
 		return
 	}
+	if isSpam {
+		ph.holdSpam(r, post.Category, report.TargetComment, post.Id, string(cmt.Id), spamScore)
+	}
+	if ph.applyAutomod(r, verdict, post.Category, report.TargetComment, post.Id, string(cmt.Id), commenter.Id) {
+		if reloaded, err := ph.PostRepo.GetById(r.Context(), post.Id); err == nil {
+			postWithComment = reloaded
+		}
+	}
 
 	w.WriteHeader(http.StatusCreated)
-	WriteRespJSON(w, postWithComment)
+	ph.writePost(w, r, postWithComment)
 }
 
 func (ph *PostHandler) Upvote(w http.ResponseWriter, r *http.Request) {
@@ -199,6 +476,10 @@ func (ph *PostHandler) vote(w http.ResponseWriter, r *http.Request, score voting
 		WriteMsg(w, "not authorized", http.StatusBadRequest)
 		return
 	}
+	if err := voter.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	post, err := ph.PostRepo.GetById(r.Context(), PostId(postId))
 	if err != nil {
@@ -206,6 +487,21 @@ func (ph *PostHandler) vote(w http.ResponseWriter, r *http.Request, score voting
 		WriteMsg(w, "post not found", http.StatusNotFound)
 		return
 	}
+	if !post.IsPublished() {
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if post.Locked {
+		WriteMsg(w, "the post is locked, voting is not allowed", http.StatusForbidden)
+		return
+	}
+	if ph.isArchived(post, time.Now()) {
+		WriteMsg(w, "the post is archived, voting is closed", http.StatusForbidden)
+		return
+	}
+	if !ph.checkCategoryBan(w, r, post.Category, voter.Id) {
+		return
+	}
 
 	v := &voting.Vote{
 		UserId: voter.Id,
@@ -220,7 +516,7 @@ func (ph *PostHandler) vote(w http.ResponseWriter, r *http.Request, score voting
 	}
 
 	w.WriteHeader(http.StatusOK)
-	WriteRespJSON(w, post)
+	ph.writePost(w, r, post)
 }
 
 func (ph PostHandler) Get(w http.ResponseWriter, r *http.Request) {
@@ -235,23 +531,43 @@ func (ph PostHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	vis, err := ph.visibility(r.Context())
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check post visibility: %v", err)
+		WriteMsg(w, "failed loading post", http.StatusInternalServerError)
+		return
+	}
+	if vis.moderator, err = ph.Moderation.CanModerate(r.Context(), post.Category, vis.viewer); err != nil {
+		logger.Log(r.Context()).Errorf("can't check moderator of %s: %v", post.Category, err)
+	}
+	if !vis.post(post) {
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	ph.Views.Record(post.Id, viewerKey(r), time.Now())
+
 	WriteRespJSON(w, post)
 }
 
+// Lists the category posts, the pinned first, narrowed and shaped
+// by the List params like `?tag=`, `?flair=` or `?fields=`.
 func (ph PostHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	vars := mux.Vars(r)
-	category := vars["category"]
-
-	categoryPosts, err := ph.PostRepo.GetCategoryPosts(r.Context(), category)
+	params := r.URL.Query()
+	if params.Has("category") {
+		WriteMsg(w, "category is given in the path", http.StatusBadRequest)
+		return
+	}
+	params.Set("category", vars["category"])
+	filter, err := ParseFilter(params)
 	if err != nil {
-		logger.Log(r.Context()).Errorf("can't load category %s %s: %v", category, err)
-		WriteMsg(w, "failed loading posts for the category", http.StatusInternalServerError)
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	WriteRespJSON(w, categoryPosts)
+	filter.pinnedFirst = true
+	ph.list(w, r, filter)
 }
 
 func (ph PostHandler) GetByUser(w http.ResponseWriter, r *http.Request) {
@@ -267,5 +583,74 @@ func (ph PostHandler) GetByUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteRespJSON(w, userPosts)
+	vis, err := ph.visibility(r.Context())
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check posts visibility: %v", err)
+		WriteMsg(w, "failed loading user posts", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, vis.posts(userPosts))
+}
+
+// Writes the post without the comments hidden from the viewer.
+func (ph *PostHandler) writePost(w http.ResponseWriter, r *http.Request, post *Post) {
+	vis, err := ph.visibility(r.Context())
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check post visibility: %v", err)
+		WriteRespJSON(w, post)
+		return
+	}
+	vis.post(post)
+	WriteRespJSON(w, post)
+}
+
+// Personalized home feed: posts of followed users and subscribed categories.
+func (ph PostHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	viewer, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := ParsePage(r)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := ph.Feed.Get(r.Context(), viewer.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't build feed for user %s: %v", viewer.Id, err)
+		WriteMsg(w, "failed loading feed", http.StatusInternalServerError)
+		return
+	}
+
+	vis, err := ph.visibility(r.Context())
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check posts visibility: %v", err)
+		WriteMsg(w, "failed loading feed", http.StatusInternalServerError)
+		return
+	}
+
+	visible := vis.listing(posts)
+	start, end := page.Bounds(len(visible))
+	WriteRespJSON(w, visible[start:end])
+}
+
+// Writes the error response and returns false if the user is banned in the category.
+func (ph *PostHandler) checkCategoryBan(w http.ResponseWriter, r *http.Request, category, userId string) bool {
+	banned, err := ph.Moderation.IsBanned(r.Context(), category, userId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check ban of user %s in %s: %v", userId, category, err)
+		WriteMsg(w, "failed checking permissions", http.StatusInternalServerError)
+		return false
+	}
+	if banned {
+		WriteMsg(w, "you are banned in "+category, http.StatusForbidden)
+		return false
+	}
+	return true
 }
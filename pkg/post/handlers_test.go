@@ -0,0 +1,141 @@
+package post
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"crud/pkg/category"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/spam"
+	"crud/pkg/user"
+)
+
+type sanctionStub struct{}
+
+func (sanctionStub) ShadowbannedIds(context.Context) (map[string]struct{}, error) {
+	return map[string]struct{}{}, nil
+}
+
+type blockStub struct{}
+
+func (blockStub) IsBlocked(context.Context, string, string) (bool, error) { return false, nil }
+
+func (blockStub) FilteredIds(context.Context, string) (map[string]struct{}, error) {
+	return map[string]struct{}{}, nil
+}
+
+type prefsStub struct{}
+
+func (prefsStub) GetContentPrefs(context.Context, string) (user.ContentPrefs, error) {
+	return user.DefaultContentPrefs(), nil
+}
+
+type marksStub struct {
+	IPostMarkRepo
+}
+
+func (marksStub) HiddenIds(context.Context, string) (map[string]struct{}, error) {
+	return map[string]struct{}{}, nil
+}
+
+type spamStub struct{}
+
+func (spamStub) Check(*spam.Doc) (float64, bool) { return 0, false }
+
+func (spamStub) Learn(context.Context, *spam.Doc, bool) error { return nil }
+
+// Admins and the users in mods moderate every category.
+type moderationStub struct {
+	IModeration
+	mods    map[string]bool
+	actions []*category.ModAction
+}
+
+func (m *moderationStub) CanModerate(_ context.Context, _ string, u *user.User) (bool, error) {
+	return u != nil && (u.Admin || m.mods[u.Id]), nil
+}
+
+func (m *moderationStub) IsBanned(context.Context, string, string) (bool, error) { return false, nil }
+
+func (m *moderationStub) Record(_ context.Context, action *category.ModAction) error {
+	m.actions = append(m.actions, action)
+	return nil
+}
+
+// The handler with the posts of repo and no sanctions, blocks, spam or hidden posts.
+func newTestHandler(repo IPostRepo, mods ...string) (*PostHandler, *moderationStub) {
+	moderation := &moderationStub{mods: map[string]bool{}}
+	for _, id := range mods {
+		moderation.mods[id] = true
+	}
+	return &PostHandler{
+		PostRepo:   repo,
+		Sanctions:  sanctionStub{},
+		Blocks:     blockStub{},
+		Moderation: moderation,
+		Spam:       spamStub{},
+		Marks:      marksStub{},
+		Prefs:      prefsStub{},
+	}, moderation
+}
+
+// The request of the viewer, nil for the anonymous one, with the route vars.
+func newTestRequest(method, target string, vars map[string]string, viewer *user.User, body io.Reader) *http.Request {
+	ctx := context.WithValue(context.Background(), logger.LoggerKey, zap.NewNop().Sugar())
+	if viewer != nil {
+		ctx = context.WithValue(ctx, sessions.SessionKey, viewer)
+	}
+	r := httptest.NewRequest(method, target, body).WithContext(ctx)
+	return mux.SetURLVars(r, vars)
+}
+
+type listRepoStub struct {
+	IPostRepo
+	filter *Filter
+}
+
+func (r *listRepoStub) Filter(_ context.Context, f *Filter) ([]*Post, error) {
+	r.filter = f
+	return []*Post{{Id: "1", Category: f.Category, Pinned: true}}, nil
+}
+
+func TestGetCategory(t *testing.T) {
+	repo := &listRepoStub{}
+	ph, _ := newTestHandler(repo)
+	vars := map[string]string{"category": "golang"}
+
+	t.Run("should list the pinned posts first with or without params", func(t *testing.T) {
+		for _, target := range []string{"/api/posts/golang", "/api/posts/golang?tag=generics&sort=top"} {
+			w := httptest.NewRecorder()
+			ph.GetCategory(w, newTestRequest("GET", target, vars, nil, nil))
+			assert.Equal(t, http.StatusOK, w.Code, target)
+			assert.Equal(t, "golang", repo.filter.Category, target)
+			assert.True(t, repo.filter.pinnedFirst, target)
+			assert.Equal(t, summaryFields, repo.filter.Fields, target)
+		}
+	})
+
+	t.Run("should show the removed posts to the moderators of the category only", func(t *testing.T) {
+		ph, _ := newTestHandler(repo, "mod")
+		for viewer, moderator := range map[string]bool{"mod": true, "1": false} {
+			w := httptest.NewRecorder()
+			ph.GetCategory(w, newTestRequest("GET", "/api/posts/golang", vars, &user.User{Id: viewer}, nil))
+			assert.Equal(t, http.StatusOK, w.Code, viewer)
+			assert.Equal(t, moderator, repo.filter.visibility.moderator, viewer)
+		}
+	})
+
+	t.Run("should reject the category param", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ph.GetCategory(w, newTestRequest("GET", "/api/posts/golang?category=rust", vars, nil, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,30 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"crud/pkg/upload"
+)
+
+type IUploadRepo interface {
+	GetById(context.Context, string) (*upload.Image, error)
+}
+
+// Replaces the image sent by the client with the stored upload,
+// only the author's own uploads can be posted.
+func (ph *PostHandler) attachImage(ctx context.Context, p *Post) error {
+	if p.Type != PostImage {
+		return nil
+	}
+	img, err := ph.Uploads.GetById(ctx, p.Image.Id)
+	if errors.Is(err, upload.ErrNotFound) || (err == nil && img.OwnerId != p.Author.Id) {
+		return fmt.Errorf("%w: unknown image %q", ErrInvalidPost, p.Image.Id)
+	}
+	if err != nil {
+		return fmt.Errorf("post/image: can't load upload: %w", err)
+	}
+	p.Image = img
+	return nil
+}
@@ -0,0 +1,86 @@
+package post
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/category"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+type IPrefsRepo interface {
+	GetContentPrefs(ctx context.Context, userId string) (user.ContentPrefs, error)
+}
+
+var postLabels = map[string]PostFlag{
+	"nsfw":    FlagNSFW,
+	"spoiler": FlagSpoiler,
+}
+
+func (ph *PostHandler) LabelPost(w http.ResponseWriter, r *http.Request) {
+	ph.labelPost(w, r, true)
+}
+
+func (ph *PostHandler) UnlabelPost(w http.ResponseWriter, r *http.Request) {
+	ph.labelPost(w, r, false)
+}
+
+// Sets or clears the `label` of the post, allowed to its author and the category moderators.
+func (ph *PostHandler) labelPost(w http.ResponseWriter, r *http.Request, set bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	postId := PostId(vars["post_id"])
+	flag, ok := postLabels[vars["label"]]
+	if !ok {
+		WriteMsg(w, "unknown label", http.StatusBadRequest)
+		return
+	}
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	isAuthor := post.Author != nil && post.Author.Id == authUser.Id
+	if !isAuthor {
+		if _, ok := ph.categoryModerator(w, r, post.Category); !ok {
+			return
+		}
+	}
+
+	if err := ph.PostRepo.SetFlags(r.Context(), postId, map[PostFlag]bool{flag: set}); err != nil {
+		logger.Log(r.Context()).Errorf("can't label post %s: %v", postId, err)
+		WriteMsg(w, "failed labeling post", http.StatusInternalServerError)
+		return
+	}
+	if !isAuthor {
+		action := category.ActionLabelPost
+		if !set {
+			action = category.ActionUnlabelPost
+		}
+		ph.recordModAction(r, &category.ModAction{
+			Category:   post.Category,
+			ActorId:    authUser.Id,
+			Action:     action,
+			TargetType: category.TargetPost,
+			TargetId:   string(postId),
+			Reason:     string(flag),
+		})
+	}
+
+	if reloaded, err := ph.PostRepo.GetById(r.Context(), postId); err == nil {
+		post = reloaded
+	}
+	ph.writePost(w, r, post)
+}
@@ -0,0 +1,73 @@
+package post
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/category"
+	"crud/pkg/user"
+)
+
+func TestLabelPost(t *testing.T) {
+	author := &user.User{Id: "1"}
+	newRepo := func() *flagsRepoStub {
+		return &flagsRepoStub{flags: map[string]map[PostFlag]bool{}, posts: map[PostId]*Post{
+			"1": {Id: "1", Author: author, Category: "golang"},
+		}}
+	}
+
+	t.Run("should log the label a moderator sets with the label as the reason", func(t *testing.T) {
+		repo := newRepo()
+		ph, moderation := newTestHandler(repo, "mod")
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "label": "nsfw"}
+		ph.LabelPost(w, newTestRequest("POST", "/api/post/1/label/nsfw", vars, &user.User{Id: "mod"}, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[PostFlag]bool{FlagNSFW: true}, repo.flags["1"])
+		assert.Equal(t, []*category.ModAction{{
+			Category: "golang", ActorId: "mod", Action: category.ActionLabelPost,
+			TargetType: category.TargetPost, TargetId: "1", Reason: string(FlagNSFW),
+		}}, moderation.actions)
+	})
+
+	t.Run("should log the label a moderator clears", func(t *testing.T) {
+		repo := newRepo()
+		ph, moderation := newTestHandler(repo, "mod")
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "label": "spoiler"}
+		ph.UnlabelPost(w, newTestRequest("DELETE", "/api/post/1/label/spoiler", vars, &user.User{Id: "mod"}, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[PostFlag]bool{FlagSpoiler: false}, repo.flags["1"])
+		assert.Len(t, moderation.actions, 1)
+		assert.Equal(t, category.ActionUnlabelPost, moderation.actions[0].Action)
+		assert.Equal(t, string(FlagSpoiler), moderation.actions[0].Reason)
+	})
+
+	t.Run("should not log the labels of the author", func(t *testing.T) {
+		repo := newRepo()
+		ph, moderation := newTestHandler(repo)
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "label": "spoiler"}
+		ph.LabelPost(w, newTestRequest("POST", "/api/post/1/label/spoiler", vars, author, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[PostFlag]bool{FlagSpoiler: true}, repo.flags["1"])
+		assert.Empty(t, moderation.actions)
+	})
+
+	t.Run("should reject the flags that aren't labels", func(t *testing.T) {
+		repo := newRepo()
+		ph, _ := newTestHandler(repo)
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "label": "removed"}
+		ph.LabelPost(w, newTestRequest("POST", "/api/post/1/label/removed", vars, author, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, repo.flags)
+	})
+}
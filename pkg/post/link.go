@@ -0,0 +1,122 @@
+package post
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+)
+
+// Query params added by trackers and ad networks, they don't change the linked page.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "gclsrc": true, "msclkid": true, "yclid": true,
+	"igshid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true, "ref_src": true,
+	"mkt_tok": true, "oly_anon_id": true, "oly_enc_id": true, "vero_id": true, "wickedid": true,
+}
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	return trackingParams[key] || strings.HasPrefix(key, "utm_")
+}
+
+// Checks the link is http(s) and brings it to the canonical form: lowercase
+// scheme and host, no default port, fragment and tracking params, sorted query.
+// Returns the link domain without "www." as well.
+func NormalizeURL(raw string) (string, string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", "", fmt.Errorf("%w: bad url", ErrInvalidPost)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("%w: link post needs a valid http(s) url", ErrInvalidPost)
+	}
+	if u.User != nil {
+		return "", "", fmt.Errorf("%w: url can't contain credentials", ErrInvalidPost)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", "", fmt.Errorf("%w: link post needs a valid http(s) url", ErrInvalidPost)
+	}
+
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment, u.RawFragment = "", ""
+
+	query := u.Query()
+	for key := range query {
+		if isTrackingParam(key) {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	return u.String(), strings.TrimPrefix(host, "www."), nil
+}
+
+type duplicateMsg struct {
+	Message     string `json:"message"`
+	DuplicateOf PostId `json:"duplicateOf"`
+}
+
+// Refuses the link already submitted to the category during the duplicate window,
+// unless the author confirms the resubmission with `?resubmit=true`.
+// Returns false when the response is written.
+func (ph *PostHandler) checkDuplicate(w http.ResponseWriter, r *http.Request, p *Post) bool {
+	if p.Type != PostLink || ph.Config.DuplicateWindow <= 0 || r.URL.Query().Get("resubmit") == "true" {
+		return true
+	}
+	dup, err := ph.PostRepo.FindByURL(r.Context(), p.Category, p.URL, time.Now().Add(-ph.Config.DuplicateWindow))
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check duplicate links: %v", err)
+		WriteMsg(w, "failed adding post", http.StatusInternalServerError)
+		return false
+	}
+	if dup == nil {
+		return true
+	}
+	w.WriteHeader(http.StatusConflict)
+	WriteRespJSON(w, duplicateMsg{
+		Message:     "the link was already submitted to " + p.Category + ", add ?resubmit=true to post it anyway",
+		DuplicateOf: dup.Id,
+	})
+	return false
+}
+
+// Lists the link posts to the domain and its subdomains, accepts the ListPosts params.
+func (ph PostHandler) GetDomain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.URL.Query()
+	if params.Has("domain") {
+		WriteMsg(w, "domain is given in the path", http.StatusBadRequest)
+		return
+	}
+	params.Set("domain", mux.Vars(r)["domain"])
+	filter, err := ParseFilter(params)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ph.list(w, r, filter)
+}
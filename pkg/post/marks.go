@@ -0,0 +1,107 @@
+package post
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+type IPostMarkRepo interface {
+	Mark(ctx context.Context, userId, postId string, kind user.PostMarkKind) error
+	Unmark(ctx context.Context, userId, postId string, kind user.PostMarkKind) error
+	GetMarked(ctx context.Context, userId string, kind user.PostMarkKind, page Page) ([]string, error)
+	HiddenIds(context.Context, string) (map[string]struct{}, error)
+}
+
+func (ph *PostHandler) SavePost(w http.ResponseWriter, r *http.Request) {
+	ph.markPost(w, r, user.MarkSaved, true)
+}
+
+func (ph *PostHandler) UnsavePost(w http.ResponseWriter, r *http.Request) {
+	ph.markPost(w, r, user.MarkSaved, false)
+}
+
+func (ph *PostHandler) HidePost(w http.ResponseWriter, r *http.Request) {
+	ph.markPost(w, r, user.MarkHidden, true)
+}
+
+func (ph *PostHandler) UnhidePost(w http.ResponseWriter, r *http.Request) {
+	ph.markPost(w, r, user.MarkHidden, false)
+}
+
+func (ph *PostHandler) markPost(w http.ResponseWriter, r *http.Request, kind user.PostMarkKind, mark bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	postId := mux.Vars(r)["post_id"]
+
+	if !mark {
+		if err := ph.Marks.Unmark(r.Context(), authUser.Id, postId, kind); err != nil {
+			logger.Log(r.Context()).Errorf("can't unmark %s post %s: %v", kind, postId, err)
+			WriteMsg(w, "failed updating the post", http.StatusInternalServerError)
+			return
+		}
+		WriteMsg(w, "success", http.StatusOK)
+		return
+	}
+
+	if _, err := ph.PostRepo.GetById(r.Context(), PostId(postId)); err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err := ph.Marks.Mark(r.Context(), authUser.Id, postId, kind); err != nil {
+		logger.Log(r.Context()).Errorf("can't mark %s post %s: %v", kind, postId, err)
+		WriteMsg(w, "failed updating the post", http.StatusInternalServerError)
+		return
+	}
+	WriteMsg(w, "success", http.StatusOK)
+}
+
+// Posts saved by the current user, the latest saved first, `?limit=&offset=`.
+func (ph *PostHandler) ListSaved(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	page, err := ParsePage(r)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids, err := ph.Marks.GetMarked(r.Context(), authUser.Id, user.MarkSaved, page)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load saved posts of %s: %v", authUser.Id, err)
+		WriteMsg(w, "failed loading saved posts", http.StatusInternalServerError)
+		return
+	}
+	posts, err := ph.PostRepo.GetByIds(r.Context(), ids)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load saved posts of %s: %v", authUser.Id, err)
+		WriteMsg(w, "failed loading saved posts", http.StatusInternalServerError)
+		return
+	}
+
+	vis, err := ph.visibility(r.Context())
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check posts visibility: %v", err)
+		WriteMsg(w, "failed loading saved posts", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, vis.posts(posts))
+}
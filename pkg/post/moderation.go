@@ -0,0 +1,167 @@
+package post
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/category"
+	"crud/pkg/comment"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/user"
+)
+
+type modAction struct {
+	kind  category.ModActionKind
+	flags map[PostFlag]bool
+}
+
+var postModActions = map[string]modAction{
+	"remove": {category.ActionRemovePost, map[PostFlag]bool{FlagRemoved: true, FlagApproved: false}},
+	"approve": {category.ActionApprovePost, map[PostFlag]bool{
+		FlagRemoved: false, FlagApproved: true, FlagHidden: false, FlagPending: false,
+	}},
+	"pin":    {category.ActionPinPost, map[PostFlag]bool{FlagPinned: true}},
+	"unpin":  {category.ActionUnpinPost, map[PostFlag]bool{FlagPinned: false}},
+	"lock":   {category.ActionLockPost, map[PostFlag]bool{FlagLocked: true}},
+	"unlock": {category.ActionUnlockPost, map[PostFlag]bool{FlagLocked: false}},
+}
+
+var commentModActions = map[string]modAction{
+	"remove": {category.ActionRemoveComment, map[PostFlag]bool{FlagRemoved: true, FlagApproved: false}},
+	"approve": {category.ActionApproveComment, map[PostFlag]bool{
+		FlagRemoved: false, FlagApproved: true, FlagHidden: false, FlagPending: false,
+	}},
+}
+
+// Applies the moderator `action` to the post, body: `{"reason": "off-topic"}`.
+func (ph *PostHandler) ModeratePost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	postId := PostId(vars["post_id"])
+	action, ok := postModActions[vars["action"]]
+	if !ok {
+		WriteMsg(w, "unknown moderator action", http.StatusBadRequest)
+		return
+	}
+
+	post, mod, ok := ph.moderatedPost(w, r, postId)
+	if !ok {
+		return
+	}
+	reason, err := parseReason(r)
+	if err != nil {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+
+	if err := ph.PostRepo.SetFlags(r.Context(), postId, action.flags); err != nil {
+		logger.Log(r.Context()).Errorf("can't moderate post %s: %v", postId, err)
+		WriteMsg(w, "moderation failed", http.StatusInternalServerError)
+		return
+	}
+	ph.moderateCrossposts(r.Context(), post, action.flags)
+	ph.recordModAction(r, &category.ModAction{
+		Category:   post.Category,
+		ActorId:    mod.Id,
+		Action:     action.kind,
+		TargetType: category.TargetPost,
+		TargetId:   string(postId),
+		Reason:     reason,
+	})
+	ph.learnFromAction(r, vars["action"], reason, postId, "", post.spamDoc())
+
+	ph.writeModeratedPost(w, r, postId)
+}
+
+// Applies the moderator `action` to the comment, body: `{"reason": "rude"}`.
+func (ph *PostHandler) ModerateComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	postId := PostId(vars["post_id"])
+	commentId := comment.CommentId(vars["comment_id"])
+	action, ok := commentModActions[vars["action"]]
+	if !ok {
+		WriteMsg(w, "unknown moderator action", http.StatusBadRequest)
+		return
+	}
+
+	post, mod, ok := ph.moderatedPost(w, r, postId)
+	if !ok {
+		return
+	}
+	reason, err := parseReason(r)
+	if err != nil {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+
+	if err := ph.PostRepo.SetCommentFlags(r.Context(), postId, commentId, action.flags); err != nil {
+		logger.Log(r.Context()).Errorf("can't moderate comment %s of post %s: %v", commentId, postId, err)
+		WriteMsg(w, "moderation failed", http.StatusInternalServerError)
+		return
+	}
+	ph.recordModAction(r, &category.ModAction{
+		Category:   post.Category,
+		ActorId:    mod.Id,
+		Action:     action.kind,
+		TargetType: category.TargetComment,
+		TargetId:   string(commentId),
+		Reason:     reason,
+	})
+	if c := findComment(post, commentId); c != nil {
+		ph.learnFromAction(r, vars["action"], reason, postId, commentId, commentSpamDoc(c))
+	}
+
+	ph.writeModeratedPost(w, r, postId)
+}
+
+// Loads the post and checks the authenticated user moderates its category,
+// otherwise writes the error response.
+func (ph *PostHandler) moderatedPost(w http.ResponseWriter, r *http.Request, postId PostId) (*Post, *user.User, bool) {
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	mod, ok := ph.categoryModerator(w, r, post.Category)
+	if !ok {
+		return nil, nil, false
+	}
+	return post, mod, true
+}
+
+func (ph *PostHandler) recordModAction(r *http.Request, a *category.ModAction) {
+	if err := ph.Moderation.Record(r.Context(), a); err != nil {
+		logger.Log(r.Context()).Errorf("can't record mod action %s: %v", a.Action, err)
+	}
+}
+
+// Moderators get the post with all the removed content.
+func (ph *PostHandler) writeModeratedPost(w http.ResponseWriter, r *http.Request, postId PostId) {
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	WriteRespJSON(w, post)
+}
+
+// The body with the reason is optional.
+func parseReason(r *http.Request) (string, error) {
+	body := struct {
+		Reason string `json:"reason"`
+	}{}
+	if err := ParseReqBody(r.Body, &body); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return body.Reason, nil
+}
@@ -0,0 +1,187 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/category"
+	"crud/pkg/comment"
+	"crud/pkg/user"
+)
+
+type flagsRepoStub struct {
+	IPostRepo
+	posts map[PostId]*Post
+	flags map[string]map[PostFlag]bool
+	// The crosspost counts added and the crossposts marked by the original.
+	crossposts map[PostId]int
+	marked     map[PostId]bool
+}
+
+func (r *flagsRepoStub) GetById(_ context.Context, id PostId) (*Post, error) {
+	if p, ok := r.posts[id]; ok {
+		return p, nil
+	}
+	return nil, errors.New("post: post not found")
+}
+
+func (r *flagsRepoStub) SetFlags(_ context.Context, id PostId, flags map[PostFlag]bool) error {
+	r.flags[string(id)] = flags
+	return nil
+}
+
+func (r *flagsRepoStub) SetCommentFlags(_ context.Context, id PostId, commentId comment.CommentId, flags map[PostFlag]bool) error {
+	r.flags[string(id)+"/"+string(commentId)] = flags
+	return nil
+}
+
+func (r *flagsRepoStub) AddCrossposts(_ context.Context, id PostId, delta int) error {
+	r.crossposts[id] += delta
+	return nil
+}
+
+func (r *flagsRepoStub) SetCrosspostsRemoved(_ context.Context, id PostId, removed bool) error {
+	r.marked[id] = removed
+	return nil
+}
+
+func (r *flagsRepoStub) SetSpamLabel(context.Context, PostId, comment.CommentId, string) (bool, error) {
+	return false, nil
+}
+
+func newFlagsRepo() *flagsRepoStub {
+	return &flagsRepoStub{
+		flags:      map[string]map[PostFlag]bool{},
+		crossposts: map[PostId]int{},
+		marked:     map[PostId]bool{},
+		posts: map[PostId]*Post{
+			"1": {Id: "1", Author: &user.User{Id: "1"}, Category: "golang", Comments: []*comment.Comment{{Id: "c1"}}},
+		},
+	}
+}
+
+func TestModeratePost(t *testing.T) {
+	mod := &user.User{Id: "mod"}
+
+	t.Run("should set the flags of the action and log it with the reason", func(t *testing.T) {
+		for action, want := range map[string]struct {
+			kind  category.ModActionKind
+			flags map[PostFlag]bool
+		}{
+			"remove": {category.ActionRemovePost, map[PostFlag]bool{FlagRemoved: true, FlagApproved: false}},
+			"approve": {category.ActionApprovePost, map[PostFlag]bool{
+				FlagRemoved: false, FlagApproved: true, FlagHidden: false, FlagPending: false,
+			}},
+			"pin":    {category.ActionPinPost, map[PostFlag]bool{FlagPinned: true}},
+			"unlock": {category.ActionUnlockPost, map[PostFlag]bool{FlagLocked: false}},
+		} {
+			repo := newFlagsRepo()
+			ph, moderation := newTestHandler(repo, "mod")
+
+			w := httptest.NewRecorder()
+			vars := map[string]string{"post_id": "1", "action": action}
+			ph.ModeratePost(w, newTestRequest("POST", "/api/post/1/mod/"+action, vars, mod,
+				strings.NewReader(`{"reason": "off-topic"}`)))
+			assert.Equal(t, http.StatusOK, w.Code, action)
+			assert.Equal(t, want.flags, repo.flags["1"], action)
+			assert.Equal(t, []*category.ModAction{{
+				Category: "golang", ActorId: "mod", Action: want.kind,
+				TargetType: category.TargetPost, TargetId: "1", Reason: "off-topic",
+			}}, moderation.actions, action)
+		}
+	})
+
+	t.Run("should take the removed crosspost out of the count and put the approved one back", func(t *testing.T) {
+		repo := newFlagsRepo()
+		repo.posts["live"] = &Post{Id: "live", Category: "golang", CrosspostOf: &CrosspostRef{Id: "orig"}}
+		repo.posts["removed"] = &Post{Id: "removed", Category: "golang", CrosspostOf: &CrosspostRef{Id: "orig"}, Removed: true}
+		ph, _ := newTestHandler(repo, "mod")
+
+		moderate := func(postId, action string) {
+			w := httptest.NewRecorder()
+			vars := map[string]string{"post_id": postId, "action": action}
+			ph.ModeratePost(w, newTestRequest("POST", "/api/post/"+postId+"/mod/"+action, vars, mod, nil))
+			assert.Equal(t, http.StatusOK, w.Code, postId)
+		}
+		moderate("live", "remove")
+		assert.Equal(t, map[PostId]int{"orig": -1}, repo.crossposts)
+		moderate("removed", "approve")
+		assert.Equal(t, map[PostId]int{"orig": 0}, repo.crossposts)
+
+		repo.posts["live"].Removed = true
+		moderate("live", "remove")
+		assert.Equal(t, map[PostId]int{"orig": 0}, repo.crossposts, "removing again changes nothing")
+	})
+
+	t.Run("should mark the crossposts of the removed original and unmark them on approval", func(t *testing.T) {
+		repo := newFlagsRepo()
+		ph, _ := newTestHandler(repo, "mod")
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "action": "remove"}
+		ph.ModeratePost(w, newTestRequest("POST", "/api/post/1/mod/remove", vars, mod, nil))
+		assert.Equal(t, map[PostId]bool{"1": true}, repo.marked)
+
+		repo.posts["1"].Removed = true
+		w = httptest.NewRecorder()
+		vars = map[string]string{"post_id": "1", "action": "approve"}
+		ph.ModeratePost(w, newTestRequest("POST", "/api/post/1/mod/approve", vars, mod, nil))
+		assert.Equal(t, map[PostId]bool{"1": false}, repo.marked)
+	})
+
+	t.Run("should not need a reason", func(t *testing.T) {
+		repo := newFlagsRepo()
+		ph, moderation := newTestHandler(repo, "mod")
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "action": "lock"}
+		ph.ModeratePost(w, newTestRequest("POST", "/api/post/1/mod/lock", vars, mod, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, moderation.actions[0].Reason)
+	})
+
+	t.Run("should refuse the users not moderating the category", func(t *testing.T) {
+		repo := newFlagsRepo()
+		ph, moderation := newTestHandler(repo, "mod")
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "action": "remove"}
+		ph.ModeratePost(w, newTestRequest("POST", "/api/post/1/mod/remove", vars, &user.User{Id: "1"}, nil))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, repo.flags)
+		assert.Empty(t, moderation.actions)
+	})
+
+	t.Run("should reject unknown actions", func(t *testing.T) {
+		repo := newFlagsRepo()
+		ph, _ := newTestHandler(repo, "mod")
+
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "action": "delete"}
+		ph.ModeratePost(w, newTestRequest("POST", "/api/post/1/mod/delete", vars, mod, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, repo.flags)
+	})
+}
+
+func TestModerateComment(t *testing.T) {
+	repo := newFlagsRepo()
+	ph, moderation := newTestHandler(repo, "mod")
+
+	w := httptest.NewRecorder()
+	vars := map[string]string{"post_id": "1", "comment_id": "c1", "action": "remove"}
+	ph.ModerateComment(w, newTestRequest("POST", "/api/post/1/c1/mod/remove", vars, &user.User{Id: "mod"},
+		strings.NewReader(`{"reason": "rude"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[PostFlag]bool{FlagRemoved: true, FlagApproved: false}, repo.flags["1/c1"])
+	assert.Equal(t, []*category.ModAction{{
+		Category: "golang", ActorId: "mod", Action: category.ActionRemoveComment,
+		TargetType: category.TargetComment, TargetId: "c1", Reason: "rude",
+	}}, moderation.actions)
+}
@@ -15,9 +15,14 @@ type ( // Interfaces
 	IMongoCollection interface {
 		InsertOne(context.Context, interface{}, ...*options.InsertOneOptions) (IMongoInsertOneResult, error)
 		UpdateOne(context.Context, interface{}, interface{}, ...*options.UpdateOptions) (IMongoUpdateResult, error)
+		UpdateMany(context.Context, interface{}, interface{}, ...*options.UpdateOptions) (IMongoUpdateResult, error)
 		DeleteOne(context.Context, interface{}, ...*options.DeleteOptions) (IMongoDeleteResult, error)
+		DeleteMany(context.Context, interface{}, ...*options.DeleteOptions) (IMongoDeleteResult, error)
 		FindOne(context.Context, interface{}, ...*options.FindOneOptions) IMongoSingleResult
 		Find(context.Context, interface{}, ...*options.FindOptions) (IMongoCursor, error)
+		Aggregate(context.Context, interface{}, ...*options.AggregateOptions) (IMongoCursor, error)
+		CreateIndex(context.Context, mongo.IndexModel) (string, error)
+		DropIndex(context.Context, string) error
 		Database() *mongo.Database
 	}
 
@@ -28,7 +33,7 @@ type ( // Interfaces
 
 	IMongoSingleResult    interface{ Decode(interface{}) error }
 	IMongoInsertOneResult interface{}
-	IMongoUpdateResult    interface{}
+	IMongoUpdateResult    interface{ MatchedCount() int64 }
 	IMongoDeleteResult    interface{}
 )
 
@@ -51,6 +56,12 @@ func (sr *MongoSingleResult) Decode(v interface{}) error {
 	return sr.res.Decode(v)
 }
 
+// MongoUpdateResult
+
+func (ur *MongoUpdateResult) MatchedCount() int64 {
+	return ur.res.MatchedCount
+}
+
 // MongoCursor
 
 func (cur *MongoCursor) Close(ctx context.Context) error {
@@ -82,6 +93,14 @@ func (col *MongoCollection) UpdateOne(ctx context.Context, filter interface{}, u
 	return &MongoUpdateResult{res: updateResult}, nil
 }
 
+func (col *MongoCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (IMongoUpdateResult, error) {
+	updateResult, err := col.Coll.UpdateMany(ctx, filter, update, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &MongoUpdateResult{res: updateResult}, nil
+}
+
 func (col *MongoCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (IMongoDeleteResult, error) {
 	deleteResult, err := col.Coll.DeleteOne(ctx, filter, opts...)
 	if err != nil {
@@ -90,6 +109,14 @@ func (col *MongoCollection) DeleteOne(ctx context.Context, filter interface{}, o
 	return &MongoDeleteResult{res: deleteResult}, nil
 }
 
+func (col *MongoCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (IMongoDeleteResult, error) {
+	deleteResult, err := col.Coll.DeleteMany(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &MongoDeleteResult{res: deleteResult}, nil
+}
+
 func (col *MongoCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) IMongoSingleResult {
 	singleResult := col.Coll.FindOne(ctx, filter, opts...)
 	return &MongoSingleResult{res: singleResult}
@@ -99,3 +126,17 @@ func (col *MongoCollection) Find(ctx context.Context, filter interface{}, opts .
 	cursorResult, err := col.Coll.Find(ctx, filter, opts...)
 	return &MongoCursor{cur: cursorResult}, err
 }
+
+func (col *MongoCollection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (IMongoCursor, error) {
+	cursorResult, err := col.Coll.Aggregate(ctx, pipeline, opts...)
+	return &MongoCursor{cur: cursorResult}, err
+}
+
+func (col *MongoCollection) CreateIndex(ctx context.Context, model mongo.IndexModel) (string, error) {
+	return col.Coll.Indexes().CreateOne(ctx, model)
+}
+
+func (col *MongoCollection) DropIndex(ctx context.Context, name string) error {
+	_, err := col.Coll.Indexes().DropOne(ctx, name)
+	return err
+}
@@ -0,0 +1,168 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+)
+
+const (
+	pollMinOptions   = 2
+	pollMaxOptions   = 10
+	pollMaxOptionLen = 100
+)
+
+var ErrAlreadyVoted = errors.New("post: already voted in the poll")
+
+type Poll struct {
+	Options []*PollOption `json:"options"`
+	// nil for the polls open forever.
+	ClosesAt   *time.Time `json:"closesAt,omitempty"`
+	TotalVotes int        `json:"totalVotes"`
+	// Voters are kept private, the viewer gets only their own choice in MyVote.
+	Voters []*PollVote `json:"-"`
+	MyVote *int        `json:"myVote,omitempty" bson:"-"`
+}
+
+type PollOption struct {
+	// Index of the option in the poll.
+	Id    int    `json:"id"`
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+type PollVote struct {
+	UserId   string `json:"userId"`
+	OptionId int    `json:"optionId"`
+}
+
+// Validates the poll from the client and resets its results.
+func (p *Poll) prepare(now time.Time) error {
+	if len(p.Options) < pollMinOptions || len(p.Options) > pollMaxOptions {
+		return fmt.Errorf("%w: poll must have %d to %d options", ErrInvalidPost, pollMinOptions, pollMaxOptions)
+	}
+	seen := map[string]bool{}
+	for i, o := range p.Options {
+		if o == nil {
+			return fmt.Errorf("%w: empty poll option", ErrInvalidPost)
+		}
+		o.Text = strings.TrimSpace(o.Text)
+		if o.Text == "" || len([]rune(o.Text)) > pollMaxOptionLen {
+			return fmt.Errorf("%w: poll option must be 1 to %d characters long", ErrInvalidPost, pollMaxOptionLen)
+		}
+		key := strings.ToLower(o.Text)
+		if seen[key] {
+			return fmt.Errorf("%w: poll options must be unique", ErrInvalidPost)
+		}
+		seen[key] = true
+		o.Id, o.Votes = i, 0
+	}
+	if p.ClosesAt != nil && !p.ClosesAt.After(now) {
+		return fmt.Errorf("%w: poll must close in the future", ErrInvalidPost)
+	}
+	p.TotalVotes, p.Voters, p.MyVote = 0, nil, nil
+	return nil
+}
+
+func (p *Poll) isClosed(now time.Time) bool {
+	return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+}
+
+func (p *Poll) voteOf(userId string) *PollVote {
+	for _, v := range p.Voters {
+		if v.UserId == userId {
+			return v
+		}
+	}
+	return nil
+}
+
+// Sets MyVote for the viewer.
+func (p *Poll) showVoteOf(userId string) {
+	if v := p.voteOf(userId); v != nil {
+		option := v.OptionId
+		p.MyVote = &option
+	}
+}
+
+// Votes for the poll option, body: `{"option": 1}`. Every user votes once.
+func (ph *PostHandler) VotePoll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	voter, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	if err := voter.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	postId := PostId(mux.Vars(r)["post_id"])
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if !post.IsPublished() {
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if post.Type != PostPoll || post.Poll == nil {
+		WriteMsg(w, "the post is not a poll", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if post.Poll.isClosed(now) || post.Locked || ph.isArchived(post, now) {
+		WriteMsg(w, "the poll is closed", http.StatusForbidden)
+		return
+	}
+	if !ph.checkCategoryBan(w, r, post.Category, voter.Id) {
+		return
+	}
+
+	body := struct {
+		Option *int `json:"option"`
+	}{}
+	if err := ParseReqBody(r.Body, &body); err != nil || body.Option == nil {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	if *body.Option < 0 || *body.Option >= len(post.Poll.Options) {
+		WriteMsg(w, "unknown poll option", http.StatusBadRequest)
+		return
+	}
+
+	if post.Poll.voteOf(voter.Id) == nil {
+		err = ph.PostRepo.VotePoll(r.Context(), postId, &PollVote{UserId: voter.Id, OptionId: *body.Option})
+	} else {
+		err = ErrAlreadyVoted
+	}
+	if errors.Is(err, ErrAlreadyVoted) {
+		WriteMsg(w, "you have already voted in this poll", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't vote in poll %s: %v", postId, err)
+		WriteMsg(w, "voting failed", http.StatusInternalServerError)
+		return
+	}
+
+	post, err = ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	ph.writePost(w, r, post)
+}
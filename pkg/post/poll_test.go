@@ -0,0 +1,105 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"crud/pkg/user"
+)
+
+type pollRepoStub struct {
+	IPostRepo
+	posts map[PostId]*Post
+	votes []*PollVote
+	// Users whose vote lands between loading the poll and voting.
+	racing map[string]bool
+}
+
+func (r *pollRepoStub) GetById(_ context.Context, id PostId) (*Post, error) {
+	if p, ok := r.posts[id]; ok {
+		return p, nil
+	}
+	return nil, errors.New("post: post not found")
+}
+
+func (r *pollRepoStub) VotePoll(_ context.Context, _ PostId, vote *PollVote) error {
+	if r.racing[vote.UserId] {
+		return ErrAlreadyVoted
+	}
+	r.votes = append(r.votes, vote)
+	return nil
+}
+
+func TestVotePollHandler(t *testing.T) {
+	repo := &pollRepoStub{racing: map[string]bool{"racing": true}, posts: map[PostId]*Post{
+		"poll": {Id: "poll", Type: PostPoll, Category: "golang", Poll: &Poll{
+			Options: []*PollOption{{Id: 0, Text: "tabs"}, {Id: 1, Text: "spaces"}},
+			Voters:  []*PollVote{{UserId: "voted", OptionId: 0}},
+		}},
+		"text": {Id: "text", Type: PostText},
+	}}
+	ph, _ := newTestHandler(repo)
+
+	t.Run("should refuse a second vote and unknown options", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			postId string
+			voter  string
+			body   string
+			code   int
+		}{
+			"already voted":  {"poll", "voted", `{"option": 1}`, http.StatusConflict},
+			"voted meantime": {"poll", "racing", `{"option": 1}`, http.StatusConflict},
+			"no option":      {"poll", "2", `{}`, http.StatusBadRequest},
+			"unknown option": {"poll", "2", `{"option": 2}`, http.StatusBadRequest},
+			"not a poll":     {"text", "2", `{"option": 0}`, http.StatusBadRequest},
+		} {
+			w := httptest.NewRecorder()
+			r := newTestRequest("POST", "/api/post/"+tc.postId+"/poll", map[string]string{"post_id": tc.postId},
+				&user.User{Id: tc.voter}, strings.NewReader(tc.body))
+			ph.VotePoll(w, r)
+			assert.Equal(t, tc.code, w.Code, name)
+		}
+		assert.Empty(t, repo.votes, "nothing is voted")
+	})
+
+	t.Run("should vote once and keep the voters private", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := newTestRequest("POST", "/api/post/poll/poll", map[string]string{"post_id": "poll"},
+			&user.User{Id: "2"}, strings.NewReader(`{"option": 1}`))
+		ph.VotePoll(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []*PollVote{{UserId: "2", OptionId: 1}}, repo.votes)
+		assert.NotContains(t, w.Body.String(), `"voted"`)
+	})
+}
+
+func TestRepoVotePoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockUpdateResult := NewMockIMongoUpdateResult(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+	vote := &PollVote{UserId: "2", OptionId: 1}
+
+	for matched, want := range map[int64]error{1: nil, 0: ErrAlreadyVoted} {
+		mockMongoColl.EXPECT().
+			UpdateOne(ctx, bson.M{"id": PostId("1"), "poll.voters.userid": bson.M{"$ne": "2"}}, bson.M{
+				"$inc":  bson.M{"poll.options.1.votes": 1, "poll.totalvotes": 1},
+				"$push": bson.M{"poll.voters": vote},
+			}).
+			Return(mockUpdateResult, nil)
+		mockUpdateResult.EXPECT().MatchedCount().Return(matched)
+
+		assert.Equal(t, want, repo.VotePoll(ctx, "1", vote), "the vote is counted only if the user hasn't voted")
+	}
+}
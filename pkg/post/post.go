@@ -1,18 +1,36 @@
 package post
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"crud/pkg/category"
 	"crud/pkg/comment"
+	"crud/pkg/markdown"
+	"crud/pkg/unfurl"
+	"crud/pkg/upload"
 	"crud/pkg/user"
 	"crud/pkg/voting"
 )
 
 const (
-	PostText = "text"
-	PostLink = "link"
+	PostText      = "text"
+	PostLink      = "link"
+	PostPoll      = "poll"
+	PostImage     = "image"
+	PostCrosspost = "crosspost"
+
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+
+	maxTitleLen = 300
 )
 
+var ErrInvalidPost = errors.New("invalid post")
+
 type PostId string
 
 type Post struct {
@@ -22,16 +40,151 @@ type Post struct {
 	Id       PostId             `json:"id"`
 	Title    string             `json:"title"`
 
-	// Types: [text|link].
+	// Types: [text|link|poll|image|crosspost].
 	Type string `json:"type"`
 
-	// Text for type "text", URL for type "link"
+	// Text for type "text" and the optional description of the poll or image,
+	// URL for type "link", Poll for type "poll", Image for type "image".
+	// The client sends only the id of the image uploaded with `POST /api/uploads`.
 	Text string `json:"text"`
-	URL  string `json:"url"`
+	// Rendered Markdown of the text, see setText.
+	TextHTML string        `json:"textHtml"`
+	URL      string        `json:"url"`
+	Poll     *Poll         `json:"poll,omitempty"`
+	Image    *upload.Image `json:"image,omitempty"`
+	// Host of the link post URL without "www.", set by the server.
+	Domain string `json:"domain,omitempty"`
+	// OpenGraph summary of the linked page, set by the Unfurler.
+	Preview *unfurl.Preview `json:"preview,omitempty"`
+	// The original of the crosspost, see attachCrosspost.
+	CrosspostOf *CrosspostRef `json:"crosspostOf,omitempty"`
+	// Number of the live crossposts of the post.
+	Crossposts int `json:"crossposts"`
+
+	// Free-form tags, see normalizeTags.
+	Tags []string `json:"tags"`
+	// One of the category flair, the client sends only its text.
+	Flair *category.Flair `json:"flair,omitempty"`
 
 	Category         string    `json:"category"`
 	Views            int       `json:"views"`
 	Score            int       `json:"score"`
 	UpvotePercentage int       `json:"upvotePercentage"`
 	Created          time.Time `json:"created"`
+
+	// Moderation state, changed by the category moderators.
+	Removed  bool `json:"removed"`
+	Approved bool `json:"approved"`
+	Pinned   bool `json:"pinned"`
+	Locked   bool `json:"locked"`
+	// Old posts are archived by the Archiver, voting on them is closed.
+	Archived bool `json:"archived"`
+	// Set when the post gets too many reports, see report.Thresholds.
+	Collapsed bool `json:"collapsed"`
+	Hidden    bool `json:"hidden"`
+	// Held until a moderator approves it.
+	Pending bool `json:"pending"`
+	// The label the spam classifier learned the post with, see learnSpam.
+	SpamLabel string `json:"-"`
+
+	Edited *time.Time `json:"edited,omitempty"`
+
+	// Statuses: [draft|scheduled|published], the posts stored before it are published.
+	// Only the author sees the drafts and the scheduled posts.
+	Status string `json:"status"`
+	// When the Scheduler publishes the scheduled post.
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+
+	// Content labels, set by the author or the moderators.
+	NSFW    bool `json:"nsfw"`
+	Spoiler bool `json:"spoiler"`
+	// Set for the viewers preferring to blur the labeled content, never stored.
+	Blurred bool `json:"blurred,omitempty" bson:"-"`
+
+	// Set when the post is deleted, see Config.RestoreWindow.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
+
+	// Projected by the listings instead of the comments and votes, never stored.
+	CommentCount int `json:"-" bson:"commentcount,omitempty"`
+	VoteCount    int `json:"-" bson:"votecount,omitempty"`
+}
+
+// Drops the fields set by the server only, e.g. when the post comes from the client.
+func (p *Post) resetServerFields() {
+	p.Views, p.Score, p.UpvotePercentage = 0, 0, 0
+	p.Removed, p.Approved, p.Pinned, p.Locked, p.Archived = false, false, false, false, false
+	p.Collapsed, p.Hidden, p.Pending = false, false, false
+	p.DeletedAt, p.DeletedBy = nil, ""
+	p.Domain, p.Preview = "", nil
+	p.TextHTML, p.Edited = "", nil
+	p.Blurred = false
+	p.Crossposts = 0
+}
+
+// Sets the text and renders it, the only way the text should change.
+func (p *Post) setText(text string) {
+	p.Text = text
+	p.TextHTML = markdown.Render(text)
+}
+
+func (p *Post) IsPublished() bool {
+	return p.Status == "" || p.Status == StatusPublished
+}
+
+func IsPostType(t string) bool {
+	return t == PostText || t == PostLink || t == PostPoll || t == PostImage || t == PostCrosspost
+}
+
+// Checks the post from the client has the fields required by its type.
+func (p *Post) Validate(now time.Time) error {
+	p.Title = strings.TrimSpace(p.Title)
+	if p.Title == "" || len([]rune(p.Title)) > maxTitleLen {
+		return fmt.Errorf("%w: title must be 1 to %d characters long", ErrInvalidPost, maxTitleLen)
+	}
+	if err := p.prepareStatus(now); err != nil {
+		return err
+	}
+	var err error
+	if p.Tags, err = normalizeTags(p.Tags); err != nil {
+		return err
+	}
+	switch p.Type {
+	case PostText:
+		if p.URL != "" || p.Poll != nil || p.Image != nil {
+			return fmt.Errorf("%w: text post can't have url, poll or image", ErrInvalidPost)
+		}
+	case PostCrosspost:
+		if p.Text != "" || p.URL != "" || p.Poll != nil || p.Image != nil {
+			return fmt.Errorf("%w: crosspost can't have text, url, poll or image", ErrInvalidPost)
+		}
+		if p.CrosspostOf == nil || p.CrosspostOf.Id == "" {
+			return fmt.Errorf("%w: crosspost needs the id of the original post", ErrInvalidPost)
+		}
+	case PostLink:
+		if p.Text != "" || p.Poll != nil || p.Image != nil {
+			return fmt.Errorf("%w: link post can't have text, poll or image", ErrInvalidPost)
+		}
+		if p.URL, p.Domain, err = NormalizeURL(p.URL); err != nil {
+			return err
+		}
+	case PostPoll:
+		if p.URL != "" || p.Image != nil {
+			return fmt.Errorf("%w: poll can't have url or image", ErrInvalidPost)
+		}
+		if p.Poll == nil {
+			return fmt.Errorf("%w: poll post needs poll options", ErrInvalidPost)
+		}
+		return p.Poll.prepare(now)
+	case PostImage:
+		if p.URL != "" || p.Poll != nil {
+			return fmt.Errorf("%w: image post can't have url or poll", ErrInvalidPost)
+		}
+		if p.Image == nil || p.Image.Id == "" {
+			return fmt.Errorf("%w: image post needs an uploaded image id", ErrInvalidPost)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPost, p.Type)
+	}
+	return nil
 }
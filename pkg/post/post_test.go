@@ -0,0 +1,87 @@
+package post
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/upload"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	options := func(texts ...string) *Poll {
+		p := &Poll{}
+		for _, t := range texts {
+			p.Options = append(p.Options, &PollOption{Text: t, Votes: 100})
+		}
+		return p
+	}
+
+	t.Run("should accept valid posts of every type", func(t *testing.T) {
+		for _, p := range []*Post{
+			{Type: PostText, Title: "Hello", Text: "world"},
+			{Type: PostText, Title: "Title only"},
+			{Type: PostLink, Title: "Go", URL: "https://go.dev"},
+			{Type: PostPoll, Title: "Tabs?", Poll: options("tabs", "spaces")},
+			{Type: PostImage, Title: "Cat", Text: "my cat", Image: &upload.Image{Id: "abc"}},
+		} {
+			assert.Nil(t, p.Validate(now), p.Title)
+		}
+	})
+
+	t.Run("should reject posts missing the fields of their type", func(t *testing.T) {
+		for _, p := range []*Post{
+			{Type: PostText, Title: " "},
+			{Type: "video", Title: "Cat"},
+			{Type: PostText, Title: "Hi", URL: "https://go.dev"},
+			{Type: PostLink, Title: "Go"},
+			{Type: PostLink, Title: "Go", URL: "javascript:alert(1)"},
+			{Type: PostLink, Title: "Go", URL: "https://go.dev", Text: "and text"},
+			{Type: PostPoll, Title: "Empty"},
+			{Type: PostImage, Title: "No image"},
+			{Type: PostImage, Title: "Image link", URL: "https://go.dev", Image: &upload.Image{Id: "abc"}},
+			{Type: PostText, Title: "Text image", Image: &upload.Image{Id: "abc"}},
+			{Type: PostPoll, Title: "One", Poll: options("yes")},
+			{Type: PostPoll, Title: "Many", Poll: options("1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11")},
+			{Type: PostPoll, Title: "Twice", Poll: options("Yes", "yes")},
+			{Type: PostPoll, Title: "Blank", Poll: options("yes", " ")},
+			{Type: PostPoll, Title: "Closed", Poll: &Poll{Options: options("a", "b").Options, ClosesAt: &past}},
+		} {
+			assert.ErrorIs(t, p.Validate(now), ErrInvalidPost, p.Title)
+		}
+	})
+
+	t.Run("should reset poll results", func(t *testing.T) {
+		p := &Post{Type: PostPoll, Title: "Tabs?", Poll: options("tabs", "spaces")}
+		p.Poll.ClosesAt = &future
+		p.Poll.TotalVotes = 5
+		p.Poll.Voters = []*PollVote{{UserId: "1"}}
+		assert.Nil(t, p.Validate(now))
+		assert.Equal(t, &PollOption{Id: 1, Text: "spaces"}, p.Poll.Options[1])
+		assert.Equal(t, 0, p.Poll.TotalVotes)
+		assert.Nil(t, p.Poll.Voters)
+	})
+}
+
+func TestPollVoteOf(t *testing.T) {
+	closes := time.Now()
+	p := &Poll{ClosesAt: &closes, Voters: []*PollVote{{UserId: "1", OptionId: 2}}}
+
+	assert.True(t, p.isClosed(closes))
+	assert.False(t, p.isClosed(closes.Add(-time.Second)))
+
+	p.showVoteOf("2")
+	assert.Nil(t, p.MyVote)
+	p.showVoteOf("1")
+	assert.Equal(t, 2, *p.MyVote)
+}
+
+func TestSetText(t *testing.T) {
+	p := &Post{}
+	p.setText("**hi** <script>")
+	assert.Equal(t, "**hi** <script>", p.Text)
+	assert.Equal(t, "<p><strong>hi</strong> &lt;script&gt;</p>\n", p.TextHTML)
+}
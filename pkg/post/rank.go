@@ -0,0 +1,37 @@
+package post
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Posts created before this time don't get any freshness bonus.
+var rankEpoch = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// The hot listing ranks at most maxHotCandidates newest posts of the last hotWindow:
+// a day of freshness is worth about 80 times more votes, so the older posts rarely make it.
+const (
+	hotWindow        = 7 * 24 * time.Hour
+	maxHotCandidates = 1000
+)
+
+// Reddit-like "hot" rank: score weighs logarithmically and every
+// 12.5 hours of freshness are worth 10 times more votes.
+func hotRank(p *Post) float64 {
+	order := math.Log10(math.Max(math.Abs(float64(p.Score)), 1))
+	var sign float64
+	if p.Score > 0 {
+		sign = 1
+	} else if p.Score < 0 {
+		sign = -1
+	}
+	age := p.Created.Sub(rankEpoch).Seconds()
+	return sign*order + age/45000
+}
+
+func sortHot(posts []*Post) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		return hotRank(posts[i]) > hotRank(posts[j])
+	})
+}
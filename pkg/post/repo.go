@@ -2,19 +2,56 @@ package post
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
+	"crud/pkg/category"
 	"crud/pkg/comment"
 	"crud/pkg/common"
-	"crud/pkg/logger"
-	"crud/pkg/user"
+	"crud/pkg/markdown"
+	"crud/pkg/unfurl"
 	"crud/pkg/voting"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// PostFlag is a boolean moderation field of the post or comment.
+type PostFlag string
+
+const (
+	FlagRemoved  PostFlag = "removed"
+	FlagApproved PostFlag = "approved"
+	FlagPinned   PostFlag = "pinned"
+	FlagLocked   PostFlag = "locked"
+	FlagArchived PostFlag = "archived"
+	// Flags set by the reports, see report.Thresholds.
+	FlagCollapsed PostFlag = "collapsed"
+	FlagHidden    PostFlag = "hidden"
+	// Set by AutoModerator, see automod.ActionRequireApproval.
+	FlagPending PostFlag = "pending"
+	// Content labels set by the authors and moderators.
+	FlagNSFW    PostFlag = "nsfw"
+	FlagSpoiler PostFlag = "spoiler"
+)
+
+// Matches the published posts, the drafts and the scheduled posts are only listed by GetDrafts.
+var published = bson.M{"$nin": bson.A{StatusDraft, StatusScheduled}}
+
+// Sets the searchtext indexed by Search to the bodies of the comments everyone sees,
+// so the deleted, removed, hidden and pending comments aren't found.
+var searchTextUpdate = bson.A{bson.M{"$set": bson.M{"searchtext": bson.M{"$reduce": bson.M{
+	"input":        (*visibility)(nil).comments(),
+	"initialValue": "",
+	"in":           bson.M{"$concat": bson.A{"$$value", " ", bson.M{"$ifNull": bson.A{"$$this.body", ""}}}},
+}}}}}
+
+// The server error code of dropping a missing index.
+const indexNotFound = 27
+
 type Repo struct {
 	posts IMongoCollection
 }
@@ -36,33 +73,537 @@ func (r *Repo) Add(ctx context.Context, p *Post) (PostId, error) {
 	return PostId(p.Id), nil
 }
 
-func (r *Repo) Update(ctx context.Context, p *Post) error {
-	_, err := r.posts.UpdateOne(ctx, bson.M{"id": p.Id}, bson.M{"$set": p})
+// Marks the post deleted, it's removed permanently by Purge.
+func (r *Repo) Delete(ctx context.Context, id PostId, deletedBy string) error {
+	set := bson.M{"deletedat": time.Now(), "deletedby": deletedBy}
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": set})
 	if err != nil {
-		return fmt.Errorf("post/repo: failed updating post: %w", err)
+		return fmt.Errorf("post/repo: failed deleting post: %w", err)
 	}
 	return nil
 }
 
-func (r *Repo) Delete(ctx context.Context, id PostId) error {
-	_, err := r.posts.DeleteOne(ctx, bson.M{"id": id})
+func (r *Repo) Restore(ctx context.Context, id PostId) error {
+	unset := bson.M{"deletedat": "", "deletedby": ""}
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$unset": unset})
 	if err != nil {
-		return fmt.Errorf("post/repo: failed deleting post: %w", err)
+		return fmt.Errorf("post/repo: failed restoring post: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) RestoreComment(ctx context.Context, id PostId, commentId comment.CommentId) error {
+	unset := bson.M{"comments.$.deletedat": "", "comments.$.deletedby": ""}
+	filter := bson.M{"id": id, "comments.id": commentId}
+	_, err := r.posts.UpdateOne(ctx, filter, bson.M{"$unset": unset})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed restoring comment: %w", err)
+	}
+	return r.refreshSearchText(ctx, id)
+}
+
+// Permanently removes the posts and comments deleted before the time.
+func (r *Repo) Purge(ctx context.Context, before time.Time) error {
+	expired := bson.M{"$lt": before}
+	_, err := r.posts.DeleteMany(ctx, bson.M{"deletedat": expired})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed purging posts: %w", err)
+	}
+	_, err = r.posts.UpdateMany(ctx, bson.M{"comments.deletedat": expired},
+		bson.M{"$pull": bson.M{"comments": bson.M{"deletedat": expired}}})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed purging comments: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) SetFlags(ctx context.Context, id PostId, flags map[PostFlag]bool) error {
+	set := bson.M{}
+	for f, v := range flags {
+		set[string(f)] = v
+	}
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed setting post flags: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) SetCommentFlags(ctx context.Context, id PostId, commentId comment.CommentId, flags map[PostFlag]bool) error {
+	set := bson.M{}
+	for f, v := range flags {
+		set["comments.$."+string(f)] = v
+	}
+	filter := bson.M{"id": id, "comments.id": commentId}
+	_, err := r.posts.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed setting comment flags: %w", err)
+	}
+	return r.refreshSearchText(ctx, id)
+}
+
+// Labels the post or, for the non-empty commentId, its comment for the spam classifier
+// unless it is labeled already. Reports if the content was labeled by this call.
+func (r *Repo) SetSpamLabel(ctx context.Context, id PostId, commentId comment.CommentId, label string) (bool, error) {
+	unlabeled := bson.M{"$in": bson.A{nil, ""}}
+	filter := bson.M{"id": id, "spamlabel": unlabeled}
+	set := bson.M{"spamlabel": label}
+	if commentId != "" {
+		filter = bson.M{"id": id, "comments": bson.M{"$elemMatch": bson.M{"id": commentId, "spamlabel": unlabeled}}}
+		set = bson.M{"comments.$.spamlabel": label}
+	}
+	res, err := r.posts.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return false, fmt.Errorf("post/repo: failed setting spam label: %w", err)
+	}
+	return res.MatchedCount() == 1, nil
+}
+
+// Replaces the post text and its HTML.
+func (r *Repo) EditText(ctx context.Context, id PostId, text, textHTML string, edited time.Time) error {
+	set := bson.M{"text": text, "texthtml": textHTML, "edited": edited}
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed editing post: %w", err)
+	}
+	return nil
+}
+
+// Replaces the comment body and its HTML.
+func (r *Repo) EditComment(ctx context.Context, id PostId, commentId comment.CommentId,
+	body, bodyHTML string, edited time.Time,
+) error {
+	set := bson.M{"comments.$.body": body, "comments.$.bodyhtml": bodyHTML, "comments.$.edited": edited}
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id, "comments.id": commentId}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed editing comment: %w", err)
+	}
+	return r.refreshSearchText(ctx, id)
+}
+
+// Replaces the post tags and flair.
+func (r *Repo) EditTags(ctx context.Context, id PostId, tags []string, flair *category.Flair) error {
+	set := bson.M{"tags": tags, "flair": flair}
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed editing post tags: %w", err)
+	}
+	return nil
+}
+
+// Changes the number of the crossposts of the post.
+func (r *Repo) AddCrossposts(ctx context.Context, id PostId, delta int) error {
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$inc": bson.M{"crossposts": delta}})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed counting crossposts: %w", err)
+	}
+	return nil
+}
+
+// Marks the references to the original in its crossposts removed or not.
+func (r *Repo) SetCrosspostsRemoved(ctx context.Context, originalId PostId, removed bool) error {
+	_, err := r.posts.UpdateMany(ctx,
+		bson.M{"crosspostof.id": originalId}, bson.M{"$set": bson.M{"crosspostof.removed": removed}})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed marking crossposts: %w", err)
 	}
 	return nil
 }
 
+// Deleted posts are only found by GetDeletedById.
 func (r *Repo) GetById(ctx context.Context, id PostId) (*Post, error) {
 	post := new(Post)
-	err := r.posts.FindOne(ctx, bson.M{"id": id}).Decode(post)
+	err := r.posts.FindOne(ctx, bson.M{"id": id, "deletedat": nil}).Decode(post)
 	if err != nil {
 		return nil, fmt.Errorf("post: post not found: %w", err)
 	}
 	return post, nil
 }
 
+func (r *Repo) GetDeletedById(ctx context.Context, id PostId) (*Post, error) {
+	post := new(Post)
+	err := r.posts.FindOne(ctx, bson.M{"id": id, "deletedat": bson.M{"$ne": nil}}).Decode(post)
+	if err != nil {
+		return nil, fmt.Errorf("post: deleted post not found: %w", err)
+	}
+	return post, nil
+}
+
+// Returns the posts in the order of the ids, missing and deleted posts are skipped.
+func (r *Repo) GetByIds(ctx context.Context, ids []string) ([]*Post, error) {
+	if len(ids) == 0 {
+		return []*Post{}, nil
+	}
+	cursor, err := r.posts.Find(ctx, bson.M{"id": bson.M{"$in": ids}, "deletedat": nil})
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	found := []*Post{}
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	byId := make(map[PostId]*Post, len(found))
+	for _, p := range found {
+		byId[p.Id] = p
+	}
+	posts := make([]*Post, 0, len(found))
+	for _, id := range ids {
+		if p, ok := byId[PostId(id)]; ok {
+			posts = append(posts, p)
+		}
+	}
+	return posts, nil
+}
+
 func (r *Repo) GetAll(ctx context.Context) ([]*Post, error) {
-	cursor, err := r.posts.Find(ctx, bson.M{})
+	cursor, err := r.posts.Find(ctx, bson.M{"deletedat": nil, "status": published})
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	posts := []*Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	return posts, nil
+}
+
+// Adds the vote to the poll unless the user has already voted.
+func (r *Repo) VotePoll(ctx context.Context, id PostId, vote *PollVote) error {
+	filter := bson.M{"id": id, "poll.voters.userid": bson.M{"$ne": vote.UserId}}
+	update := bson.M{
+		"$inc":  bson.M{fmt.Sprintf("poll.options.%d.votes", vote.OptionId): 1, "poll.totalvotes": 1},
+		"$push": bson.M{"poll.voters": vote},
+	}
+	res, err := r.posts.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("post/repo: failed voting in poll: %w", err)
+	}
+	if res.MatchedCount() == 0 {
+		return ErrAlreadyVoted
+	}
+	return nil
+}
+
+// Archives the posts created before the time.
+func (r *Repo) ArchiveBefore(ctx context.Context, before time.Time) error {
+	filter := bson.M{"created": bson.M{"$lt": before}, "archived": bson.M{"$ne": true}, "status": published}
+	_, err := r.posts.UpdateMany(ctx, filter, bson.M{"$set": bson.M{string(FlagArchived): true}})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed archiving posts: %w", err)
+	}
+	return nil
+}
+
+// Returns the posts matching the filter, sorted and paged.
+func (r *Repo) Filter(ctx context.Context, f *Filter) ([]*Post, error) {
+	opts := options.Find()
+	if len(f.Fields) > 0 {
+		opts.SetProjection(fieldsProjection(f.Fields, f.visibility))
+	}
+	sort := bson.D{}
+	if f.pinnedFirst {
+		sort = append(sort, bson.E{Key: "pinned", Value: -1})
+	}
+	var pinnedQuery bson.M
+	switch f.Sort {
+	case SortNew:
+		opts.SetSort(append(sort, bson.E{Key: "created", Value: -1}))
+	case SortTop:
+		opts.SetSort(append(sort, bson.E{Key: "score", Value: -1}, bson.E{Key: "created", Value: -1}))
+	case SortHot:
+		// The pinned posts stay on top however old, so they are loaded apart from the hot window.
+		if f.pinnedFirst {
+			pinnedQuery = filterQuery(f)
+			pinnedQuery["pinned"] = true
+		}
+		// Hot rank changes with time, so the newest posts are ranked and paged after loading.
+		hot := *f
+		if since := time.Now().Add(-hotWindow); hot.Since.Before(since) {
+			hot.Since = since
+		}
+		f = &hot
+		opts.SetSort(bson.D{{Key: "created", Value: -1}}).SetLimit(maxHotCandidates)
+	}
+	if f.Sort != SortHot && f.Page.Limit > 0 {
+		opts.SetSkip(int64(f.Page.Offset)).SetLimit(int64(f.Page.Limit))
+	}
+
+	query := filterQuery(f)
+	if pinnedQuery != nil {
+		query["pinned"] = bson.M{"$ne": true}
+	}
+	posts, err := r.findPosts(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if f.Sort == SortHot {
+		sortHot(posts)
+		if pinnedQuery != nil {
+			pinnedOpts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}})
+			if opts.Projection != nil {
+				pinnedOpts.SetProjection(opts.Projection)
+			}
+			pinned, err := r.findPosts(ctx, pinnedQuery, pinnedOpts)
+			if err != nil {
+				return nil, err
+			}
+			posts = append(pinned, posts...)
+		}
+		if f.Page.Limit > 0 {
+			start, end := f.Page.Bounds(len(posts))
+			posts = posts[start:end]
+		}
+	}
+	return posts, nil
+}
+
+func (r *Repo) findPosts(ctx context.Context, query bson.M, opts *options.FindOptions) ([]*Post, error) {
+	cursor, err := r.posts.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	posts := []*Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	return posts, nil
+}
+
+func filterQuery(f *Filter) bson.M {
+	query := bson.M{"deletedat": nil, "status": published}
+	if f.Author != "" {
+		query["author.username"] = f.Author
+	}
+	if f.Type != "" {
+		query["type"] = f.Type
+	}
+	created := bson.M{}
+	if !f.Since.IsZero() {
+		created["$gte"] = f.Since
+	}
+	if !f.Until.IsZero() {
+		created["$lt"] = f.Until
+	}
+	if len(created) > 0 {
+		query["created"] = created
+	}
+	if f.MinScore != nil {
+		query["score"] = bson.M{"$gte": *f.MinScore}
+	}
+	if f.MinComments > 0 {
+		// Counted like commentCount, the comments the viewer would see.
+		query["$expr"] = bson.M{"$gte": bson.A{bson.M{"$size": f.visibility.comments()}, f.MinComments}}
+	}
+	if f.Category != "" {
+		query["category"] = f.Category
+	}
+	if f.Tag != "" {
+		query["tags"] = f.Tag
+	}
+	if f.Flair != "" {
+		query["flair.text"] = f.Flair
+	}
+	if f.Domain != "" {
+		query["$or"] = bson.A{
+			bson.M{"domain": f.Domain},
+			bson.M{"domain": bson.M{"$regex": `\.` + regexp.QuoteMeta(f.Domain) + `$`}},
+		}
+	}
+	if visible := f.visibility.query(); len(visible) > 0 {
+		query["$and"] = visible
+	}
+	return query
+}
+
+// Creates the text index used by Search.
+func (r *Repo) EnsureIndexes(ctx context.Context) error {
+	// The search index of the older versions had every comment body, a collection has one text index.
+	var cmdErr mongo.CommandError
+	if err := r.posts.DropIndex(ctx, "search"); err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == indexNotFound) {
+		return fmt.Errorf("post/repo: failed dropping old search index: %w", err)
+	}
+	_, err := r.posts.CreateIndex(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "text", Value: "text"},
+			{Key: "searchtext", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("searchtext").
+			SetWeights(bson.M{"title": 5, "text": 2, "searchtext": 1}),
+	})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed creating search index: %w", err)
+	}
+	_, err = r.posts.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "category", Value: 1}, {Key: "url", Value: 1}, {Key: "created", Value: -1}},
+		Options: options.Index().SetName("duplicates"),
+	})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed creating duplicates index: %w", err)
+	}
+	_, err = r.posts.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "domain", Value: 1}, {Key: "created", Value: -1}},
+		Options: options.Index().SetName("domain"),
+	})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed creating domain index: %w", err)
+	}
+	_, err = r.posts.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tags", Value: 1}, {Key: "created", Value: -1}},
+		Options: options.Index().SetName("tags"),
+	})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed creating tags index: %w", err)
+	}
+	_, err = r.posts.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "category", Value: 1}, {Key: "flair.text", Value: 1}, {Key: "created", Value: -1}},
+		Options: options.Index().SetName("flair"),
+	})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed creating flair index: %w", err)
+	}
+	_, err = r.posts.CreateIndex(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "publishat", Value: 1}},
+		Options: options.Index().
+			SetName("scheduled").
+			SetPartialFilterExpression(bson.M{"status": StatusScheduled}),
+	})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed creating scheduled index: %w", err)
+	}
+	_, err = r.posts.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "crosspostof.id", Value: 1}},
+		Options: options.Index().SetName("crossposts").SetSparse(true),
+	})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed creating crossposts index: %w", err)
+	}
+	return nil
+}
+
+// Counts the tags of the visible category posts, the most used first.
+func (r *Repo) PopularTags(ctx context.Context, category string, page common.Page) ([]*TagCount, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"category":  category,
+			"tags":      bson.M{"$exists": true, "$ne": bson.A{}},
+			"deletedat": nil,
+			"status":    published,
+			"removed":   bson.M{"$ne": true},
+			"hidden":    bson.M{"$ne": true},
+			"pending":   bson.M{"$ne": true},
+		}},
+		bson.M{"$unwind": "$tags"},
+		bson.M{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$skip": page.Offset},
+		bson.M{"$limit": page.Limit},
+		bson.M{"$project": bson.M{"_id": 0, "tag": "$_id", "count": 1}},
+	}
+	cursor, err := r.posts.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed counting tags: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	tags := []*TagCount{}
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting tags from cursor: %w", err)
+	}
+	return tags, nil
+}
+
+// Returns the drafts and the scheduled posts of the author, the latest first.
+func (r *Repo) GetDrafts(ctx context.Context, authorId string) ([]*Post, error) {
+	filter := bson.M{
+		"author.id": authorId,
+		"status":    bson.M{"$in": bson.A{StatusDraft, StatusScheduled}},
+		"deletedat": nil,
+	}
+	cursor, err := r.posts.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding drafts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	posts := []*Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	return posts, nil
+}
+
+// Returns the scheduled posts which are due, the earliest first.
+func (r *Repo) GetDue(ctx context.Context, now time.Time, limit int) ([]*Post, error) {
+	filter := bson.M{"status": StatusScheduled, "publishat": bson.M{"$lte": now}, "deletedat": nil}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "publishat", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"id": 1, "status": 1, "publishat": 1, "crosspostof.id": 1})
+	cursor, err := r.posts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding due posts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	posts := []*Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	return posts, nil
+}
+
+// Changes the post status if it's still `from`, scheduled at the same time, and reports
+// if it did. The published posts are created at the time, so they are listed as the new ones.
+func (r *Repo) SetStatus(ctx context.Context, id PostId, from, to PostStatus, now time.Time) (bool, error) {
+	update := bson.M{"$set": bson.M{"status": to.Status, "publishat": to.PublishAt}}
+	if to.Status == StatusPublished {
+		update = bson.M{
+			"$set":   bson.M{"status": to.Status, "created": now},
+			"$unset": bson.M{"publishat": ""},
+		}
+	}
+	filter := bson.M{"id": id, "status": from.Status, "publishat": from.PublishAt, "deletedat": nil}
+	res, err := r.posts.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("post/repo: failed changing post status: %w", err)
+	}
+	return res.MatchedCount() == 1, nil
+}
+
+// Returns the latest live post linking the url in the category since the time, nil if there is none.
+func (r *Repo) FindByURL(ctx context.Context, category, url string, since time.Time) (*Post, error) {
+	filter := bson.M{
+		"category":  category,
+		"url":       url,
+		"created":   bson.M{"$gte": since},
+		"deletedat": nil,
+		"status":    published,
+		"removed":   bson.M{"$ne": true},
+	}
+	post := new(Post)
+	err := r.posts.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "created", Value: -1}})).Decode(post)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding post by url: %w", err)
+	}
+	return post, nil
+}
+
+// Returns the newest link posts without previews.
+func (r *Repo) GetUnfurlPending(ctx context.Context, limit int) ([]*Post, error) {
+	filter := bson.M{"type": PostLink, "preview": nil, "deletedat": nil}
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.posts.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
 	}
@@ -75,19 +616,196 @@ func (r *Repo) GetAll(ctx context.Context) ([]*Post, error) {
 	return posts, nil
 }
 
-func (r *Repo) AddComment(ctx context.Context, postId PostId, commenter *user.User, commentText string) (*Post, error) {
-	cmt := new(comment.Comment)
-	cmt.Id = comment.CommentId(common.RandStringRunes(12))
-	cmt.Author = commenter
-	cmt.Created = time.Now()
-	cmt.Body = commentText
+func (r *Repo) SetPreview(ctx context.Context, id PostId, preview *unfurl.Preview) error {
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"preview": preview}})
+	if err != nil {
+		return fmt.Errorf("post/repo: failed setting preview: %w", err)
+	}
+	return nil
+}
+
+// How many posts the backfills load at once.
+const backfillBatch = 500
+
+// Calls fn with the posts matching the filter, a batch at a time ordered by id,
+// so the whole collection is never loaded at once.
+func (r *Repo) eachBatch(ctx context.Context, filter bson.M, fn func([]*Post) error) error {
+	last := PostId("")
+	for {
+		page := bson.M{"$and": bson.A{filter, bson.M{"id": bson.M{"$gt": last}}}}
+		opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetLimit(backfillBatch)
+		cursor, err := r.posts.Find(ctx, page, opts)
+		if err != nil {
+			return fmt.Errorf("post/repo: failed finding posts: %w", err)
+		}
+		posts := []*Post{}
+		err = cursor.All(ctx, &posts)
+		cursor.Close(ctx)
+		if err != nil {
+			return fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+		}
+		if len(posts) == 0 {
+			return nil
+		}
+		if err := fn(posts); err != nil {
+			return err
+		}
+		if len(posts) < backfillBatch {
+			return nil
+		}
+		last = posts[len(posts)-1].Id
+	}
+}
+
+// Renders the texts and comments stored before they had the HTML.
+func (r *Repo) BackfillHTML(ctx context.Context) (int, error) {
+	updated := 0
+	filter := bson.M{"$or": bson.A{
+		bson.M{"text": bson.M{"$nin": bson.A{nil, ""}}, "texthtml": bson.M{"$exists": false}},
+		bson.M{"comments": bson.M{"$elemMatch": bson.M{"bodyhtml": bson.M{"$exists": false}}}},
+	}}
+	err := r.eachBatch(ctx, filter, func(posts []*Post) error {
+		for _, p := range posts {
+			set := bson.M{"texthtml": markdown.Render(p.Text)}
+			for i, c := range p.Comments {
+				set[fmt.Sprintf("comments.%d.bodyhtml", i)] = markdown.Render(c.Body)
+			}
+			if _, err := r.posts.UpdateOne(ctx, bson.M{"id": p.Id}, bson.M{"$set": set}); err != nil {
+				return fmt.Errorf("post/repo: failed updating post html: %w", err)
+			}
+			updated++
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// Sets the normalized url and domain of the link posts stored before they were recorded.
+func (r *Repo) BackfillDomains(ctx context.Context) (int, error) {
+	updated := 0
+	filter := bson.M{"type": PostLink, "domain": bson.M{"$in": bson.A{nil, ""}}}
+	err := r.eachBatch(ctx, filter, func(posts []*Post) error {
+		for _, p := range posts {
+			normalized, domain, err := NormalizeURL(p.URL)
+			if err != nil {
+				continue
+			}
+			update := bson.M{"$set": bson.M{"url": normalized, "domain": domain}}
+			if _, err := r.posts.UpdateOne(ctx, bson.M{"id": p.Id}, update); err != nil {
+				return fmt.Errorf("post/repo: failed updating post domain: %w", err)
+			}
+			updated++
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// Sets the searchtext of the posts without it, returns the number of posts updated.
+func (r *Repo) BackfillSearchText(ctx context.Context) (int, error) {
+	res, err := r.posts.UpdateMany(ctx, bson.M{"searchtext": bson.M{"$exists": false}}, searchTextUpdate)
+	if err != nil {
+		return 0, fmt.Errorf("post/repo: failed backfilling search text: %w", err)
+	}
+	return int(res.MatchedCount()), nil
+}
+
+// Reindexes the post comments for Search after they changed.
+func (r *Repo) refreshSearchText(ctx context.Context, id PostId) error {
+	if _, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, searchTextUpdate); err != nil {
+		return fmt.Errorf("post/repo: failed updating search text: %w", err)
+	}
+	return nil
+}
+
+// Finds the posts matching the query text in their titles, texts or comments.
+func (r *Repo) Search(ctx context.Context, q *SearchQuery) ([]*SearchHit, error) {
+	filter := bson.M{"$text": bson.M{"$search": q.Text}, "deletedat": nil, "status": published}
+	if q.Category != "" {
+		filter["category"] = q.Category
+	}
+	if q.Author != "" {
+		filter["author.username"] = q.Author
+	}
+	if q.Type != "" {
+		filter["type"] = q.Type
+	}
+	created := bson.M{}
+	if !q.From.IsZero() {
+		created["$gte"] = q.From
+	}
+	if !q.To.IsZero() {
+		created["$lt"] = q.To
+	}
+	if len(created) > 0 {
+		filter["created"] = created
+	}
+	if visible := q.visibility.query(); len(visible) > 0 {
+		filter["$and"] = visible
+	}
+
+	relevance := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"relevance": relevance, "searchtext": 0}).
+		SetSkip(int64(q.Page.Offset)).
+		SetLimit(int64(q.Page.Limit))
+	if q.SortByDate {
+		opts.SetSort(bson.D{{Key: "created", Value: -1}})
+	} else {
+		opts.SetSort(bson.D{{Key: "relevance", Value: relevance}, {Key: "created", Value: -1}})
+	}
+
+	cursor, err := r.posts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed searching posts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	docs := []*struct {
+		Post      `bson:",inline"`
+		Relevance float64 `bson:"relevance"`
+	}{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	hits := make([]*SearchHit, 0, len(docs))
+	for _, d := range docs {
+		p := d.Post
+		hits = append(hits, &SearchHit{Post: &p, Relevance: d.Relevance})
+	}
+	return hits, nil
+}
+
+// Returns the posts which are labeled for the spam classifier themselves or have labeled comments.
+func (r *Repo) GetModerated(ctx context.Context) ([]*Post, error) {
+	labeled := bson.M{"$in": bson.A{spamLabelSpam, spamLabelHam}}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"spamlabel": labeled},
+		bson.M{"comments.spamlabel": labeled},
+	}}
+	cursor, err := r.posts.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	posts := []*Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	return posts, nil
+}
 
+func (r *Repo) AddComment(ctx context.Context, postId PostId, cmt *comment.Comment) (*Post, error) {
 	filter := bson.D{{Key: "id", Value: postId}}
 	update := bson.D{{Key: "$push", Value: bson.D{{Key: "comments", Value: cmt}}}}
 	_, err := r.posts.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.refreshSearchText(ctx, postId); err != nil {
+		return nil, err
+	}
 
 	post, err := r.GetById(ctx, PostId(postId))
 	if err != nil {
@@ -97,13 +815,17 @@ func (r *Repo) AddComment(ctx context.Context, postId PostId, commenter *user.Us
 	return post, nil
 }
 
-func (r *Repo) DeletePostComment(ctx context.Context, postId PostId, commentId comment.CommentId) (*Post, error) {
-	filter := bson.D{{"id", postId}}
-	update := bson.D{{"$pull", bson.D{{"comments", bson.D{{"id", commentId}}}}}}
-	_, err := r.posts.UpdateOne(ctx, filter, update)
+// Marks the comment deleted, it's removed permanently by Purge.
+func (r *Repo) DeletePostComment(ctx context.Context, postId PostId, commentId comment.CommentId, deletedBy string) (*Post, error) {
+	filter := bson.M{"id": postId, "comments.id": commentId}
+	set := bson.M{"comments.$.deletedat": time.Now(), "comments.$.deletedby": deletedBy}
+	_, err := r.posts.UpdateOne(ctx, filter, bson.M{"$set": set})
 	if err != nil {
 		return nil, err
 	}
+	if err := r.refreshSearchText(ctx, postId); err != nil {
+		return nil, err
+	}
 
 	post, err := r.GetById(ctx, postId)
 	if err != nil {
@@ -114,7 +836,7 @@ func (r *Repo) DeletePostComment(ctx context.Context, postId PostId, commentId c
 }
 
 func (r *Repo) GetCategoryPosts(ctx context.Context, category string) ([]*Post, error) {
-	cursor, err := r.posts.Find(ctx, bson.D{{"category", category}})
+	cursor, err := r.posts.Find(ctx, bson.M{"category": category, "deletedat": nil, "status": published})
 	if err != nil {
 		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
 	}
@@ -127,9 +849,46 @@ func (r *Repo) GetCategoryPosts(ctx context.Context, category string) ([]*Post,
 	return categoryPosts, nil
 }
 
+// Returns the newest posts of the last hotWindow by the users or in the categories,
+// at most maxHotCandidates of them for ranking the feed.
+func (r *Repo) GetFeedPosts(ctx context.Context, usernames, categories []string) ([]*Post, error) {
+	sources := bson.A{}
+	if len(usernames) > 0 {
+		sources = append(sources, bson.M{"author.username": bson.M{"$in": usernames}})
+	}
+	if len(categories) > 0 {
+		sources = append(sources, bson.M{"category": bson.M{"$in": categories}})
+	}
+	if len(sources) == 0 {
+		return []*Post{}, nil
+	}
+	filter := bson.M{
+		"$or":       sources,
+		"created":   bson.M{"$gte": time.Now().Add(-hotWindow)},
+		"deletedat": nil,
+		"status":    published,
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}}).SetLimit(maxHotCandidates)
+	cursor, err := r.posts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	posts := []*Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return nil, fmt.Errorf("post/repo: failed geting posts from cursor: %w", err)
+	}
+	return posts, nil
+}
+
 func (r *Repo) GetUserPosts(ctx context.Context, username string) ([]*Post, error) {
 	userPosts := []*Post{}
-	filter := bson.D{{Key: "author.username", Value: username}}
+	filter := bson.D{
+		{Key: "author.username", Value: username},
+		{Key: "deletedat", Value: nil},
+		{Key: "status", Value: published},
+	}
 	cursor, err := r.posts.Find(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("post/repo: failed finding posts: %w", err)
@@ -140,47 +899,29 @@ func (r *Repo) GetUserPosts(ctx context.Context, username string) ([]*Post, erro
 	return userPosts, nil
 }
 
-// Vote with the MongoDB transaction:
-// - update the post data,
-// - recalculate upvote percentage
-// - and update the post in DB.
+// Vote updates the votes of the post and recalculates its score.
+// Only the vote fields are written, the counters updated meanwhile,
+// like the views and the poll votes, are kept.
 func (r *Repo) Vote(ctx context.Context, post *Post, newVote *voting.Vote) error {
-	session, err := r.posts.Database().Client().StartSession()
-	if err != nil {
-		logger.Log(ctx).Errorf("post/repo: start session failed", err)
-		return err
-	}
-	defer session.EndSession(ctx)
-
-	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-		_, userAlreadyVoted := r.getUserVoteFromPost(post, newVote.UserId)
+	_, userAlreadyVoted := r.getUserVoteFromPost(post, newVote.UserId)
 
-		if !userAlreadyVoted && newVote.Score != voting.ScoreDiscard {
-			post.Votes = append(post.Votes, newVote)
-		} else {
-			// This handles several cases which are equal to unvote:
-			// 1. User wants remove previous vote (pure unvote);
-			// 2. User previously voted +1 and now votes -1;
-			// 3. User previously voted -1 and now votes +1.
-			removeVote(post, newVote.UserId)
-		}
-
-		updatePercentage(post)
-
-		err := r.Update(ctx, post)
-		if err != nil {
-			return nil, fmt.Errorf("post/repo: can't update vote %w", err)
-		}
-
-		return post, nil
+	if !userAlreadyVoted && newVote.Score != voting.ScoreDiscard {
+		post.Votes = append(post.Votes, newVote)
+	} else {
+		// This handles several cases which are equal to unvote:
+		// 1. User wants remove previous vote (pure unvote);
+		// 2. User previously voted +1 and now votes -1;
+		// 3. User previously voted -1 and now votes +1.
+		removeVote(post, newVote.UserId)
 	}
 
-	_, err = session.WithTransaction(ctx, callback)
+	updatePercentage(post)
+
+	set := bson.M{"votes": post.Votes, "score": post.Score, "upvotepercentage": post.UpvotePercentage}
+	_, err := r.posts.UpdateOne(ctx, bson.M{"id": post.Id}, bson.M{"$set": set})
 	if err != nil {
-		logger.Log(ctx).Errorf("post/repo: failed update post votes", err)
-		return err
+		return fmt.Errorf("post/repo: can't update vote: %w", err)
 	}
-
 	return nil
 }
 
@@ -2,12 +2,15 @@ package post
 
 import (
 	"context"
+	"crud/pkg/comment"
 	"crud/pkg/user"
+	"crud/pkg/voting"
 	"fmt"
 	"testing"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func TestPostAdd(t *testing.T) {
@@ -87,3 +90,61 @@ func TestGetUserPosts(t *testing.T) {
 		}, users)
 	})
 }
+
+func TestBackfillHTML(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockCursor := NewMockIMongoCursor(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+
+	posts := []*Post{{Id: "1", Text: "**hi**", Comments: []*comment.Comment{{Body: "*yo*"}}}}
+	mockMongoColl.EXPECT().Find(ctx, gomock.Any(), gomock.Any()).Return(mockCursor, nil)
+	mockCursor.EXPECT().All(ctx, gomock.Any()).SetArg(1, posts).Return(nil)
+	mockCursor.EXPECT().Close(ctx)
+	mockMongoColl.EXPECT().
+		UpdateOne(ctx, bson.M{"id": PostId("1")}, bson.M{"$set": bson.M{
+			"texthtml":            "<p><strong>hi</strong></p>\n",
+			"comments.0.bodyhtml": "<p><em>yo</em></p>\n",
+		}}).
+		Return(nil, nil)
+
+	updated, err := repo.BackfillHTML(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, updated)
+}
+
+func TestRepoVote(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockUpdateResult := NewMockIMongoUpdateResult(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+	// The post read before the views and the poll votes counted meanwhile.
+	post := &Post{Id: "1", Type: PostPoll, Views: 3, Poll: &Poll{TotalVotes: 1}}
+	vote := &voting.Vote{UserId: "2", Score: voting.ScoreUp}
+
+	t.Run("should write only the votes and keep the counters updated meanwhile", func(t *testing.T) {
+		mockMongoColl.EXPECT().
+			UpdateOne(ctx, bson.M{"id": PostId("1")}, bson.M{"$set": bson.M{
+				"votes": []*voting.Vote{vote}, "score": 1, "upvotepercentage": 100,
+			}}).
+			Return(mockUpdateResult, nil)
+
+		assert.Nil(t, repo.Vote(ctx, post, vote))
+	})
+
+	t.Run("should remove the vote of the user voting again", func(t *testing.T) {
+		mockMongoColl.EXPECT().
+			UpdateOne(ctx, bson.M{"id": PostId("1")}, bson.M{"$set": bson.M{
+				"votes": []*voting.Vote{}, "score": 0, "upvotepercentage": 0,
+			}}).
+			Return(mockUpdateResult, nil)
+
+		assert.Nil(t, repo.Vote(ctx, post, &voting.Vote{UserId: "2", Score: voting.ScoreDown}))
+	})
+}
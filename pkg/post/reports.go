@@ -0,0 +1,362 @@
+package post
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/category"
+	"crud/pkg/comment"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/report"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+// Reports the post, body: `{"reason": "spam", "text": "..."}`.
+func (ph *PostHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	ph.addReport(w, r, report.TargetPost)
+}
+
+func (ph *PostHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	ph.addReport(w, r, report.TargetComment)
+}
+
+func (ph *PostHandler) addReport(w http.ResponseWriter, r *http.Request, targetType report.TargetType) {
+	w.Header().Set("Content-Type", "application/json")
+
+	reporter, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	if err := reporter.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	postId := vars["post_id"]
+	post, err := ph.PostRepo.GetById(r.Context(), PostId(postId))
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+
+	targetId, author := postId, post.Author
+	if targetType == report.TargetComment {
+		c := findComment(post, comment.CommentId(vars["comment_id"]))
+		if c == nil {
+			WriteMsg(w, "comment not found", http.StatusNotFound)
+			return
+		}
+		targetId, author = string(c.Id), c.Author
+	}
+	if author != nil && author.Id == reporter.Id {
+		WriteMsg(w, "you can't report your own content", http.StatusBadRequest)
+		return
+	}
+
+	rep := new(report.Report)
+	if err := ParseReqBody(r.Body, rep); err != nil {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	rep.Category = post.Category
+	rep.TargetType = targetType
+	rep.TargetId = targetId
+	rep.PostId = postId
+	rep.ReporterId = reporter.Id
+	rep.Created = time.Now()
+	rep.Resolution = ""
+	if err := rep.Validate(); err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = ph.Reports.Add(r.Context(), rep)
+	if errors.Is(err, report.ErrAlreadyReported) {
+		WriteMsg(w, "you have already reported this", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't add report: %v", err)
+		WriteMsg(w, "failed reporting", http.StatusInternalServerError)
+		return
+	}
+
+	ph.applyReportThresholds(r, rep)
+
+	WriteMsg(w, "reported", http.StatusCreated)
+}
+
+// Collapses or hides the reported content if it got enough reports.
+func (ph *PostHandler) applyReportThresholds(r *http.Request, rep *report.Report) {
+	th := ph.Config.ReportThresholds
+	if th.Collapse == 0 && th.Hide == 0 {
+		return
+	}
+	count, err := ph.Reports.CountOpen(r.Context(), rep.TargetType, rep.TargetId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't count reports of %s: %v", rep.TargetId, err)
+		return
+	}
+
+	flags := map[PostFlag]bool{}
+	if th.Collapse > 0 && count >= th.Collapse {
+		flags[FlagCollapsed] = true
+	}
+	if th.Hide > 0 && count >= th.Hide {
+		flags[FlagHidden] = true
+	}
+	if len(flags) == 0 {
+		return
+	}
+	if err := ph.setTargetFlags(r, rep.TargetType, rep.PostId, rep.TargetId, flags); err != nil {
+		logger.Log(r.Context()).Errorf("can't apply report thresholds to %s: %v", rep.TargetId, err)
+	}
+}
+
+// Open reports of the category grouped per content.
+func (ph *PostHandler) ReportQueue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug := mux.Vars(r)["category"]
+	if _, ok := ph.categoryModerator(w, r, slug); !ok {
+		return
+	}
+	page, err := ParsePage(r)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	queue, err := ph.Reports.Queue(r.Context(), slug, page)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load report queue of %s: %v", slug, err)
+		WriteMsg(w, "failed loading reports", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, queue)
+}
+
+// Open reports of the single post or comment.
+func (ph *PostHandler) ReportDetails(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	slug := vars["category"]
+	if _, ok := ph.categoryModerator(w, r, slug); !ok {
+		return
+	}
+
+	reports, err := ph.Reports.GetOpen(r.Context(), slug, report.TargetType(vars["target_type"]), vars["target_id"])
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load reports of %s: %v", vars["target_id"], err)
+		WriteMsg(w, "failed loading reports", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, reports)
+}
+
+// Resolves the reports of the content,
+// body: `{"action": "dismiss|remove|ban", "reason": "...", "until": "2030-01-01T00:00:00Z"}`.
+func (ph *PostHandler) ResolveReports(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	slug := vars["category"]
+	targetType := report.TargetType(vars["target_type"])
+	targetId := vars["target_id"]
+	mod, ok := ph.categoryModerator(w, r, slug)
+	if !ok {
+		return
+	}
+
+	body := struct {
+		Action report.Resolution `json:"action"`
+		Reason string            `json:"reason"`
+		Until  *time.Time        `json:"until,omitempty"`
+	}{}
+	if err := ParseReqBody(r.Body, &body); err != nil {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+
+	reports, err := ph.Reports.GetOpen(r.Context(), slug, targetType, targetId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load reports of %s: %v", targetId, err)
+		WriteMsg(w, "failed loading reports", http.StatusInternalServerError)
+		return
+	}
+	if len(reports) == 0 {
+		WriteMsg(w, "no open reports", http.StatusNotFound)
+		return
+	}
+	postId := reports[0].PostId
+
+	switch body.Action {
+	case report.ResolutionDismiss:
+		flags := map[PostFlag]bool{FlagCollapsed: false, FlagHidden: false}
+		if err := ph.setTargetFlags(r, targetType, postId, targetId, flags); err != nil {
+			logger.Log(r.Context()).Errorf("can't restore reported %s: %v", targetId, err)
+			WriteMsg(w, "failed resolving reports", http.StatusInternalServerError)
+			return
+		}
+		ph.recordModAction(r, &category.ModAction{
+			Category: slug, ActorId: mod.Id, Action: category.ActionDismissReports,
+			TargetType: category.TargetType(targetType), TargetId: targetId, Reason: body.Reason,
+		})
+	case report.ResolutionRemove, report.ResolutionBan:
+		isSpam := isSpamReason(body.Reason) || (body.Reason == "" && reportedAs(reports, report.ReasonSpam))
+		author, ok := ph.removeReported(w, r, mod, slug, targetType, postId, targetId, body.Reason, isSpam)
+		if !ok {
+			return
+		}
+		if body.Action == report.ResolutionBan && !ph.banReported(w, r, mod, slug, author, body.Reason, body.Until) {
+			return
+		}
+	default:
+		WriteMsg(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+
+	resolved, err := ph.Reports.Resolve(r.Context(), slug, targetType, targetId, body.Action, mod.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't resolve reports of %s: %v", targetId, err)
+		WriteMsg(w, "failed resolving reports", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, struct {
+		Resolved int64 `json:"resolved"`
+	}{resolved})
+}
+
+// Removes the reported content and returns its author.
+func (ph *PostHandler) removeReported(w http.ResponseWriter, r *http.Request, mod *user.User, slug string,
+	targetType report.TargetType, postId, targetId, reason string, isSpam bool,
+) (*user.User, bool) {
+	post, err := ph.PostRepo.GetById(r.Context(), PostId(postId))
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	author := post.Author
+	action := category.ActionRemovePost
+	flags := map[PostFlag]bool{FlagRemoved: true, FlagApproved: false}
+	doc := post.spamDoc()
+	commentId := comment.CommentId("")
+	if targetType == report.TargetComment {
+		c := findComment(post, comment.CommentId(targetId))
+		if c == nil {
+			WriteMsg(w, "comment not found", http.StatusNotFound)
+			return nil, false
+		}
+		author = c.Author
+		action = category.ActionRemoveComment
+		flags = map[PostFlag]bool{FlagRemoved: true, FlagApproved: false}
+		doc = commentSpamDoc(c)
+		commentId = c.Id
+	}
+
+	if err := ph.setTargetFlags(r, targetType, postId, targetId, flags); err != nil {
+		logger.Log(r.Context()).Errorf("can't remove reported %s: %v", targetId, err)
+		WriteMsg(w, "failed removing content", http.StatusInternalServerError)
+		return nil, false
+	}
+	if targetType == report.TargetPost {
+		ph.moderateCrossposts(r.Context(), post, flags)
+	}
+	ph.recordModAction(r, &category.ModAction{
+		Category: slug, ActorId: mod.Id, Action: action,
+		TargetType: category.TargetType(targetType), TargetId: targetId, Reason: reason,
+	})
+	if isSpam {
+		ph.learnSpam(r, PostId(postId), commentId, doc, true)
+	}
+	return author, true
+}
+
+// Reports if every report gives the reason.
+func reportedAs(reports []*report.Report, reason report.Reason) bool {
+	for _, rep := range reports {
+		if rep.Reason != reason {
+			return false
+		}
+	}
+	return len(reports) > 0
+}
+
+func (ph *PostHandler) banReported(w http.ResponseWriter, r *http.Request, mod *user.User, slug string,
+	author *user.User, reason string, until *time.Time,
+) bool {
+	if author == nil || author.Id == mod.Id {
+		WriteMsg(w, "can't ban the author", http.StatusBadRequest)
+		return false
+	}
+	ban := &category.Ban{
+		Category: slug,
+		UserId:   author.Id,
+		ActorId:  mod.Id,
+		Reason:   reason,
+		Until:    until,
+		Created:  time.Now(),
+	}
+	if err := ph.Moderation.Ban(r.Context(), ban); err != nil {
+		logger.Log(r.Context()).Errorf("can't ban user %s in %s: %v", author.Id, slug, err)
+		WriteMsg(w, "failed banning the author", http.StatusInternalServerError)
+		return false
+	}
+	ph.recordModAction(r, &category.ModAction{
+		Category: slug, ActorId: mod.Id, Action: category.ActionBanUser,
+		TargetType: category.TargetUser, TargetId: author.Id, Reason: reason,
+	})
+	return true
+}
+
+func (ph *PostHandler) setTargetFlags(r *http.Request, targetType report.TargetType, postId, targetId string,
+	flags map[PostFlag]bool,
+) error {
+	if targetType == report.TargetComment {
+		return ph.PostRepo.SetCommentFlags(r.Context(), PostId(postId), comment.CommentId(targetId), flags)
+	}
+	return ph.PostRepo.SetFlags(r.Context(), PostId(postId), flags)
+}
+
+// Returns the authenticated moderator of the category, otherwise writes the error response.
+func (ph *PostHandler) categoryModerator(w http.ResponseWriter, r *http.Request, slug string) (*user.User, bool) {
+	mod, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	isMod, err := ph.Moderation.CanModerate(r.Context(), slug, mod)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check moderator of %s: %v", slug, err)
+		WriteMsg(w, "failed checking permissions", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !isMod {
+		WriteMsg(w, "only moderators of "+slug+" can do this", http.StatusForbidden)
+		return nil, false
+	}
+	return mod, true
+}
+
+func findComment(post *Post, id comment.CommentId) *comment.Comment {
+	for _, c := range post.Comments {
+		if c.Id == id {
+			return c
+		}
+	}
+	return nil
+}
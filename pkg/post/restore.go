@@ -0,0 +1,155 @@
+package post
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/comment"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+// Restores the deleted post, allowed to its author and the category moderators.
+func (ph *PostHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	postId := PostId(mux.Vars(r)["post_id"])
+	post, err := ph.PostRepo.GetDeletedById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get deleted post with id %s: %v", postId, err)
+		WriteMsg(w, "deleted post not found", http.StatusNotFound)
+		return
+	}
+	if !ph.canRestore(w, r, post.Category, post.Author, post.DeletedAt) {
+		return
+	}
+
+	if err := ph.PostRepo.Restore(r.Context(), postId); err != nil {
+		logger.Log(r.Context()).Errorf("can't restore post %s: %v", postId, err)
+		WriteMsg(w, "restoring post failed", http.StatusInternalServerError)
+		return
+	}
+	if post.countsAsCrosspost() {
+		ph.countCrosspost(r.Context(), post, 1)
+	}
+	ph.markCrossposts(r.Context(), post, false)
+
+	post, err = ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	ph.writePost(w, r, post)
+}
+
+// Restores the deleted comment, allowed to its author and the category moderators.
+func (ph *PostHandler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+	postId := PostId(vars["post_id"])
+	commentId := comment.CommentId(vars["comment_id"])
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		// The comments of the deleted post come back with it.
+		if _, err := ph.PostRepo.GetDeletedById(r.Context(), postId); err == nil {
+			WriteMsg(w, "the post is deleted, restore the post first", http.StatusConflict)
+			return
+		}
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	c := findComment(post, commentId)
+	if c == nil || c.DeletedAt == nil {
+		WriteMsg(w, "deleted comment not found", http.StatusNotFound)
+		return
+	}
+	if !ph.canRestore(w, r, post.Category, c.Author, c.DeletedAt) {
+		return
+	}
+
+	if err := ph.PostRepo.RestoreComment(r.Context(), postId, commentId); err != nil {
+		logger.Log(r.Context()).Errorf("can't restore comment %s of post %s: %v", commentId, postId, err)
+		WriteMsg(w, "restoring comment failed", http.StatusInternalServerError)
+		return
+	}
+
+	post, err = ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	ph.writePost(w, r, post)
+}
+
+// Checks the authenticated user may restore the content deleted at the time,
+// otherwise writes the error response.
+func (ph *PostHandler) canRestore(w http.ResponseWriter, r *http.Request, category string,
+	author *user.User, deletedAt *time.Time,
+) bool {
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return false
+	}
+	if !ph.withinRestoreWindow(deletedAt, time.Now()) {
+		WriteMsg(w, "the restore period is over", http.StatusGone)
+		return false
+	}
+	if author != nil && author.Id == authUser.Id {
+		return true
+	}
+	isMod, err := ph.Moderation.CanModerate(r.Context(), category, authUser)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check moderator of %s: %v", category, err)
+		WriteMsg(w, "failed checking permissions", http.StatusInternalServerError)
+		return false
+	}
+	if !isMod {
+		WriteMsg(w, "only the author or moderators can restore this", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (ph *PostHandler) withinRestoreWindow(deletedAt *time.Time, now time.Time) bool {
+	return deletedAt != nil && now.Before(deletedAt.Add(ph.Config.RestoreWindow))
+}
+
+type IPurgeRepo interface {
+	Purge(context.Context, time.Time) error
+}
+
+// Purger periodically removes the content deleted more than retention ago.
+type Purger struct {
+	repo      IPurgeRepo
+	retention time.Duration
+	every     time.Duration
+}
+
+func NewPurger(repo IPurgeRepo, retention, every time.Duration) *Purger {
+	return &Purger{
+		repo:      repo,
+		retention: retention,
+		every:     every,
+	}
+}
+
+// Purges the deleted content until the context is done.
+func (p *Purger) Run(ctx context.Context) {
+	RunPeriodically(ctx, p.every, p.purge)
+}
+
+func (p *Purger) purge(ctx context.Context, now time.Time) {
+	if err := p.repo.Purge(ctx, now.Add(-p.retention)); err != nil {
+		logger.Log(ctx).Errorf("can't purge deleted posts: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/comment"
+	"crud/pkg/user"
+)
+
+type purgeRepoStub struct {
+	before time.Time
+}
+
+func (r *purgeRepoStub) Purge(_ context.Context, before time.Time) error {
+	r.before = before
+	return nil
+}
+
+func TestPurger(t *testing.T) {
+	now := time.Now()
+	repo := &purgeRepoStub{}
+	NewPurger(repo, 30*24*time.Hour, time.Hour).purge(context.Background(), now)
+	assert.Equal(t, now.Add(-30*24*time.Hour), repo.before)
+}
+
+func TestWithinRestoreWindow(t *testing.T) {
+	now := time.Now()
+	ph := &PostHandler{Config: Config{RestoreWindow: 24 * time.Hour}}
+	recent, old := now.Add(-time.Hour), now.Add(-25*time.Hour)
+
+	assert.True(t, ph.withinRestoreWindow(&recent, now))
+	assert.False(t, ph.withinRestoreWindow(&old, now))
+	assert.False(t, ph.withinRestoreWindow(nil, now), "not deleted")
+}
+
+type deletedPostStub struct {
+	IPostRepo
+	deleted  map[PostId]*Post
+	live     map[PostId]*Post
+	restored []string
+}
+
+func (r *deletedPostStub) GetById(_ context.Context, id PostId) (*Post, error) {
+	if p, ok := r.live[id]; ok {
+		return p, nil
+	}
+	return nil, errors.New("post: post not found")
+}
+
+func (r *deletedPostStub) GetDeletedById(_ context.Context, id PostId) (*Post, error) {
+	if p, ok := r.deleted[id]; ok {
+		return p, nil
+	}
+	return nil, errors.New("post: deleted post not found")
+}
+
+func (r *deletedPostStub) Restore(_ context.Context, id PostId) error {
+	r.restored = append(r.restored, string(id))
+	r.live[id] = r.deleted[id]
+	return nil
+}
+
+func (r *deletedPostStub) RestoreComment(_ context.Context, id PostId, commentId comment.CommentId) error {
+	r.restored = append(r.restored, string(id)+"/"+string(commentId))
+	return nil
+}
+
+func (r *deletedPostStub) AddCrossposts(context.Context, PostId, int) error { return nil }
+
+func (r *deletedPostStub) SetCrosspostsRemoved(context.Context, PostId, bool) error { return nil }
+
+func TestRestorePost(t *testing.T) {
+	author := &user.User{Id: "1"}
+	recent, old := time.Now().Add(-time.Hour), time.Now().Add(-48*time.Hour)
+	repo := &deletedPostStub{live: map[PostId]*Post{}, deleted: map[PostId]*Post{
+		"recent": {Id: "recent", Author: author, DeletedAt: &recent},
+		"old":    {Id: "old", Author: author, DeletedAt: &old},
+	}}
+	ph, _ := newTestHandler(repo)
+	ph.Config.RestoreWindow = 24 * time.Hour
+
+	for postId, code := range map[string]int{"old": http.StatusGone, "unknown": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": postId}
+		ph.RestorePost(w, newTestRequest("POST", "/api/post/"+postId+"/restore", vars, author, nil))
+		assert.Equal(t, code, w.Code, postId)
+	}
+	assert.Empty(t, repo.restored)
+
+	w := httptest.NewRecorder()
+	vars := map[string]string{"post_id": "recent"}
+	ph.RestorePost(w, newTestRequest("POST", "/api/post/recent/restore", vars, author, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"recent"}, repo.restored)
+}
+
+func TestRestoreComment(t *testing.T) {
+	recent, old := time.Now().Add(-time.Hour), time.Now().Add(-48*time.Hour)
+	author := &user.User{Id: "1"}
+	repo := &deletedPostStub{live: map[PostId]*Post{"1": {Id: "1", Category: "golang", Comments: []*comment.Comment{
+		{Id: "recent", Author: author, DeletedAt: &recent},
+		{Id: "old", Author: author, DeletedAt: &old},
+		{Id: "live", Author: author},
+	}}}}
+	ph, _ := newTestHandler(repo, "mod")
+	ph.Config.RestoreWindow = 24 * time.Hour
+
+	for commentId, code := range map[string]int{"old": http.StatusGone, "live": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": "1", "comment_id": commentId}
+		ph.RestoreComment(w, newTestRequest("POST", "/api/post/1/"+commentId+"/restore", vars, author, nil))
+		assert.Equal(t, code, w.Code, commentId)
+	}
+
+	w := httptest.NewRecorder()
+	vars := map[string]string{"post_id": "1", "comment_id": "recent"}
+	ph.RestoreComment(w, newTestRequest("POST", "/api/post/1/recent/restore", vars, &user.User{Id: "mod"}, nil))
+	assert.Equal(t, http.StatusOK, w.Code, "the moderators restore the comments of others")
+	assert.Equal(t, []string{"1/recent"}, repo.restored)
+}
+
+func TestRestoreCommentOfDeletedPost(t *testing.T) {
+	deletedAt := time.Now()
+	ph, _ := newTestHandler(&deletedPostStub{deleted: map[PostId]*Post{"1": {Id: "1", DeletedAt: &deletedAt}}})
+
+	for postId, code := range map[string]int{"1": http.StatusConflict, "2": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		vars := map[string]string{"post_id": postId, "comment_id": "c1"}
+		ph.RestoreComment(w, newTestRequest("POST", "/api/post/"+postId+"/c1/restore", vars, nil, nil))
+		assert.Equal(t, code, w.Code, postId)
+	}
+}
@@ -0,0 +1,217 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+)
+
+// The posts can't be scheduled further ahead.
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// Posts published per tick.
+const scheduleBatch = 100
+
+// PostStatus is the publication status of the post with its scheduled time.
+type PostStatus struct {
+	Status    string
+	PublishAt *time.Time
+}
+
+func (p *Post) status() PostStatus {
+	return PostStatus{Status: p.Status, PublishAt: p.PublishAt}
+}
+
+type IScheduleRepo interface {
+	GetById(context.Context, PostId) (*Post, error)
+	GetDue(ctx context.Context, now time.Time, limit int) ([]*Post, error)
+	SetStatus(ctx context.Context, id PostId, from, to PostStatus, now time.Time) (bool, error)
+	AddCrossposts(ctx context.Context, id PostId, delta int) error
+}
+
+// Checks the publication status of the post from the client, the empty one is published.
+func (p *Post) prepareStatus(now time.Time) error {
+	switch p.Status {
+	case "", StatusPublished:
+		p.Status, p.PublishAt = StatusPublished, nil
+	case StatusDraft:
+		p.PublishAt = nil
+	case StatusScheduled:
+		if p.PublishAt == nil || !p.PublishAt.After(now) {
+			return fmt.Errorf("%w: scheduled post needs publishAt in the future", ErrInvalidPost)
+		}
+		if p.PublishAt.After(now.Add(maxScheduleAhead)) {
+			return fmt.Errorf("%w: post can be scheduled at most %d days ahead",
+				ErrInvalidPost, int(maxScheduleAhead.Hours()/24))
+		}
+	default:
+		return fmt.Errorf("%w: status must be %s, %s or %s", ErrInvalidPost, StatusDraft, StatusScheduled, StatusPublished)
+	}
+	return nil
+}
+
+// Scheduler periodically publishes the scheduled posts which are due. Every post
+// is published by a single SetStatus call changing its status from scheduled, so
+// when several instances run only one of them publishes it.
+type Scheduler struct {
+	repo  IScheduleRepo
+	every time.Duration
+}
+
+func NewScheduler(repo IScheduleRepo, every time.Duration) *Scheduler {
+	return &Scheduler{
+		repo:  repo,
+		every: every,
+	}
+}
+
+// Publishes the due posts until the context is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	RunPeriodically(ctx, s.every, func(ctx context.Context, now time.Time) {
+		s.publish(ctx, now)
+	})
+}
+
+// Returns the number of the posts published by this call.
+func (s *Scheduler) publish(ctx context.Context, now time.Time) int {
+	posts, err := s.repo.GetDue(ctx, now, scheduleBatch)
+	if err != nil {
+		logger.Log(ctx).Errorf("can't load scheduled posts: %v", err)
+		return 0
+	}
+	published := 0
+	for _, p := range posts {
+		live, err := originalLive(ctx, s.repo.GetById, p)
+		if err != nil {
+			logger.Log(ctx).Errorf("can't check the original of scheduled post %s: %v", p.Id, err)
+			continue
+		}
+		if !live {
+			s.unschedule(ctx, p)
+			continue
+		}
+		ok, err := s.repo.SetStatus(ctx, p.Id, p.status(), PostStatus{Status: StatusPublished}, now)
+		if err != nil {
+			logger.Log(ctx).Errorf("can't publish scheduled post %s: %v", p.Id, err)
+			continue
+		}
+		// Published by another instance, or published or rescheduled by the author meanwhile.
+		if !ok {
+			continue
+		}
+		published++
+		logger.Log(ctx).Infof("published scheduled post %s", p.Id)
+		if p.CrosspostOf != nil {
+			if err := s.repo.AddCrossposts(ctx, p.CrosspostOf.Id, 1); err != nil {
+				logger.Log(ctx).Errorf("can't count crossposts of post %s: %v", p.CrosspostOf.Id, err)
+			}
+		}
+	}
+	return published
+}
+
+// Moves the crosspost of the original deleted meanwhile back to the drafts, so it's not retried.
+func (s *Scheduler) unschedule(ctx context.Context, p *Post) {
+	ok, err := s.repo.SetStatus(ctx, p.Id, p.status(), PostStatus{Status: StatusDraft}, time.Now())
+	if err != nil {
+		logger.Log(ctx).Errorf("can't unschedule post %s: %v", p.Id, err)
+		return
+	}
+	if ok {
+		logger.Log(ctx).Infof("moved scheduled post %s to drafts, its original is gone", p.Id)
+	}
+}
+
+// Changes the status of the author's draft or scheduled post,
+// body: `{"status": "draft|scheduled|published", "publishAt": "2030-01-01T10:00:00Z"}`.
+func (ph *PostHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	postId := PostId(mux.Vars(r)["post_id"])
+	author, post, ok := ph.editable(w, r, postId)
+	if !ok {
+		return
+	}
+	if post.Author == nil || post.Author.Id != author.Id {
+		WriteMsg(w, "only the author can publish the post", http.StatusForbidden)
+		return
+	}
+	if post.IsPublished() {
+		WriteMsg(w, "the post is published already", http.StatusConflict)
+		return
+	}
+
+	body := struct {
+		Status    string
+		PublishAt *time.Time
+	}{}
+	if err := ParseReqBody(r.Body, &body); err != nil {
+		WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	next := &Post{Status: body.Status, PublishAt: body.PublishAt}
+	if err := next.prepareStatus(now); err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if next.IsPublished() {
+		live, err := originalLive(r.Context(), ph.PostRepo.GetById, post)
+		if err != nil {
+			logger.Log(r.Context()).Errorf("can't check the original of post %s: %v", postId, err)
+			WriteMsg(w, "failed changing post status", http.StatusInternalServerError)
+			return
+		}
+		if !live {
+			WriteMsg(w, "the original post is deleted, the crosspost can't be published", http.StatusConflict)
+			return
+		}
+	}
+
+	changed, err := ph.PostRepo.SetStatus(r.Context(), postId, post.status(), next.status(), now)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't change status of post %s: %v", postId, err)
+		WriteMsg(w, "failed changing post status", http.StatusInternalServerError)
+		return
+	}
+	if !changed {
+		WriteMsg(w, "the post status has changed meanwhile, try again", http.StatusConflict)
+		return
+	}
+	if next.IsPublished() && !post.Removed {
+		ph.countCrosspost(r.Context(), post, 1)
+	}
+
+	if reloaded, err := ph.PostRepo.GetById(r.Context(), postId); err == nil {
+		post = reloaded
+	}
+	ph.writePost(w, r, post)
+}
+
+// Lists the drafts and the scheduled posts of the authenticated user, the latest first.
+func (ph *PostHandler) ListDrafts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	posts, err := ph.PostRepo.GetDrafts(r.Context(), authUser.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load drafts of user %s: %v", authUser.Id, err)
+		WriteMsg(w, "failed loading drafts", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, posts)
+}
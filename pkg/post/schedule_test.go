@@ -0,0 +1,302 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"crud/pkg/logger"
+	"crud/pkg/user"
+)
+
+// Changes the statuses atomically like the conditional Mongo update.
+type scheduleRepoStub struct {
+	mu         sync.Mutex
+	posts      map[PostId]*Post
+	crossposts map[PostId]int
+}
+
+func (r *scheduleRepoStub) GetById(_ context.Context, id PostId) (*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.posts[id]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("post: post not found: %w", mongo.ErrNoDocuments)
+}
+
+func (r *scheduleRepoStub) GetDue(_ context.Context, now time.Time, _ int) ([]*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := []*Post{}
+	for _, p := range r.posts {
+		if p.Status == StatusScheduled && !p.PublishAt.After(now) {
+			due = append(due, &Post{Id: p.Id, Status: p.Status, PublishAt: p.PublishAt, CrosspostOf: p.CrosspostOf})
+		}
+	}
+	return due, nil
+}
+
+func (r *scheduleRepoStub) SetStatus(_ context.Context, id PostId, from, to PostStatus, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.posts[id]
+	if p == nil || p.Status != from.Status || !sameTime(p.PublishAt, from.PublishAt) {
+		return false, nil
+	}
+	p.Status, p.PublishAt = to.Status, to.PublishAt
+	return true, nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func (r *scheduleRepoStub) AddCrossposts(_ context.Context, id PostId, delta int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.crossposts[id] += delta
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.WithValue(context.Background(), logger.LoggerKey, zap.NewNop().Sugar())
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	repo := &scheduleRepoStub{posts: map[PostId]*Post{}, crossposts: map[PostId]int{}}
+	for i := 0; i < 50; i++ {
+		id := PostId(fmt.Sprint(i))
+		repo.posts[id] = &Post{Id: id, Status: StatusScheduled, PublishAt: &past}
+	}
+	repo.posts["later"] = &Post{Id: "later", Status: StatusScheduled, PublishAt: &future}
+	repo.posts["draft"] = &Post{Id: "draft", Status: StatusDraft}
+	repo.posts["orig"] = &Post{Id: "orig", Status: StatusPublished}
+	repo.posts["crosspost"] = &Post{
+		Id: "crosspost", Status: StatusScheduled, PublishAt: &past, CrosspostOf: &CrosspostRef{Id: "orig"},
+	}
+	repo.posts["orphan"] = &Post{
+		Id: "orphan", Status: StatusScheduled, PublishAt: &past, CrosspostOf: &CrosspostRef{Id: "deleted"},
+	}
+
+	t.Run("should publish every due post once across the instances", func(t *testing.T) {
+		var wg sync.WaitGroup
+		counts := make([]int, 4)
+		for i := range counts {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				counts[i] = NewScheduler(repo, time.Minute).publish(ctx, now)
+			}(i)
+		}
+		wg.Wait()
+
+		total := 0
+		for _, c := range counts {
+			total += c
+		}
+		assert.Equal(t, 51, total)
+		assert.Equal(t, map[PostId]int{"orig": 1}, repo.crossposts, "crossposts are counted once")
+		assert.Equal(t, StatusScheduled, repo.posts["later"].Status)
+		assert.Equal(t, StatusDraft, repo.posts["draft"].Status)
+		assert.Equal(t, StatusDraft, repo.posts["orphan"].Status, "the crosspost of the deleted original is unscheduled")
+		assert.Zero(t, NewScheduler(repo, time.Minute).publish(ctx, now), "nothing is due anymore")
+	})
+
+	t.Run("should leave the post rescheduled after it was found due", func(t *testing.T) {
+		repo.posts["moved"] = &Post{Id: "moved", Status: StatusScheduled, PublishAt: &past}
+		due, _ := repo.GetDue(ctx, now, scheduleBatch)
+		repo.posts["moved"].PublishAt = &future
+
+		ok, err := repo.SetStatus(ctx, "moved", due[0].status(), PostStatus{Status: StatusPublished}, now)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, StatusScheduled, repo.posts["moved"].Status)
+	})
+}
+
+func TestRepoSetStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockUpdateResult := NewMockIMongoUpdateResult(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+	now := time.Now()
+	publishAt := now.Add(-time.Minute)
+
+	t.Run("should publish only the post still scheduled at the time read", func(t *testing.T) {
+		mockMongoColl.EXPECT().
+			UpdateOne(ctx,
+				bson.M{"id": PostId("1"), "status": StatusScheduled, "publishat": &publishAt, "deletedat": nil},
+				bson.M{"$set": bson.M{"status": StatusPublished, "created": now}, "$unset": bson.M{"publishat": ""}}).
+			Return(mockUpdateResult, nil)
+		mockUpdateResult.EXPECT().MatchedCount().Return(int64(0))
+
+		ok, err := repo.SetStatus(ctx, "1",
+			PostStatus{Status: StatusScheduled, PublishAt: &publishAt}, PostStatus{Status: StatusPublished}, now)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("should schedule the draft", func(t *testing.T) {
+		later := now.Add(time.Hour)
+		mockMongoColl.EXPECT().
+			UpdateOne(ctx,
+				bson.M{"id": PostId("1"), "status": StatusDraft, "publishat": (*time.Time)(nil), "deletedat": nil},
+				bson.M{"$set": bson.M{"status": StatusScheduled, "publishat": &later}}).
+			Return(mockUpdateResult, nil)
+		mockUpdateResult.EXPECT().MatchedCount().Return(int64(1))
+
+		ok, err := repo.SetStatus(ctx, "1",
+			PostStatus{Status: StatusDraft}, PostStatus{Status: StatusScheduled, PublishAt: &later}, now)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestRepoGetDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockCursor := NewMockIMongoCursor(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+	now := time.Now()
+
+	mockMongoColl.EXPECT().
+		Find(ctx, bson.M{"status": StatusScheduled, "publishat": bson.M{"$lte": now}, "deletedat": nil}, gomock.Any()).
+		Do(func(_ context.Context, _ interface{}, opts ...*options.FindOptions) {
+			assert.Equal(t, int64(10), *opts[0].Limit)
+			assert.Equal(t, bson.D{{Key: "publishat", Value: 1}}, opts[0].Sort)
+			assert.Equal(t, 1, opts[0].Projection.(bson.M)["status"], "the status is compared when publishing")
+		}).
+		Return(mockCursor, nil)
+	mockCursor.EXPECT().All(ctx, gomock.Any()).Return(nil)
+	mockCursor.EXPECT().Close(ctx).Return(nil)
+
+	_, err := repo.GetDue(ctx, now, 10)
+	assert.Nil(t, err)
+}
+
+func TestPrepareStatus(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	p := &Post{PublishAt: &later}
+	assert.Nil(t, p.prepareStatus(now))
+	assert.Equal(t, StatusPublished, p.Status)
+	assert.Nil(t, p.PublishAt)
+
+	p = &Post{Status: StatusScheduled, PublishAt: &later}
+	assert.Nil(t, p.prepareStatus(now))
+	assert.Equal(t, &later, p.PublishAt)
+
+	tooLate := now.Add(maxScheduleAhead + time.Hour)
+	for _, p := range []*Post{
+		{Status: StatusScheduled},
+		{Status: StatusScheduled, PublishAt: &now},
+		{Status: StatusScheduled, PublishAt: &tooLate},
+		{Status: "archived"},
+	} {
+		assert.ErrorIs(t, p.prepareStatus(now), ErrInvalidPost, p.Status)
+	}
+}
+
+func TestDraftVisibility(t *testing.T) {
+	author := &user.User{Id: "1"}
+	newPosts := func() []*Post {
+		return []*Post{
+			{Id: "1", Author: author, Status: StatusDraft},
+			{Id: "2", Author: author, Status: StatusScheduled},
+			{Id: "3", Author: author, Status: StatusPublished},
+			{Id: "4", Author: author},
+		}
+	}
+
+	own := &visibility{viewer: author}
+	assert.Len(t, own.posts(newPosts()), 4)
+	assert.Len(t, own.listing(newPosts()), 2, "listings never show the drafts")
+
+	other := &visibility{viewer: &user.User{Id: "2"}}
+	assert.Len(t, other.posts(newPosts()), 2)
+	assert.False(t, other.post(&Post{Author: author, Status: StatusDraft}))
+}
+
+type statusRepoStub struct {
+	IPostRepo
+	posts    map[PostId]*Post
+	from, to PostStatus
+}
+
+func (r *statusRepoStub) GetById(_ context.Context, id PostId) (*Post, error) {
+	if p, ok := r.posts[id]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("post: post not found: %w", mongo.ErrNoDocuments)
+}
+
+func (r *statusRepoStub) SetStatus(_ context.Context, _ PostId, from, to PostStatus, _ time.Time) (bool, error) {
+	r.from, r.to = from, to
+	return true, nil
+}
+
+func TestSetStatusHandler(t *testing.T) {
+	author := &user.User{Id: "1"}
+	publishAt := time.Now().Add(time.Hour)
+	repo := &statusRepoStub{posts: map[PostId]*Post{
+		"scheduled": {Id: "scheduled", Author: author, Status: StatusScheduled, PublishAt: &publishAt},
+		"published": {Id: "published", Author: author, Status: StatusPublished},
+		"orphan": {
+			Id: "orphan", Author: author, Status: StatusDraft, CrosspostOf: &CrosspostRef{Id: "deleted"},
+		},
+	}}
+	ph, _ := newTestHandler(repo, "mod")
+	body := `{"status": "published"}`
+
+	for name, tc := range map[string]struct {
+		postId string
+		viewer *user.User
+		code   int
+	}{
+		"anonymous":     {"scheduled", nil, http.StatusUnauthorized},
+		"not the owner": {"scheduled", &user.User{Id: "2"}, http.StatusForbidden},
+		"moderator":     {"scheduled", &user.User{Id: "mod"}, http.StatusForbidden},
+		"admin":         {"scheduled", &user.User{Id: "3", Admin: true}, http.StatusForbidden},
+		"published":     {"published", author, http.StatusConflict},
+		"unknown":       {"unknown", author, http.StatusNotFound},
+		"orphan":        {"orphan", author, http.StatusConflict},
+	} {
+		w := httptest.NewRecorder()
+		r := newTestRequest("POST", "/api/post/"+tc.postId+"/status", map[string]string{"post_id": tc.postId},
+			tc.viewer, strings.NewReader(body))
+		ph.SetStatus(w, r)
+		assert.Equal(t, tc.code, w.Code, name)
+	}
+	assert.Zero(t, repo.from, "nothing is changed")
+
+	w := httptest.NewRecorder()
+	r := newTestRequest("POST", "/api/post/scheduled/status", map[string]string{"post_id": "scheduled"},
+		author, strings.NewReader(body))
+	ph.SetStatus(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, PostStatus{Status: StatusScheduled, PublishAt: &publishAt}, repo.from,
+		"should change only the post scheduled at the time read")
+	assert.Equal(t, PostStatus{Status: StatusPublished}, repo.to)
+}
@@ -0,0 +1,260 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"crud/pkg/comment"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+)
+
+const (
+	// Characters of context around the first match in the snippet.
+	snippetBefore = 60
+	snippetAfter  = 140
+	// Max comment snippets per post.
+	maxCommentSnippets = 3
+)
+
+type ISearchRepo interface {
+	Search(context.Context, *SearchQuery) ([]*SearchHit, error)
+}
+
+// SearchQuery is the full-text query with its filters from `GET /api/search`.
+type SearchQuery struct {
+	Text     string
+	Category string
+	Author   string
+	Type     string
+	// Zero times don't limit the range.
+	From time.Time
+	To   time.Time
+	// Newest first instead of the most relevant first.
+	SortByDate bool
+	Page       Page
+
+	// The viewer whose invisible posts the query skips, so the pages come full.
+	visibility *visibility
+}
+
+type SearchHit struct {
+	Post      *Post      `json:"post"`
+	Relevance float64    `json:"relevance"`
+	Snippets  []*Snippet `json:"snippets"`
+}
+
+// Snippet is the part of the post or comment with the matched words
+// wrapped in <mark>, the rest of the text is HTML escaped.
+type Snippet struct {
+	// title, text or comment
+	Field     string            `json:"field"`
+	CommentId comment.CommentId `json:"commentId,omitempty"`
+	Text      string            `json:"text"`
+}
+
+// Searches posts and comments:
+// `?q=&category=&author=&type=text|link|poll|image&from=&to=&sort=relevance|new&limit=&offset=`,
+// dates are RFC 3339 or YYYY-MM-DD.
+func (ph *PostHandler) Search(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q, err := parseSearchQuery(r)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vis, err := ph.visibility(r.Context())
+	if err == nil {
+		err = ph.moderateListing(r.Context(), vis, q.Category)
+	}
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't check posts visibility: %v", err)
+		WriteMsg(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+	q.visibility = vis
+
+	hits, err := ph.Searcher.Search(r.Context(), q)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't search posts for %q: %v", q.Text, err)
+		WriteMsg(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+
+	terms := searchTerms(q.Text)
+	visible := make([]*SearchHit, 0, len(hits))
+	for _, h := range hits {
+		if len(vis.listing([]*Post{h.Post})) == 0 {
+			continue
+		}
+		h.Snippets = snippets(h.Post, terms)
+		visible = append(visible, h)
+	}
+	WriteRespJSON(w, visible)
+}
+
+func parseSearchQuery(r *http.Request) (*SearchQuery, error) {
+	params := r.URL.Query()
+	q := &SearchQuery{
+		Text:     strings.TrimSpace(params.Get("q")),
+		Category: params.Get("category"),
+		Author:   params.Get("author"),
+		Type:     params.Get("type"),
+	}
+	if q.Text == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if q.Type != "" && !IsPostType(q.Type) {
+		return nil, fmt.Errorf("unknown post type %q", q.Type)
+	}
+	switch params.Get("sort") {
+	case "", "relevance":
+	case "new":
+		q.SortByDate = true
+	default:
+		return nil, fmt.Errorf("sort must be relevance or new")
+	}
+
+	var err error
+	if q.From, err = parseSearchDate(params.Get("from"), false); err != nil {
+		return nil, err
+	}
+	if q.To, err = parseSearchDate(params.Get("to"), true); err != nil {
+		return nil, err
+	}
+	if q.Page, err = ParsePage(r); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Dates without time cover the whole day when they end the range.
+func parseSearchDate(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad date %q, use YYYY-MM-DD or RFC 3339", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// Lowercased words of the query to highlight, negated words are skipped.
+func searchTerms(query string) []string {
+	terms := []string{}
+	for _, f := range strings.Fields(strings.ToLower(query)) {
+		if strings.HasPrefix(f, "-") {
+			continue
+		}
+		for _, w := range wordPattern.FindAllString(f, -1) {
+			terms = append(terms, stem(w))
+		}
+	}
+	return terms
+}
+
+// Cuts common English endings so "posts" highlights "posting",
+// close enough to the stemming of the text index.
+func stem(w string) string {
+	for _, suffix := range []string{"ing", "es", "ed", "s"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 {
+			return strings.TrimSuffix(w, suffix)
+		}
+	}
+	return w
+}
+
+func snippets(p *Post, terms []string) []*Snippet {
+	res := []*Snippet{}
+	if s, ok := highlight(p.Title, terms); ok {
+		res = append(res, &Snippet{Field: "title", Text: s})
+	}
+	if s, ok := highlight(p.Text, terms); ok {
+		res = append(res, &Snippet{Field: "text", Text: s})
+	}
+	for _, c := range p.Comments {
+		if len(res) >= maxCommentSnippets+2 {
+			break
+		}
+		if s, ok := highlight(c.Body, terms); ok {
+			res = append(res, &Snippet{Field: "comment", CommentId: c.Id, Text: s})
+		}
+	}
+	return res
+}
+
+// Returns the part of the text around the first match with the matched words marked.
+func highlight(text string, terms []string) (string, bool) {
+	words := wordPattern.FindAllStringIndex(text, -1)
+	matched := make([]bool, len(words))
+	first := -1
+	for i, loc := range words {
+		word := strings.ToLower(text[loc[0]:loc[1]])
+		for _, t := range terms {
+			if strings.HasPrefix(word, t) {
+				matched[i] = true
+				break
+			}
+		}
+		if matched[i] && first < 0 {
+			first = i
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+	for i := first; i >= 0 && words[i][0] >= words[first][0]-snippetBefore; i-- {
+		start = words[i][0]
+	}
+	for i := first; i < len(words) && words[i][1] <= words[first][1]+snippetAfter; i++ {
+		end = words[i][1]
+	}
+	// Keep the punctuation around the text when the snippet reaches its edges.
+	if start == words[0][0] {
+		start = 0
+	}
+	if end == words[len(words)-1][1] {
+		end = len(text)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for i, loc := range words {
+		if loc[0] < start || loc[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:loc[0]]))
+		word := html.EscapeString(text[loc[0]:loc[1]])
+		if matched[i] {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+		pos = loc[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
@@ -0,0 +1,149 @@
+package post
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"crud/pkg/common"
+
+	"crud/pkg/comment"
+)
+
+func TestHighlight(t *testing.T) {
+	terms := searchTerms(`Posts -spam "golang"`)
+	assert.Equal(t, []string{"post", "golang"}, terms)
+
+	t.Run("should mark matched words and escape the rest", func(t *testing.T) {
+		s, ok := highlight("<b>Posting</b> about Golang!", terms)
+		assert.True(t, ok)
+		assert.Equal(t, "&lt;b&gt;<mark>Posting</mark>&lt;/b&gt; about <mark>Golang</mark>!", s)
+	})
+
+	t.Run("should cut long texts around the first match", func(t *testing.T) {
+		text := strings.Repeat("lorem ", 30) + "golang" + strings.Repeat(" ipsum", 40)
+		s, ok := highlight(text, terms)
+		assert.True(t, ok)
+		assert.True(t, strings.HasPrefix(s, "…lorem"))
+		assert.True(t, strings.HasSuffix(s, "ipsum…"))
+		assert.Contains(t, s, "<mark>golang</mark>")
+	})
+
+	t.Run("should skip texts without matches", func(t *testing.T) {
+		_, ok := highlight("nothing here", terms)
+		assert.False(t, ok)
+	})
+
+	t.Run("should collect snippets of the post and comments", func(t *testing.T) {
+		p := &Post{Title: "Golang", Text: "none", Comments: []*comment.Comment{
+			{Id: "1", Body: "no"}, {Id: "2", Body: "my post"},
+		}}
+		assert.Equal(t, []*Snippet{
+			{Field: "title", Text: "<mark>Golang</mark>"},
+			{Field: "comment", CommentId: "2", Text: "my <mark>post</mark>"},
+		}, snippets(p, terms))
+	})
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/search?q=go&type=link&from=2022-01-01&to=2022-01-31&sort=new&limit=10", nil)
+	q, err := parseSearchQuery(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "go", q.Text)
+	assert.True(t, q.SortByDate)
+	assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), q.From)
+	assert.Equal(t, time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), q.To)
+	assert.Equal(t, 10, q.Page.Limit)
+
+	for _, bad := range []string{"", "q=go&type=video", "q=go&sort=top", "q=go&from=yesterday"} {
+		_, err := parseSearchQuery(httptest.NewRequest("GET", "/api/search?"+bad, nil))
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestRepoSearch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockCursor := NewMockIMongoCursor(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+
+	vis := &visibility{moderator: true, blocked: map[string]struct{}{"3": {}}}
+	q := &SearchQuery{Text: "golang", Category: "programming", Page: common.Page{Limit: 10, Offset: 10}, visibility: vis}
+	mockMongoColl.EXPECT().
+		Find(ctx, bson.M{
+			"$text":     bson.M{"$search": "golang"},
+			"deletedat": nil,
+			"status":    published,
+			"category":  "programming",
+			"$and":      bson.A{bson.M{"author.id": bson.M{"$nin": []string{"3"}}}},
+		}, gomock.Any()).
+		Do(func(_ context.Context, _ interface{}, opts ...*options.FindOptions) {
+			assert.Equal(t, int64(10), *opts[0].Skip)
+			assert.Equal(t, int64(10), *opts[0].Limit)
+		}).
+		Return(mockCursor, nil)
+	mockCursor.EXPECT().All(ctx, gomock.Any()).Return(nil)
+	mockCursor.EXPECT().Close(ctx).Return(nil)
+
+	hits, err := repo.Search(ctx, q)
+	assert.Nil(t, err)
+	assert.Empty(t, hits)
+}
+
+func TestRepoSearchText(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockUpdateResult := NewMockIMongoUpdateResult(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+
+	t.Run("should index only the comments everyone sees", func(t *testing.T) {
+		text := searchTextUpdate[0].(bson.M)["$set"].(bson.M)["searchtext"].(bson.M)["$reduce"].(bson.M)
+		assert.Equal(t, (*visibility)(nil).comments(), text["input"])
+	})
+
+	t.Run("should reindex the comments of the post after moderating one", func(t *testing.T) {
+		gomock.InOrder(
+			mockMongoColl.EXPECT().
+				UpdateOne(ctx, bson.M{"id": PostId("1"), "comments.id": comment.CommentId("c1")}, gomock.Any()).
+				Return(mockUpdateResult, nil),
+			mockMongoColl.EXPECT().
+				UpdateOne(ctx, bson.M{"id": PostId("1")}, searchTextUpdate).
+				Return(mockUpdateResult, nil),
+		)
+
+		assert.Nil(t, repo.SetCommentFlags(ctx, "1", "c1", map[PostFlag]bool{FlagRemoved: true}))
+	})
+
+	t.Run("should replace the old index of every comment body", func(t *testing.T) {
+		models := []mongo.IndexModel{}
+		mockMongoColl.EXPECT().DropIndex(ctx, "search").Return(mongo.CommandError{Code: indexNotFound})
+		mockMongoColl.EXPECT().
+			CreateIndex(ctx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, model mongo.IndexModel) (string, error) {
+				models = append(models, model)
+				return "", nil
+			}).
+			AnyTimes()
+
+		assert.Nil(t, repo.EnsureIndexes(ctx))
+		assert.Equal(t, bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "text", Value: "text"},
+			{Key: "searchtext", Value: "text"},
+		}, models[0].Keys)
+	})
+}
@@ -0,0 +1,107 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"crud/pkg/comment"
+	"crud/pkg/logger"
+	"crud/pkg/report"
+	"crud/pkg/spam"
+)
+
+type ISpamClassifier interface {
+	Check(*spam.Doc) (float64, bool)
+	Learn(ctx context.Context, doc *spam.Doc, isSpam bool) error
+}
+
+func (p *Post) spamDoc() *spam.Doc {
+	return &spam.Doc{Title: p.Title, Text: p.Text, URL: p.URL}
+}
+
+func commentSpamDoc(c *comment.Comment) *spam.Doc {
+	return &spam.Doc{Text: c.Body}
+}
+
+// Puts the content held as spam into the moderation queue.
+func (ph *PostHandler) holdSpam(r *http.Request, category string, targetType report.TargetType,
+	postId PostId, targetId string, score float64,
+) {
+	logger.Log(r.Context()).Infof("holding %s %s in %s as spam, score %.2f", targetType, targetId, category, score)
+	rep := &report.Report{
+		Category:   category,
+		TargetType: targetType,
+		TargetId:   targetId,
+		PostId:     string(postId),
+		Reason:     report.ReasonSpam,
+		Text:       fmt.Sprintf("spam score %.2f", score),
+		Created:    time.Now(),
+	}
+	if err := ph.Reports.Add(r.Context(), rep); err != nil {
+		logger.Log(r.Context()).Errorf("can't file spam report for %s: %v", targetId, err)
+	}
+}
+
+const (
+	spamLabelSpam = "spam"
+	spamLabelHam  = "ham"
+)
+
+// Removals for spam teach the classifier spam, approvals teach it ham.
+func (ph *PostHandler) learnFromAction(r *http.Request, action, reason string,
+	postId PostId, commentId comment.CommentId, doc *spam.Doc,
+) {
+	switch {
+	case action == "remove" && isSpamReason(reason):
+		ph.learnSpam(r, postId, commentId, doc, true)
+	case action == "approve":
+		ph.learnSpam(r, postId, commentId, doc, false)
+	}
+}
+
+// The removal reasons are free text, only the ones saying spam count.
+func isSpamReason(reason string) bool {
+	return strings.EqualFold(strings.TrimSpace(reason), string(report.ReasonSpam))
+}
+
+// Teaches the classifier the post or, for the non-empty commentId, its comment.
+// The first decision on the content is learned, the later ones are skipped.
+func (ph *PostHandler) learnSpam(r *http.Request, postId PostId, commentId comment.CommentId,
+	doc *spam.Doc, isSpam bool,
+) {
+	label := spamLabelHam
+	if isSpam {
+		label = spamLabelSpam
+	}
+	first, err := ph.PostRepo.SetSpamLabel(r.Context(), postId, commentId, label)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't label %s %s as %s: %v", postId, commentId, label, err)
+		return
+	}
+	if !first {
+		return
+	}
+	if err := ph.Spam.Learn(r.Context(), doc, isSpam); err != nil {
+		logger.Log(r.Context()).Errorf("can't train spam classifier: %v", err)
+	}
+}
+
+// Returns the posts and comments the classifier learned from the moderators,
+// with the labels it learned them with.
+func SpamSamples(posts []*Post) []spam.Sample {
+	samples := []spam.Sample{}
+	for _, p := range posts {
+		if p.SpamLabel != "" {
+			samples = append(samples, spam.Sample{Doc: p.spamDoc(), Spam: p.SpamLabel == spamLabelSpam})
+		}
+		for _, c := range p.Comments {
+			if c.SpamLabel != "" {
+				samples = append(samples, spam.Sample{Doc: commentSpamDoc(c), Spam: c.SpamLabel == spamLabelSpam})
+			}
+		}
+	}
+	return samples
+}
@@ -0,0 +1,123 @@
+package post
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+
+	"crud/pkg/comment"
+	"crud/pkg/logger"
+	"crud/pkg/spam"
+)
+
+type spamRepoStub struct {
+	IPostRepo
+	labels map[string]string
+}
+
+func (r *spamRepoStub) SetSpamLabel(_ context.Context, id PostId, commentId comment.CommentId, label string) (bool, error) {
+	key := string(id) + "/" + string(commentId)
+	if r.labels[key] != "" {
+		return false, nil
+	}
+	r.labels[key] = label
+	return true, nil
+}
+
+type classifierStub struct {
+	learned []bool
+}
+
+func (c *classifierStub) Check(*spam.Doc) (float64, bool) {
+	return 0, false
+}
+
+func (c *classifierStub) Learn(_ context.Context, _ *spam.Doc, isSpam bool) error {
+	c.learned = append(c.learned, isSpam)
+	return nil
+}
+
+func TestLearnFromAction(t *testing.T) {
+	ctx := context.WithValue(context.Background(), logger.LoggerKey, zap.NewNop().Sugar())
+	r := httptest.NewRequest("POST", "/", nil).WithContext(ctx)
+	doc := &spam.Doc{Text: "cheap pills"}
+
+	t.Run("should learn only the removals for spam", func(t *testing.T) {
+		classifier := &classifierStub{}
+		ph := &PostHandler{PostRepo: &spamRepoStub{labels: map[string]string{}}, Spam: classifier}
+		ph.learnFromAction(r, "remove", "off-topic", "1", "", doc)
+		ph.learnFromAction(r, "remove", " Spam ", "2", "", doc)
+		ph.learnFromAction(r, "pin", "spam", "3", "", doc)
+		ph.learnFromAction(r, "approve", "", "4", "c1", doc)
+		assert.Equal(t, []bool{true, false}, classifier.learned)
+	})
+
+	t.Run("should learn every content once", func(t *testing.T) {
+		classifier := &classifierStub{}
+		ph := &PostHandler{PostRepo: &spamRepoStub{labels: map[string]string{}}, Spam: classifier}
+		ph.learnFromAction(r, "remove", "spam", "1", "", doc)
+		ph.learnFromAction(r, "remove", "spam", "1", "", doc)
+		ph.learnFromAction(r, "approve", "", "1", "", doc)
+		ph.learnFromAction(r, "approve", "", "1", "c1", doc)
+		ph.learnFromAction(r, "approve", "", "1", "c1", doc)
+		assert.Equal(t, []bool{true, false}, classifier.learned)
+	})
+}
+
+func TestSetSpamLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockUpdateResult := NewMockIMongoUpdateResult(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+	unlabeled := bson.M{"$in": bson.A{nil, ""}}
+
+	t.Run("should label the unlabeled post", func(t *testing.T) {
+		mockMongoColl.EXPECT().
+			UpdateOne(ctx,
+				bson.M{"id": PostId("1"), "spamlabel": unlabeled},
+				bson.M{"$set": bson.M{"spamlabel": spamLabelSpam}}).
+			Return(mockUpdateResult, nil)
+		mockUpdateResult.EXPECT().MatchedCount().Return(int64(1))
+
+		first, err := repo.SetSpamLabel(ctx, "1", "", spamLabelSpam)
+		assert.Nil(t, err)
+		assert.True(t, first)
+	})
+
+	t.Run("should skip the labeled comment", func(t *testing.T) {
+		mockMongoColl.EXPECT().
+			UpdateOne(ctx,
+				bson.M{"id": PostId("1"), "comments": bson.M{"$elemMatch": bson.M{
+					"id": comment.CommentId("c1"), "spamlabel": unlabeled,
+				}}},
+				bson.M{"$set": bson.M{"comments.$.spamlabel": spamLabelHam}}).
+			Return(mockUpdateResult, nil)
+		mockUpdateResult.EXPECT().MatchedCount().Return(int64(0))
+
+		first, err := repo.SetSpamLabel(ctx, "1", "c1", spamLabelHam)
+		assert.Nil(t, err)
+		assert.False(t, first)
+	})
+}
+
+func TestSpamSamples(t *testing.T) {
+	posts := []*Post{
+		{Title: "pills", SpamLabel: spamLabelSpam, Comments: []*comment.Comment{
+			{Body: "thanks", SpamLabel: spamLabelHam},
+			{Body: "removed as rude", Removed: true},
+		}},
+		{Title: "off-topic", Removed: true},
+	}
+	samples := SpamSamples(posts)
+	assert.Len(t, samples, 2)
+	assert.True(t, samples[0].Spam)
+	assert.False(t, samples[1].Spam)
+}
@@ -0,0 +1,96 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/category"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+)
+
+const maxTags = 5
+
+// Letters, digits, dashes and underscores, starting with a letter or a digit.
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_-]{0,31}$`)
+
+type IFlairRepo interface {
+	GetFlair(ctx context.Context, category string) ([]*category.Flair, error)
+}
+
+// TagCount is the number of the visible category posts with the tag.
+type TagCount struct {
+	Tag   string `json:"tag" bson:"tag"`
+	Count int    `json:"count" bson:"count"`
+}
+
+// Lowercases the tags without the leading "#" and drops the repeated ones.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t, err := normalizeTag(t)
+		if err != nil {
+			return nil, err
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("%w: at most %d tags allowed", ErrInvalidPost, maxTags)
+	}
+	return normalized, nil
+}
+
+func normalizeTag(tag string) (string, error) {
+	t := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if !tagPattern.MatchString(t) {
+		return "", fmt.Errorf("%w: bad tag %q", ErrInvalidPost, tag)
+	}
+	return t, nil
+}
+
+// Returns the category flair with the text, nil for the empty text.
+func (ph *PostHandler) resolveFlair(ctx context.Context, slug, text string) (*category.Flair, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	flair, err := ph.Flair.GetFlair(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("post/tags: can't load flair of %s: %w", slug, err)
+	}
+	f := category.FindFlair(flair, text)
+	if f == nil {
+		return nil, fmt.Errorf("%w: %s has no flair %q", ErrInvalidPost, slug, text)
+	}
+	return f, nil
+}
+
+// Lists the tags of the category, the most used first.
+func (ph PostHandler) PopularTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	slug := mux.Vars(r)["category"]
+	page, err := ParsePage(r)
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := ph.PostRepo.PopularTags(r.Context(), slug, page)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load tags of %s: %v", slug, err)
+		WriteMsg(w, "failed loading tags", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, tags)
+}
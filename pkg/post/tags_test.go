@@ -0,0 +1,60 @@
+package post
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/common"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	t.Run("should lowercase and dedupe the tags", func(t *testing.T) {
+		tags, err := normalizeTags([]string{" Go ", "#golang", "go", "проза", "web-dev"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"go", "golang", "проза", "web-dev"}, tags)
+	})
+
+	t.Run("should never return nil", func(t *testing.T) {
+		tags, err := normalizeTags(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, tags)
+	})
+
+	t.Run("should reject bad tags", func(t *testing.T) {
+		for _, tags := range [][]string{
+			{""}, {"#"}, {"two words"}, {"-dash"}, {"a.b"}, {strings.Repeat("x", 33)},
+			{"a", "b", "c", "d", "e", "f"},
+		} {
+			_, err := normalizeTags(tags)
+			assert.ErrorIs(t, err, ErrInvalidPost, tags)
+		}
+	})
+}
+
+func TestPopularTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockMongoColl := NewMockIMongoCollection(ctrl)
+	mockCursor := NewMockIMongoCursor(ctrl)
+	repo := &Repo{posts: mockMongoColl}
+
+	expected := []*TagCount{{Tag: "go", Count: 3}, {Tag: "rust", Count: 1}}
+	mockMongoColl.EXPECT().
+		Aggregate(ctx, gomock.Any()).
+		Return(mockCursor, nil)
+	mockCursor.EXPECT().
+		All(ctx, gomock.AssignableToTypeOf(&expected)).
+		SetArg(1, expected).
+		Return(nil)
+	mockCursor.EXPECT().Close(ctx).Return(nil)
+
+	tags, err := repo.PopularTags(ctx, "music", common.Page{Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, tags)
+}
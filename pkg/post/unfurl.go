@@ -0,0 +1,64 @@
+package post
+
+import (
+	"context"
+	"time"
+
+	"crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/unfurl"
+)
+
+type IUnfurlRepo interface {
+	GetUnfurlPending(ctx context.Context, limit int) ([]*Post, error)
+	SetPreview(context.Context, PostId, *unfurl.Preview) error
+}
+
+type IPreviewFetcher interface {
+	Fetch(ctx context.Context, url string) (*unfurl.Preview, error)
+}
+
+// Posts unfurled per tick, the fetches are sequential.
+const unfurlBatch = 20
+
+// Unfurler periodically fetches the previews of the new link posts.
+type Unfurler struct {
+	repo    IUnfurlRepo
+	fetcher IPreviewFetcher
+	every   time.Duration
+}
+
+func NewUnfurler(repo IUnfurlRepo, fetcher IPreviewFetcher, every time.Duration) *Unfurler {
+	return &Unfurler{
+		repo:    repo,
+		fetcher: fetcher,
+		every:   every,
+	}
+}
+
+// Unfurls the new link posts until the context is done.
+func (u *Unfurler) Run(ctx context.Context) {
+	common.RunPeriodically(ctx, u.every, u.unfurl)
+}
+
+// Pages that can't be fetched get the failed preview and aren't retried.
+func (u *Unfurler) unfurl(ctx context.Context, now time.Time) {
+	posts, err := u.repo.GetUnfurlPending(ctx, unfurlBatch)
+	if err != nil {
+		logger.Log(ctx).Errorf("can't load posts to unfurl: %v", err)
+		return
+	}
+	for _, p := range posts {
+		preview, err := u.fetcher.Fetch(ctx, p.URL)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Log(ctx).Infof("can't unfurl %s of post %s: %v", p.URL, p.Id, err)
+			preview = &unfurl.Preview{FetchedAt: now, Failed: true}
+		}
+		if err := u.repo.SetPreview(ctx, p.Id, preview); err != nil {
+			logger.Log(ctx).Errorf("can't save preview of post %s: %v", p.Id, err)
+		}
+	}
+}
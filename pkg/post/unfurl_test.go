@@ -0,0 +1,54 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"crud/pkg/logger"
+	"crud/pkg/unfurl"
+)
+
+type unfurlRepoStub struct {
+	pending  []*Post
+	previews map[PostId]*unfurl.Preview
+}
+
+func (r *unfurlRepoStub) GetUnfurlPending(context.Context, int) ([]*Post, error) {
+	return r.pending, nil
+}
+
+func (r *unfurlRepoStub) SetPreview(_ context.Context, id PostId, p *unfurl.Preview) error {
+	r.previews[id] = p
+	return nil
+}
+
+type fetcherStub map[string]*unfurl.Preview
+
+func (f fetcherStub) Fetch(_ context.Context, url string) (*unfurl.Preview, error) {
+	if p, ok := f[url]; ok {
+		return p, nil
+	}
+	return nil, errors.New("unreachable")
+}
+
+func TestUnfurler(t *testing.T) {
+	now := time.Now()
+	repo := &unfurlRepoStub{
+		pending: []*Post{
+			{Id: "1", URL: "https://go.dev/"},
+			{Id: "2", URL: "https://down.example/"},
+		},
+		previews: map[PostId]*unfurl.Preview{},
+	}
+	fetcher := fetcherStub{"https://go.dev/": {Title: "Go", FetchedAt: now}}
+
+	ctx := context.WithValue(context.Background(), logger.LoggerKey, zap.NewNop().Sugar())
+	NewUnfurler(repo, fetcher, time.Minute).unfurl(ctx, now)
+	assert.Equal(t, &unfurl.Preview{Title: "Go", FetchedAt: now}, repo.previews["1"])
+	assert.Equal(t, &unfurl.Preview{FetchedAt: now, Failed: true}, repo.previews["2"], "failed pages aren't retried")
+}
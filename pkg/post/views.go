@@ -0,0 +1,265 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+)
+
+const (
+	dayLayout = "2006-01-02"
+	// Max days of the view stats.
+	maxStatsDays = 90
+)
+
+// DailyViews is the number of views of the post during the UTC day.
+type DailyViews struct {
+	PostId PostId `json:"-" bson:"postid"`
+	Day    string `json:"day" bson:"day"`
+	Views  int    `json:"views" bson:"views"`
+}
+
+type viewKey struct {
+	postId PostId
+	day    string
+}
+
+// viewBatch is the buffered views: per post and day for the stats, per post for the counters.
+type viewBatch struct {
+	daily map[viewKey]int
+	posts map[PostId]int
+}
+
+func newViewBatch() *viewBatch {
+	return &viewBatch{
+		daily: map[viewKey]int{},
+		posts: map[PostId]int{},
+	}
+}
+
+func (b *viewBatch) add(key viewKey, n int) {
+	b.daily[key] += n
+	b.posts[key.postId] += n
+}
+
+func (b *viewBatch) merge(other *viewBatch) {
+	for key, n := range other.daily {
+		b.daily[key] += n
+	}
+	for id, n := range other.posts {
+		b.posts[id] += n
+	}
+}
+
+func (b *viewBatch) empty() bool {
+	return len(b.daily) == 0 && len(b.posts) == 0
+}
+
+type IViewRepo interface {
+	// Deletes the written views from the batch, so on failure it keeps the unwritten ones.
+	AddViews(context.Context, *viewBatch) error
+	GetDailyViews(ctx context.Context, postId PostId, since time.Time) ([]*DailyViews, error)
+}
+
+// ViewCounter counts the post views, the repeated views of the same viewer
+// within the window are counted once. Views are buffered and written by Flush.
+type ViewCounter struct {
+	repo   IViewRepo
+	window time.Duration
+
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	pending *viewBatch
+}
+
+func NewViewCounter(repo IViewRepo, window time.Duration) *ViewCounter {
+	return &ViewCounter{
+		repo:    repo,
+		window:  window,
+		seen:    map[string]time.Time{},
+		pending: newViewBatch(),
+	}
+}
+
+// Records the view of the post by the viewer, the user Id or IP.
+func (vc *ViewCounter) Record(postId PostId, viewer string, now time.Time) {
+	key := string(postId) + "|" + viewer
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if last, ok := vc.seen[key]; ok && now.Sub(last) < vc.window {
+		return
+	}
+	vc.seen[key] = now
+	vc.pending.add(viewKey{postId, now.UTC().Format(dayLayout)}, 1)
+}
+
+// Writes the buffered views and forgets the viewers outside the window.
+func (vc *ViewCounter) Flush(ctx context.Context, now time.Time) error {
+	vc.mu.Lock()
+	pending := vc.pending
+	vc.pending = newViewBatch()
+	for key, last := range vc.seen {
+		if now.Sub(last) >= vc.window {
+			delete(vc.seen, key)
+		}
+	}
+	vc.mu.Unlock()
+
+	if pending.empty() {
+		return nil
+	}
+	if err := vc.repo.AddViews(ctx, pending); err != nil {
+		// Keep the unwritten views for the next flush.
+		vc.mu.Lock()
+		vc.pending.merge(pending)
+		vc.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Flushes the views periodically until the context is done, then flushes the rest
+// within the timeout. Run the counter with the context done on shutdown.
+func (vc *ViewCounter) Run(ctx context.Context, every, finalTimeout time.Duration) {
+	RunPeriodically(ctx, every, vc.flush)
+	flushCtx, cancel := context.WithTimeout(context.Background(), finalTimeout)
+	defer cancel()
+	vc.flush(flushCtx, time.Now())
+}
+
+func (vc *ViewCounter) flush(ctx context.Context, now time.Time) {
+	if err := vc.Flush(ctx, now); err != nil {
+		logger.Log(ctx).Errorf("can't flush post views: %v", err)
+	}
+}
+
+// Authenticated viewers are told apart by the user Id, anonymous ones by IP.
+func viewerKey(r *http.Request) string {
+	if u, err := sessions.GetAuthUser(r.Context()); err == nil {
+		return "u:" + u.Id
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+// Daily views of the post for its author, `?days=30`.
+func (ph *PostHandler) ViewStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		days, err = strconv.Atoi(d)
+		if err != nil || days < 1 || days > maxStatsDays {
+			WriteMsg(w, fmt.Sprintf("days must be a number from 1 to %d", maxStatsDays), http.StatusBadRequest)
+			return
+		}
+	}
+
+	postId := PostId(mux.Vars(r)["post_id"])
+	post, err := ph.PostRepo.GetById(r.Context(), postId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't get post with id %s: %v", postId, err)
+		WriteMsg(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if post.Author == nil || post.Author.Id != authUser.Id {
+		WriteMsg(w, "only the author can see the view stats", http.StatusForbidden)
+		return
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days+1)
+	stats, err := ph.Views.repo.GetDailyViews(r.Context(), postId, since)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load view stats of %s: %v", postId, err)
+		WriteMsg(w, "failed loading view stats", http.StatusInternalServerError)
+		return
+	}
+
+	WriteRespJSON(w, struct {
+		Total int           `json:"total"`
+		Daily []*DailyViews `json:"daily"`
+	}{post.Views, stats})
+}
+
+// ViewRepo stores the view counters of the posts and their daily stats.
+type ViewRepo struct {
+	posts IMongoCollection
+	views IMongoCollection
+}
+
+func NewViewRepo(postsCol, viewsCol *mongo.Collection) *ViewRepo {
+	return &ViewRepo{
+		posts: &MongoCollection{Coll: postsCol},
+		views: &MongoCollection{Coll: viewsCol},
+	}
+}
+
+func (r *ViewRepo) EnsureIndexes(ctx context.Context) error {
+	_, err := r.views.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "postid", Value: 1}, {Key: "day", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("post/views: failed creating views index: %w", err)
+	}
+	return nil
+}
+
+// Increments the daily views and the view counters of the posts, every written
+// increment is deleted from the batch.
+func (r *ViewRepo) AddViews(ctx context.Context, views *viewBatch) error {
+	for key, n := range views.daily {
+		_, err := r.views.UpdateOne(ctx,
+			bson.M{"postid": key.postId, "day": key.day},
+			bson.M{"$inc": bson.M{"views": n}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return fmt.Errorf("post/views: failed adding daily views: %w", err)
+		}
+		delete(views.daily, key)
+	}
+	for id, n := range views.posts {
+		_, err := r.posts.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$inc": bson.M{"views": n}})
+		if err != nil {
+			return fmt.Errorf("post/views: failed adding post views: %w", err)
+		}
+		delete(views.posts, id)
+	}
+	return nil
+}
+
+func (r *ViewRepo) GetDailyViews(ctx context.Context, postId PostId, since time.Time) ([]*DailyViews, error) {
+	filter := bson.M{"postid": postId, "day": bson.M{"$gte": since.Format(dayLayout)}}
+	cursor, err := r.views.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "day", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("post/views: failed finding views: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	stats := []*DailyViews{}
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, fmt.Errorf("post/views: failed geting views from cursor: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,192 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"crud/pkg/user"
+)
+
+type viewRepoStub struct {
+	added map[viewKey]int
+	posts map[PostId]int
+	err   error
+	// Writes that many daily views before failing with err.
+	failAfter int
+	// The start of the last stats loaded.
+	since time.Time
+}
+
+func (r *viewRepoStub) AddViews(_ context.Context, views *viewBatch) error {
+	for k, n := range views.daily {
+		if r.err != nil && r.failAfter == 0 {
+			return r.err
+		}
+		r.failAfter--
+		r.added[k] += n
+		delete(views.daily, k)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	for id, n := range views.posts {
+		r.posts[id] += n
+		delete(views.posts, id)
+	}
+	return nil
+}
+
+func (r *viewRepoStub) GetDailyViews(_ context.Context, postId PostId, since time.Time) ([]*DailyViews, error) {
+	r.since = since
+	return []*DailyViews{{PostId: postId, Day: since.Format(dayLayout), Views: 1}}, nil
+}
+
+func TestViewCounter(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := "2022-03-01"
+	repo := &viewRepoStub{added: map[viewKey]int{}, posts: map[PostId]int{}}
+	vc := NewViewCounter(repo, 30*time.Minute)
+
+	vc.Record("1", "u:1", now)
+	vc.Record("1", "u:1", now.Add(10*time.Minute))
+	vc.Record("1", "ip:127.0.0.1", now)
+	vc.Record("2", "u:1", now)
+
+	t.Run("should count repeated views once within the window", func(t *testing.T) {
+		assert.Nil(t, vc.Flush(context.Background(), now))
+		assert.Equal(t, map[viewKey]int{{"1", day}: 2, {"2", day}: 1}, repo.added)
+	})
+
+	t.Run("should count views again after the window", func(t *testing.T) {
+		later := now.Add(40 * time.Minute)
+		assert.Nil(t, vc.Flush(context.Background(), later))
+		vc.Record("1", "u:1", later)
+		assert.Nil(t, vc.Flush(context.Background(), later))
+		assert.Equal(t, 3, repo.added[viewKey{"1", day}])
+	})
+
+	t.Run("should keep views when flushing fails", func(t *testing.T) {
+		vc.Record("3", "u:1", now)
+		repo.err = errors.New("down")
+		assert.NotNil(t, vc.Flush(context.Background(), now))
+		repo.err = nil
+		assert.Nil(t, vc.Flush(context.Background(), now))
+		assert.Equal(t, 1, repo.added[viewKey{"3", day}])
+		assert.Equal(t, 1, repo.posts["3"])
+	})
+
+	t.Run("should keep only the unwritten views when flushing fails halfway", func(t *testing.T) {
+		vc.Record("4", "u:1", now)
+		vc.Record("5", "u:1", now)
+		repo.err, repo.failAfter = errors.New("down"), 1
+		assert.NotNil(t, vc.Flush(context.Background(), now))
+		repo.err = nil
+		assert.Nil(t, vc.Flush(context.Background(), now))
+		assert.Equal(t, 1, repo.added[viewKey{"4", day}])
+		assert.Equal(t, 1, repo.added[viewKey{"5", day}])
+		assert.Equal(t, 1, repo.posts["4"])
+		assert.Equal(t, 1, repo.posts["5"])
+	})
+
+	t.Run("should flush the rest when the context is done", func(t *testing.T) {
+		vc.Record("6", "u:1", now)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		vc.Run(ctx, time.Hour, time.Second)
+		assert.Equal(t, 1, repo.posts["6"])
+	})
+}
+
+func TestViewRepoAddViews(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockPosts := NewMockIMongoCollection(ctrl)
+	mockViews := NewMockIMongoCollection(ctrl)
+	mockUpdateResult := NewMockIMongoUpdateResult(ctrl)
+	repo := &ViewRepo{posts: mockPosts, views: mockViews}
+
+	batch := newViewBatch()
+	batch.add(viewKey{"1", "2022-03-01"}, 2)
+	mockViews.EXPECT().
+		UpdateOne(ctx, bson.M{"postid": PostId("1"), "day": "2022-03-01"}, bson.M{"$inc": bson.M{"views": 2}}, gomock.Any()).
+		Return(mockUpdateResult, nil)
+	mockPosts.EXPECT().
+		UpdateOne(ctx, bson.M{"id": PostId("1")}, bson.M{"$inc": bson.M{"views": 2}}).
+		Return(nil, errors.New("down"))
+
+	assert.NotNil(t, repo.AddViews(ctx, batch))
+	assert.Empty(t, batch.daily, "the daily views are written")
+	assert.Equal(t, map[PostId]int{"1": 2}, batch.posts)
+}
+
+func TestViewStats(t *testing.T) {
+	author := &user.User{Id: "1"}
+	repo := &flagsRepoStub{posts: map[PostId]*Post{"1": {Id: "1", Author: author, Views: 7}}}
+	views := &viewRepoStub{}
+	ph, _ := newTestHandler(repo, "mod")
+	ph.Views = NewViewCounter(views, time.Minute)
+	vars := map[string]string{"post_id": "1"}
+
+	t.Run("should load the daily views of the last days with the total", func(t *testing.T) {
+		for target, days := range map[string]int{"/api/post/1/stats": 30, "/api/post/1/stats?days=7": 7} {
+			w := httptest.NewRecorder()
+			ph.ViewStats(w, newTestRequest("GET", target, vars, author, nil))
+			assert.Equal(t, http.StatusOK, w.Code, target)
+			day := time.Now().UTC().AddDate(0, 0, -days+1).Format(dayLayout)
+			assert.Equal(t, day, views.since.Format(dayLayout), "the days of %s include today", target)
+			assert.JSONEq(t, fmt.Sprintf(`{"total": 7, "daily": [{"day": %q, "views": 1}]}`, day), w.Body.String(), target)
+		}
+	})
+
+	t.Run("should refuse the days out of range and the other users", func(t *testing.T) {
+		views.since = time.Time{}
+		for name, tc := range map[string]struct {
+			target string
+			viewer *user.User
+			code   int
+		}{
+			"no days":       {"/api/post/1/stats?days=0", author, http.StatusBadRequest},
+			"too many days": {fmt.Sprintf("/api/post/1/stats?days=%d", maxStatsDays+1), author, http.StatusBadRequest},
+			"moderator":     {"/api/post/1/stats", &user.User{Id: "mod"}, http.StatusForbidden},
+		} {
+			w := httptest.NewRecorder()
+			ph.ViewStats(w, newTestRequest("GET", tc.target, vars, tc.viewer, nil))
+			assert.Equal(t, tc.code, w.Code, name)
+		}
+		assert.Zero(t, views.since, "no stats are loaded")
+	})
+}
+
+func TestViewRepoGetDailyViews(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockViews := NewMockIMongoCollection(ctrl)
+	mockCursor := NewMockIMongoCursor(ctrl)
+	repo := &ViewRepo{views: mockViews}
+
+	mockViews.EXPECT().
+		Find(ctx, bson.M{"postid": PostId("1"), "day": bson.M{"$gte": "2022-03-01"}}, gomock.Any()).
+		Do(func(_ context.Context, _ interface{}, opts ...*options.FindOptions) {
+			assert.Equal(t, bson.D{{Key: "day", Value: 1}}, opts[0].Sort)
+		}).
+		Return(mockCursor, nil)
+	mockCursor.EXPECT().All(ctx, gomock.Any()).Return(nil)
+	mockCursor.EXPECT().Close(ctx).Return(nil)
+
+	_, err := repo.GetDailyViews(ctx, "1", time.Date(2022, 3, 1, 23, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,273 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"crud/pkg/comment"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+// Decides which posts and comments the viewer is allowed to see.
+type visibility struct {
+	// nil for anonymous viewers
+	viewer       *user.User
+	shadowbanned map[string]struct{}
+	// Users blocked or muted by the viewer, hidden from listings.
+	blocked map[string]struct{}
+	// Moderators see the removed content.
+	moderator bool
+	// Posts hidden by the viewer, skipped by listings.
+	hiddenPosts map[string]struct{}
+	// How the viewer wants to see the NSFW and spoiler posts.
+	prefs user.ContentPrefs
+}
+
+func (ph *PostHandler) visibility(ctx context.Context) (*visibility, error) {
+	viewer, _ := sessions.GetAuthUser(ctx)
+	shadowbanned, err := ph.Sanctions.ShadowbannedIds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("post/visibility: can't load shadowbanned users: %w", err)
+	}
+	blocked := map[string]struct{}{}
+	prefs := user.DefaultContentPrefs()
+	if viewer != nil {
+		blocked, err = ph.Blocks.FilteredIds(ctx, viewer.Id)
+		if err != nil {
+			return nil, fmt.Errorf("post/visibility: can't load blocked users: %w", err)
+		}
+		prefs, err = ph.Prefs.GetContentPrefs(ctx, viewer.Id)
+		if err != nil {
+			return nil, fmt.Errorf("post/visibility: can't load content preferences: %w", err)
+		}
+	}
+	return &visibility{
+		viewer:       viewer,
+		shadowbanned: shadowbanned,
+		blocked:      blocked,
+		moderator:    viewer != nil && viewer.Admin,
+		prefs:        prefs,
+	}, nil
+}
+
+// Like visibility, but also knows the posts hidden by the viewer.
+func (ph *PostHandler) listingVisibility(ctx context.Context) (*visibility, error) {
+	vis, err := ph.visibility(ctx)
+	if err != nil || vis.viewer == nil {
+		return vis, err
+	}
+	vis.hiddenPosts, err = ph.Marks.HiddenIds(ctx, vis.viewer.Id)
+	if err != nil {
+		return nil, fmt.Errorf("post/visibility: can't load hidden posts: %w", err)
+	}
+	return vis, nil
+}
+
+// The listings of one category show its removed content to the category moderators,
+// the other listings only to the admins.
+func (ph *PostHandler) moderateListing(ctx context.Context, vis *visibility, category string) error {
+	if category == "" || vis.moderator || vis.viewer == nil {
+		return nil
+	}
+	isMod, err := ph.Moderation.CanModerate(ctx, category, vis.viewer)
+	if err != nil {
+		return fmt.Errorf("post/visibility: can't check moderator of %s: %w", category, err)
+	}
+	vis.moderator = isMod
+	return nil
+}
+
+func (v *visibility) isViewer(u *user.User) bool {
+	return v.viewer != nil && u != nil && v.viewer.Id == u.Id
+}
+
+// Shadowbanned authors see their content, nobody else does.
+func (v *visibility) canSeeAuthor(author *user.User) bool {
+	if author == nil || v.isViewer(author) {
+		return true
+	}
+	_, shadowbanned := v.shadowbanned[author.Id]
+	return !shadowbanned
+}
+
+// Removed, hidden and pending content is visible only to its author and moderators.
+func (v *visibility) canSeeRemoved(removed bool, author *user.User) bool {
+	return !removed || v.moderator || v.isViewer(author)
+}
+
+// The strictest of the viewer modes for the post labels, the authors see their posts as is.
+func (v *visibility) contentMode(p *Post) user.ContentMode {
+	mode := user.ContentShow
+	if v.isViewer(p.Author) {
+		return mode
+	}
+	for _, m := range []struct {
+		labeled bool
+		mode    user.ContentMode
+	}{{p.NSFW, v.prefs.NSFW}, {p.Spoiler, v.prefs.Spoiler}} {
+		if !m.labeled {
+			continue
+		}
+		if m.mode == user.ContentHide || (m.mode == user.ContentBlur && mode == user.ContentShow) {
+			mode = m.mode
+		}
+	}
+	return mode
+}
+
+// Reports if the post is visible and drops the hidden comments from it.
+// Deleted comments are hidden from everyone until restored, the drafts and
+// the scheduled posts from everyone but their author. Labeled posts
+// the viewer prefers to hide are only blurred here, the listings skip them.
+func (v *visibility) post(p *Post) bool {
+	if !p.IsPublished() && !v.isViewer(p.Author) {
+		return false
+	}
+	if !v.canSeeAuthor(p.Author) || !v.canSeeRemoved(p.Removed || p.Hidden || p.Pending, p.Author) {
+		return false
+	}
+	p.Blurred = v.contentMode(p) != user.ContentShow
+	p.CrosspostOf = p.CrosspostOf.visible()
+	comments := make([]*comment.Comment, 0, len(p.Comments))
+	for _, c := range p.Comments {
+		if c.DeletedAt == nil && v.canSeeAuthor(c.Author) &&
+			v.canSeeRemoved(c.Removed || c.Hidden || c.Pending, c.Author) {
+			comments = append(comments, c)
+		}
+	}
+	p.Comments = comments
+	if p.Poll != nil && v.viewer != nil {
+		p.Poll.showVoteOf(v.viewer.Id)
+	}
+	return true
+}
+
+func (v *visibility) posts(posts []*Post) []*Post {
+	visible := make([]*Post, 0, len(posts))
+	for _, p := range posts {
+		if v.contentMode(p) != user.ContentHide && v.post(p) {
+			visible = append(visible, p)
+		}
+	}
+	return visible
+}
+
+// Like posts, but also hides the content of users blocked or muted by the viewer
+// and the posts hidden by them.
+func (v *visibility) listing(posts []*Post) []*Post {
+	visible := make([]*Post, 0, len(posts))
+	for _, p := range posts {
+		if p.Author != nil && v.isBlocked(p.Author.Id) {
+			continue
+		}
+		if _, hidden := v.hiddenPosts[string(p.Id)]; hidden {
+			continue
+		}
+		if !p.IsPublished() || v.contentMode(p) == user.ContentHide || !v.post(p) {
+			continue
+		}
+		comments := make([]*comment.Comment, 0, len(p.Comments))
+		for _, c := range p.Comments {
+			if c.Author == nil || !v.isBlocked(c.Author.Id) {
+				comments = append(comments, c)
+			}
+		}
+		p.Comments = comments
+		visible = append(visible, p)
+	}
+	return visible
+}
+
+// Mongo conditions skipping the posts the listing would skip, so the repo pages
+// over the visible posts only. The listing still checks the loaded posts.
+func (v *visibility) query() bson.A {
+	conds := bson.A{}
+	if v == nil {
+		return conds
+	}
+	if authors := v.hiddenAuthors(); len(authors) > 0 {
+		conds = append(conds, bson.M{"author.id": bson.M{"$nin": authors}})
+	}
+	if len(v.hiddenPosts) > 0 {
+		ids := make([]string, 0, len(v.hiddenPosts))
+		for id := range v.hiddenPosts {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		conds = append(conds, bson.M{"id": bson.M{"$nin": ids}})
+	}
+	if !v.moderator {
+		conds = append(conds, v.unlessViewer(bson.M{
+			"removed": bson.M{"$ne": true}, "hidden": bson.M{"$ne": true}, "pending": bson.M{"$ne": true},
+		}))
+	}
+	if v.prefs.NSFW == user.ContentHide {
+		conds = append(conds, v.unlessViewer(bson.M{"nsfw": bson.M{"$ne": true}}))
+	}
+	if v.prefs.Spoiler == user.ContentHide {
+		conds = append(conds, v.unlessViewer(bson.M{"spoiler": bson.M{"$ne": true}}))
+	}
+	return conds
+}
+
+// Mongo expression of the post comments the listing would show: not deleted,
+// not by the hidden authors and, unless moderated or the viewer's, not removed.
+// The anonymous visibility of the nil one skips only the deleted and removed comments.
+func (v *visibility) comments() bson.M {
+	conds := bson.A{bson.M{"$not": bson.A{"$$this.deletedat"}}}
+	notRemoved := bson.M{"$and": bson.A{
+		bson.M{"$ne": bson.A{"$$this.removed", true}},
+		bson.M{"$ne": bson.A{"$$this.hidden", true}},
+		bson.M{"$ne": bson.A{"$$this.pending", true}},
+	}}
+	if v == nil {
+		conds = append(conds, notRemoved)
+	} else {
+		authorId := bson.M{"$ifNull": bson.A{"$$this.author.id", ""}}
+		if authors := v.hiddenAuthors(); len(authors) > 0 {
+			conds = append(conds, bson.M{"$not": bson.A{bson.M{"$in": bson.A{authorId, authors}}}})
+		}
+		if v.viewer != nil {
+			notRemoved = bson.M{"$or": bson.A{bson.M{"$eq": bson.A{authorId, v.viewer.Id}}, notRemoved}}
+		}
+		if !v.moderator {
+			conds = append(conds, notRemoved)
+		}
+	}
+	return bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$comments", bson.A{}}},
+		"cond":  bson.M{"$and": conds},
+	}}
+}
+
+// The shadowbanned authors but the viewer and the ones blocked by the viewer, sorted.
+func (v *visibility) hiddenAuthors() []string {
+	authors := []string{}
+	for id := range v.shadowbanned {
+		if v.viewer == nil || id != v.viewer.Id {
+			authors = append(authors, id)
+		}
+	}
+	for id := range v.blocked {
+		authors = append(authors, id)
+	}
+	sort.Strings(authors)
+	return authors
+}
+
+// The viewer sees their posts as is.
+func (v *visibility) unlessViewer(cond bson.M) bson.M {
+	if v.viewer == nil {
+		return cond
+	}
+	return bson.M{"$or": bson.A{bson.M{"author.id": v.viewer.Id}, cond}}
+}
+
+func (v *visibility) isBlocked(userId string) bool {
+	_, blocked := v.blocked[userId]
+	return blocked
+}
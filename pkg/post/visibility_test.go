@@ -0,0 +1,164 @@
+package post
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"crud/pkg/comment"
+	"crud/pkg/user"
+)
+
+func TestVisibility(t *testing.T) {
+	viewer := &user.User{Id: "1"}
+	shadowbanned := &user.User{Id: "2"}
+	blocked := &user.User{Id: "3"}
+	regular := &user.User{Id: "4"}
+
+	newPosts := func() []*Post {
+		return []*Post{
+			{Id: "1", Author: regular, Comments: []*comment.Comment{
+				{Id: "1", Author: shadowbanned},
+				{Id: "2", Author: blocked},
+				{Id: "3", Author: viewer},
+			}},
+			{Id: "2", Author: shadowbanned},
+			{Id: "3", Author: blocked},
+		}
+	}
+
+	vis := &visibility{
+		viewer:       viewer,
+		shadowbanned: map[string]struct{}{shadowbanned.Id: {}},
+		blocked:      map[string]struct{}{blocked.Id: {}},
+	}
+
+	t.Run("should hide shadowbanned content", func(t *testing.T) {
+		posts := vis.posts(newPosts())
+		assert.Len(t, posts, 2)
+		assert.Equal(t, PostId("1"), posts[0].Id)
+		assert.Len(t, posts[0].Comments, 2)
+	})
+
+	t.Run("should hide blocked content from listings", func(t *testing.T) {
+		posts := vis.listing(newPosts())
+		assert.Len(t, posts, 1)
+		assert.Len(t, posts[0].Comments, 1)
+		assert.Equal(t, comment.CommentId("3"), posts[0].Comments[0].Id)
+	})
+
+	t.Run("should hide posts hidden by the viewer from listings", func(t *testing.T) {
+		hiding := &visibility{viewer: viewer, hiddenPosts: map[string]struct{}{"1": {}}}
+		posts := hiding.listing(newPosts())
+		assert.Len(t, posts, 2)
+		assert.Equal(t, PostId("2"), posts[0].Id)
+		assert.Len(t, hiding.posts(newPosts()), 3, "only listings skip hidden posts")
+	})
+
+	t.Run("should show shadowbanned content to its author", func(t *testing.T) {
+		own := &visibility{viewer: shadowbanned, shadowbanned: vis.shadowbanned}
+		assert.Len(t, own.posts(newPosts()), 3)
+	})
+
+	t.Run("should apply the content preferences", func(t *testing.T) {
+		labeled := func() []*Post {
+			return []*Post{
+				{Id: "1", Author: regular, NSFW: true},
+				{Id: "2", Author: regular, Spoiler: true},
+				{Id: "3", Author: regular, NSFW: true, Spoiler: true},
+				{Id: "4", Author: viewer, NSFW: true},
+				{Id: "5", Author: regular},
+			}
+		}
+
+		anonymous := &visibility{prefs: user.DefaultContentPrefs()}
+		posts := anonymous.listing(labeled())
+		assert.Len(t, posts, 2, "NSFW is hidden by default")
+		assert.Equal(t, PostId("2"), posts[0].Id)
+		assert.True(t, posts[0].Blurred)
+		assert.False(t, posts[1].Blurred)
+
+		own := &visibility{viewer: viewer, prefs: user.DefaultContentPrefs()}
+		posts = own.listing(labeled())
+		assert.Len(t, posts, 3, "authors see their own posts")
+		assert.Equal(t, PostId("4"), posts[1].Id)
+		assert.False(t, posts[1].Blurred)
+
+		blurring := &visibility{viewer: viewer, prefs: user.ContentPrefs{NSFW: user.ContentBlur, Spoiler: user.ContentShow}}
+		posts = blurring.listing(labeled())
+		assert.Len(t, posts, 5)
+		assert.True(t, posts[0].Blurred)
+		assert.False(t, posts[1].Blurred)
+		assert.True(t, posts[2].Blurred)
+	})
+}
+
+func TestVisibilityQuery(t *testing.T) {
+	viewer := &user.User{Id: "1"}
+	notRemoved := bson.M{"removed": bson.M{"$ne": true}, "hidden": bson.M{"$ne": true}, "pending": bson.M{"$ne": true}}
+
+	t.Run("should skip the content hidden from the viewer", func(t *testing.T) {
+		vis := &visibility{
+			viewer:       viewer,
+			shadowbanned: map[string]struct{}{"1": {}, "2": {}},
+			blocked:      map[string]struct{}{"3": {}},
+			hiddenPosts:  map[string]struct{}{"p1": {}},
+			prefs:        user.ContentPrefs{NSFW: user.ContentHide, Spoiler: user.ContentBlur},
+		}
+		own := func(cond bson.M) bson.M {
+			return bson.M{"$or": bson.A{bson.M{"author.id": "1"}, cond}}
+		}
+		assert.Equal(t, bson.A{
+			bson.M{"author.id": bson.M{"$nin": []string{"2", "3"}}},
+			bson.M{"id": bson.M{"$nin": []string{"p1"}}},
+			own(notRemoved),
+			own(bson.M{"nsfw": bson.M{"$ne": true}}),
+		}, vis.query())
+	})
+
+	t.Run("should show the removed content to moderators", func(t *testing.T) {
+		vis := &visibility{viewer: viewer, moderator: true, prefs: user.ContentPrefs{NSFW: user.ContentShow}}
+		assert.Empty(t, vis.query())
+	})
+
+	t.Run("should hide the removed and NSFW content from anonymous viewers", func(t *testing.T) {
+		vis := &visibility{prefs: user.DefaultContentPrefs()}
+		assert.Equal(t, bson.A{notRemoved, bson.M{"nsfw": bson.M{"$ne": true}}}, vis.query())
+	})
+}
+
+func TestVisibilityComments(t *testing.T) {
+	notDeleted := bson.M{"$not": bson.A{"$$this.deletedat"}}
+	notRemoved := bson.M{"$and": bson.A{
+		bson.M{"$ne": bson.A{"$$this.removed", true}},
+		bson.M{"$ne": bson.A{"$$this.hidden", true}},
+		bson.M{"$ne": bson.A{"$$this.pending", true}},
+	}}
+	authorId := bson.M{"$ifNull": bson.A{"$$this.author.id", ""}}
+	cond := func(vis *visibility) bson.A {
+		return vis.comments()["$filter"].(bson.M)["cond"].(bson.M)["$and"].(bson.A)
+	}
+
+	t.Run("should count the comments the listing shows the viewer", func(t *testing.T) {
+		vis := &visibility{
+			viewer:       &user.User{Id: "1"},
+			shadowbanned: map[string]struct{}{"1": {}, "2": {}},
+			blocked:      map[string]struct{}{"3": {}},
+		}
+		assert.Equal(t, bson.A{
+			notDeleted,
+			bson.M{"$not": bson.A{bson.M{"$in": bson.A{authorId, []string{"2", "3"}}}}},
+			bson.M{"$or": bson.A{bson.M{"$eq": bson.A{authorId, "1"}}, notRemoved}},
+		}, cond(vis))
+	})
+
+	t.Run("should count the removed, hidden and pending comments for moderators", func(t *testing.T) {
+		vis := &visibility{viewer: &user.User{Id: "1"}, moderator: true}
+		assert.Equal(t, bson.A{notDeleted}, cond(vis))
+	})
+
+	t.Run("should skip the deleted, removed, hidden and pending comments without a visibility", func(t *testing.T) {
+		assert.Equal(t, bson.A{notDeleted, notRemoved}, cond(nil))
+	})
+}
@@ -0,0 +1,154 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"crud/pkg/common"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func NewReportRepo(db *sql.DB) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+// Adds the report. A user can report the same content only once.
+func (r *Repo) Add(ctx context.Context, rep *Report) error {
+	row := r.db.QueryRowContext(ctx,
+		`INSERT INTO reports(category, target_type, target_id, post_id, reporter_id, reason, text, created)
+		 VALUES($1, $2, $3, $4, NULLIF($5::text, '')::integer, $6, $7, $8) RETURNING id`,
+		rep.Category, rep.TargetType, rep.TargetId, rep.PostId, rep.ReporterId, rep.Reason, rep.Text, rep.Created)
+	if err := row.Scan(&rep.Id); err != nil {
+		if common.IsUniqueViolation(err) {
+			return ErrAlreadyReported
+		}
+		return fmt.Errorf("report/repo: failed adding report: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) CountOpen(ctx context.Context, targetType TargetType, targetId string) (int, error) {
+	var count int
+	row := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM reports WHERE target_type=$1 AND target_id=$2 AND resolution IS NULL",
+		targetType, targetId)
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("report/repo: could not scan row: %w", err)
+	}
+	return count, nil
+}
+
+// Returns open reports of the category grouped per reported content,
+// the most reported first.
+func (r *Repo) Queue(ctx context.Context, category string, page common.Page) ([]*QueueItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT target_type, target_id, post_id, reason, COUNT(*), MIN(created), MAX(created)
+		 FROM reports WHERE category=$1 AND resolution IS NULL
+		 GROUP BY target_type, target_id, post_id, reason`, category)
+	if err != nil {
+		return nil, fmt.Errorf("report/repo: failed querying reports: %w", err)
+	}
+	defer rows.Close()
+
+	type key struct {
+		targetType TargetType
+		targetId   string
+	}
+	items := map[key]*QueueItem{}
+	for rows.Next() {
+		var (
+			k                   key
+			postId              string
+			reason              Reason
+			count               int
+			firstTime, lastTime time.Time
+		)
+		if err := rows.Scan(&k.targetType, &k.targetId, &postId, &reason, &count, &firstTime, &lastTime); err != nil {
+			return nil, fmt.Errorf("report/repo: could not scan row: %w", err)
+		}
+		item, ok := items[k]
+		if !ok {
+			item = &QueueItem{
+				TargetType:    k.targetType,
+				TargetId:      k.targetId,
+				PostId:        postId,
+				Reasons:       map[Reason]int{},
+				FirstReported: firstTime,
+				LastReported:  lastTime,
+			}
+			items[k] = item
+		}
+		item.Count += count
+		item.Reasons[reason] += count
+		if firstTime.Before(item.FirstReported) {
+			item.FirstReported = firstTime
+		}
+		if lastTime.After(item.LastReported) {
+			item.LastReported = lastTime
+		}
+	}
+
+	queue := make([]*QueueItem, 0, len(items))
+	for _, item := range items {
+		queue = append(queue, item)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if queue[i].Count != queue[j].Count {
+			return queue[i].Count > queue[j].Count
+		}
+		return queue[i].FirstReported.Before(queue[j].FirstReported)
+	})
+
+	start, end := page.Bounds(len(queue))
+	return queue[start:end], nil
+}
+
+// Returns open reports of the content in the category.
+func (r *Repo) GetOpen(ctx context.Context, category string, targetType TargetType, targetId string) ([]*Report, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, category, target_type, target_id, post_id, COALESCE(reporter_id::text, ''), reason, text, created
+		 FROM reports WHERE category=$1 AND target_type=$2 AND target_id=$3 AND resolution IS NULL
+		 ORDER BY created`, category, targetType, targetId)
+	if err != nil {
+		return nil, fmt.Errorf("report/repo: failed querying reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []*Report{}
+	for rows.Next() {
+		rep := new(Report)
+		err := rows.Scan(&rep.Id, &rep.Category, &rep.TargetType, &rep.TargetId, &rep.PostId,
+			&rep.ReporterId, &rep.Reason, &rep.Text, &rep.Created)
+		if err != nil {
+			return nil, fmt.Errorf("report/repo: could not scan row: %w", err)
+		}
+		reports = append(reports, rep)
+	}
+	return reports, nil
+}
+
+// Resolves all open reports of the content, returns the number of resolved reports.
+func (r *Repo) Resolve(ctx context.Context, category string, targetType TargetType, targetId string,
+	resolution Resolution, resolverId string,
+) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE reports SET resolution=$4, resolver_id=$5, resolved=NOW()
+		 WHERE category=$1 AND target_type=$2 AND target_id=$3 AND resolution IS NULL`,
+		category, targetType, targetId, resolution, resolverId)
+	if err != nil {
+		return 0, fmt.Errorf("report/repo: failed resolving reports: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("report/repo: failed resolving reports: %w", err)
+	}
+	return n, nil
+}
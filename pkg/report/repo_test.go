@@ -0,0 +1,83 @@
+package report
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	"crud/pkg/common"
+)
+
+func TestReportAdd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	repo := NewReportRepo(db)
+
+	t.Run("should return ErrAlreadyReported on duplicate", func(t *testing.T) {
+		mock.
+			ExpectQuery("INSERT INTO reports").
+			WillReturnError(&pgconn.PgError{Code: common.PgUniqueViolation})
+
+		err := repo.Add(context.TODO(), &Report{Reason: ReasonSpam})
+		assert.ErrorIs(t, err, ErrAlreadyReported)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+}
+
+func TestReportQueue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	repo := NewReportRepo(db)
+
+	t.Run("should group reports per content", func(t *testing.T) {
+		t1 := time.Now().Add(-time.Hour)
+		t2 := time.Now()
+		rows := sqlmock.NewRows([]string{"target_type", "target_id", "post_id", "reason", "count", "min", "max"}).
+			AddRow(TargetComment, "c1", "p1", ReasonSpam, 1, t2, t2).
+			AddRow(TargetPost, "p2", "p2", ReasonSpam, 2, t2, t2).
+			AddRow(TargetPost, "p2", "p2", ReasonHate, 1, t1, t1)
+		mock.
+			ExpectQuery("SELECT (.+) FROM reports WHERE category=").
+			WithArgs("music").
+			WillReturnRows(rows)
+
+		queue, err := repo.Queue(context.TODO(), "music", common.Page{Limit: 10})
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+		assert.Len(t, queue, 2)
+		assert.Equal(t, &QueueItem{
+			TargetType:    TargetPost,
+			TargetId:      "p2",
+			PostId:        "p2",
+			Count:         3,
+			Reasons:       map[Reason]int{ReasonSpam: 2, ReasonHate: 1},
+			FirstReported: t1,
+			LastReported:  t2,
+		}, queue[0])
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+}
+
+func TestReportValidate(t *testing.T) {
+	assert.Nil(t, (&Report{Reason: ReasonSpam}).Validate())
+	assert.ErrorIs(t, (&Report{Reason: "boring"}).Validate(), ErrInvalid)
+	assert.ErrorIs(t, (&Report{Reason: ReasonOther}).Validate(), ErrInvalid)
+}
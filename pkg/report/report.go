@@ -0,0 +1,97 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Reason string
+
+const (
+	ReasonSpam           Reason = "spam"
+	ReasonHarassment     Reason = "harassment"
+	ReasonHate           Reason = "hate"
+	ReasonViolence       Reason = "violence"
+	ReasonNSFW           Reason = "nsfw"
+	ReasonMisinformation Reason = "misinformation"
+	ReasonOffTopic       Reason = "off_topic"
+	// Requires the text explaining the reason.
+	ReasonOther Reason = "other"
+	// Filed by AutoModerator, can't be chosen by users.
+	ReasonAutomod Reason = "automod"
+)
+
+var reasons = map[Reason]struct{}{
+	ReasonSpam: {}, ReasonHarassment: {}, ReasonHate: {}, ReasonViolence: {},
+	ReasonNSFW: {}, ReasonMisinformation: {}, ReasonOffTopic: {}, ReasonOther: {},
+}
+
+type TargetType string
+
+const (
+	TargetPost    TargetType = "post"
+	TargetComment TargetType = "comment"
+)
+
+type Resolution string
+
+const (
+	ResolutionDismiss Resolution = "dismiss"
+	ResolutionRemove  Resolution = "remove"
+	// Removes the content and bans its author in the category.
+	ResolutionBan Resolution = "ban"
+)
+
+var (
+	ErrInvalid         = errors.New("report: invalid report")
+	ErrAlreadyReported = errors.New("report: already reported")
+)
+
+const maxTextLen = 500
+
+type Report struct {
+	Id         int64      `json:"id"`
+	Category   string     `json:"category"`
+	TargetType TargetType `json:"targetType"`
+	TargetId   string     `json:"targetId"`
+	// Post of the reported comment or the reported post itself.
+	PostId string `json:"postId"`
+	// Empty for reports filed by AutoModerator.
+	ReporterId string     `json:"reporterId"`
+	Reason     Reason     `json:"reason"`
+	Text       string     `json:"text"`
+	Created    time.Time  `json:"created"`
+	Resolution Resolution `json:"resolution,omitempty"`
+}
+
+func (r *Report) Validate() error {
+	if _, ok := reasons[r.Reason]; !ok {
+		return fmt.Errorf("%w: unknown reason %q", ErrInvalid, r.Reason)
+	}
+	if r.Reason == ReasonOther && r.Text == "" {
+		return fmt.Errorf("%w: describe the reason", ErrInvalid)
+	}
+	if len(r.Text) > maxTextLen {
+		return fmt.Errorf("%w: text must be at most %d characters long", ErrInvalid, maxTextLen)
+	}
+	return nil
+}
+
+// QueueItem groups open reports of the same post or comment.
+type QueueItem struct {
+	TargetType    TargetType     `json:"targetType"`
+	TargetId      string         `json:"targetId"`
+	PostId        string         `json:"postId"`
+	Count         int            `json:"count"`
+	Reasons       map[Reason]int `json:"reasons"`
+	FirstReported time.Time      `json:"firstReported"`
+	LastReported  time.Time      `json:"lastReported"`
+}
+
+// Thresholds of open reports after which the content is collapsed
+// or hidden until moderators review it. Zero disables the threshold.
+type Thresholds struct {
+	Collapse int
+	Hide     int
+}
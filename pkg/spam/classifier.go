@@ -0,0 +1,179 @@
+package spam
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// The model doesn't judge until it has seen this many documents of each class.
+	minClassDocs  = 10
+	maxTokens     = 500
+	minWordLength = 2
+	maxWordLength = 24
+)
+
+// Doc is the post or comment to classify.
+type Doc struct {
+	Title string
+	Text  string
+	URL   string
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+// Splits the doc into unique tokens: title words, body words and link domains
+// are prefixed to be counted separately.
+func Tokenize(doc *Doc) []string {
+	seen := map[string]struct{}{}
+	tokens := []string{}
+	add := func(token string) {
+		if _, ok := seen[token]; ok || len(tokens) >= maxTokens {
+			return
+		}
+		seen[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+
+	for _, w := range words(doc.Title) {
+		add("t:" + w)
+	}
+	for _, w := range words(doc.Text) {
+		add("b:" + w)
+	}
+	links := urlPattern.FindAllString(doc.Text, -1)
+	if doc.URL != "" {
+		links = append(links, doc.URL)
+	}
+	for _, l := range links {
+		u, err := url.Parse(l)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		add("d:" + strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+	}
+	return tokens
+}
+
+func words(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	res := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if n := len([]rune(f)); n >= minWordLength && n <= maxWordLength {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// Model is a naive Bayes model: the number of spam and ham documents containing each token.
+type Model struct {
+	spam     map[string]int
+	ham      map[string]int
+	spamDocs int
+	hamDocs  int
+}
+
+func NewModel() *Model {
+	return &Model{
+		spam: map[string]int{},
+		ham:  map[string]int{},
+	}
+}
+
+func (m *Model) Learn(tokens []string, isSpam bool) {
+	counts := m.ham
+	if isSpam {
+		counts = m.spam
+		m.spamDocs++
+	} else {
+		m.hamDocs++
+	}
+	for _, t := range tokens {
+		counts[t]++
+	}
+}
+
+// Returns the probability of the tokens being spam, 0 if the model isn't trained enough.
+func (m *Model) Score(tokens []string) float64 {
+	if m.spamDocs < minClassDocs || m.hamDocs < minClassDocs {
+		return 0
+	}
+	total := float64(m.spamDocs + m.hamDocs)
+	logSpam := math.Log(float64(m.spamDocs) / total)
+	logHam := math.Log(float64(m.hamDocs) / total)
+	for _, t := range tokens {
+		s, h := m.spam[t], m.ham[t]
+		if s == 0 && h == 0 {
+			continue
+		}
+		// Laplace smoothing keeps unseen tokens from zeroing the class.
+		logSpam += math.Log(float64(s+1) / float64(m.spamDocs+2))
+		logHam += math.Log(float64(h+1) / float64(m.hamDocs+2))
+	}
+	return 1 / (1 + math.Exp(logHam-logSpam))
+}
+
+type IModelRepo interface {
+	Load(context.Context) (*Model, error)
+	Learn(ctx context.Context, tokens []string, isSpam bool) error
+}
+
+// Classifier scores the new content and learns from the moderators decisions.
+type Classifier struct {
+	repo IModelRepo
+	// Content scoring at least the threshold is spam, 0 disables the classifier.
+	threshold float64
+
+	mu    sync.RWMutex
+	model *Model
+}
+
+func NewClassifier(repo IModelRepo, threshold float64) *Classifier {
+	return &Classifier{
+		repo:      repo,
+		threshold: threshold,
+		model:     NewModel(),
+	}
+}
+
+// Loads the model from the repo, e.g. after it was retrained with the CLI.
+func (c *Classifier) Reload(ctx context.Context) error {
+	m, err := c.repo.Load(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.model = m
+	c.mu.Unlock()
+	return nil
+}
+
+// Returns the spam score of the doc and if it is above the threshold.
+func (c *Classifier) Check(doc *Doc) (float64, bool) {
+	if c.threshold <= 0 {
+		return 0, false
+	}
+	c.mu.RLock()
+	score := c.model.Score(Tokenize(doc))
+	c.mu.RUnlock()
+	return score, score >= c.threshold
+}
+
+func (c *Classifier) Learn(ctx context.Context, doc *Doc, isSpam bool) error {
+	tokens := Tokenize(doc)
+	if err := c.repo.Learn(ctx, tokens, isSpam); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.model.Learn(tokens, isSpam)
+	c.mu.Unlock()
+	return nil
+}
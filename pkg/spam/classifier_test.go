@@ -0,0 +1,81 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize(&Doc{
+		Title: "Cheap pills",
+		Text:  "Buy cheap at https://www.pills.example/buy a b",
+		URL:   "https://shop.example",
+	})
+	assert.Equal(t, []string{
+		"t:cheap", "t:pills", "b:buy", "b:cheap", "b:at", "b:https", "b:www", "b:pills", "b:example",
+		"d:pills.example", "d:shop.example",
+	}, tokens)
+}
+
+func corpus() []Sample {
+	samples := []Sample{}
+	for i := 0; i < 20; i++ {
+		samples = append(samples,
+			Sample{Doc: &Doc{Title: fmt.Sprintf("cheap pills %d", i), URL: "https://pills.example"}, Spam: true},
+			Sample{Doc: &Doc{Title: fmt.Sprintf("release notes %d", i), Text: "go generics discussion"}, Spam: false},
+		)
+	}
+	return samples
+}
+
+func TestModelScore(t *testing.T) {
+	t.Run("should not judge until trained", func(t *testing.T) {
+		m := Train(corpus()[:4])
+		assert.Equal(t, 0.0, m.Score(Tokenize(&Doc{Title: "cheap pills"})))
+	})
+
+	t.Run("should score by the learned tokens", func(t *testing.T) {
+		m := Train(corpus())
+		assert.Greater(t, m.Score(Tokenize(&Doc{Title: "cheap pills here", URL: "https://pills.example/x"})), 0.9)
+		assert.Less(t, m.Score(Tokenize(&Doc{Title: "generics release"})), 0.1)
+	})
+}
+
+func TestCrossValidate(t *testing.T) {
+	metrics := CrossValidate(corpus(), 4, 0.9)
+	assert.Equal(t, 40, metrics.Total())
+	assert.Equal(t, 1.0, metrics.Accuracy())
+}
+
+type memRepo struct {
+	model   *Model
+	learned int
+}
+
+func (r *memRepo) Load(context.Context) (*Model, error) { return r.model, nil }
+
+func (r *memRepo) Learn(context.Context, []string, bool) error {
+	r.learned++
+	return nil
+}
+
+func TestClassifier(t *testing.T) {
+	repo := &memRepo{model: Train(corpus())}
+	c := NewClassifier(repo, 0.9)
+
+	_, isSpam := c.Check(&Doc{Title: "cheap pills"})
+	assert.False(t, isSpam, "empty model before reload")
+
+	assert.Nil(t, c.Reload(context.Background()))
+	_, isSpam = c.Check(&Doc{Title: "cheap pills"})
+	assert.True(t, isSpam)
+
+	assert.Nil(t, c.Learn(context.Background(), &Doc{Title: "new"}, false))
+	assert.Equal(t, 1, repo.learned)
+
+	_, isSpam = NewClassifier(repo, 0).Check(&Doc{Title: "cheap pills"})
+	assert.False(t, isSpam, "disabled classifier")
+}
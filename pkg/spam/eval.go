@@ -0,0 +1,91 @@
+package spam
+
+import "fmt"
+
+// Sample is the doc labeled by a moderator.
+type Sample struct {
+	Doc  *Doc
+	Spam bool
+}
+
+// Metrics of the classifier on the labeled samples.
+type Metrics struct {
+	TruePositives  int
+	FalsePositives int
+	TrueNegatives  int
+	FalseNegatives int
+}
+
+func (m Metrics) Total() int {
+	return m.TruePositives + m.FalsePositives + m.TrueNegatives + m.FalseNegatives
+}
+
+func (m Metrics) Accuracy() float64 {
+	return ratio(m.TruePositives+m.TrueNegatives, m.Total())
+}
+
+// Share of the content held as spam that is really spam.
+func (m Metrics) Precision() float64 {
+	return ratio(m.TruePositives, m.TruePositives+m.FalsePositives)
+}
+
+// Share of the spam that is held.
+func (m Metrics) Recall() float64 {
+	return ratio(m.TruePositives, m.TruePositives+m.FalseNegatives)
+}
+
+func (m Metrics) String() string {
+	return fmt.Sprintf("samples: %d, accuracy: %.3f, precision: %.3f, recall: %.3f (tp %d, fp %d, tn %d, fn %d)",
+		m.Total(), m.Accuracy(), m.Precision(), m.Recall(),
+		m.TruePositives, m.FalsePositives, m.TrueNegatives, m.FalseNegatives)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func Train(samples []Sample) *Model {
+	m := NewModel()
+	for _, s := range samples {
+		m.Learn(Tokenize(s.Doc), s.Spam)
+	}
+	return m
+}
+
+// Evaluates the classifier with k-fold cross-validation:
+// every sample is scored by the model trained on the other folds.
+func CrossValidate(samples []Sample, folds int, threshold float64) Metrics {
+	var res Metrics
+	if folds < 2 {
+		folds = 2
+	}
+	for k := 0; k < folds; k++ {
+		train := make([]Sample, 0, len(samples))
+		test := []Sample{}
+		for i, s := range samples {
+			if i%folds == k {
+				test = append(test, s)
+			} else {
+				train = append(train, s)
+			}
+		}
+		m := Train(train)
+		for _, s := range test {
+			isSpam := m.Score(Tokenize(s.Doc)) >= threshold
+			switch {
+			case isSpam && s.Spam:
+				res.TruePositives++
+			case isSpam && !s.Spam:
+				res.FalsePositives++
+			case !isSpam && s.Spam:
+				res.FalseNegatives++
+			default:
+				res.TrueNegatives++
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,136 @@
+package spam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func NewModelRepo(db *sql.DB) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+func (r *Repo) Load(ctx context.Context) (*Model, error) {
+	m := NewModel()
+	row := r.db.QueryRowContext(ctx, "SELECT spam_docs, ham_docs FROM spam_model WHERE id=1")
+	err := row.Scan(&m.spamDocs, &m.hamDocs)
+	if err == sql.ErrNoRows {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("spam/repo: could not scan row: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT token, spam, ham FROM spam_tokens")
+	if err != nil {
+		return nil, fmt.Errorf("spam/repo: failed querying tokens: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token string
+		var s, h int
+		if err := rows.Scan(&token, &s, &h); err != nil {
+			return nil, fmt.Errorf("spam/repo: could not scan row: %w", err)
+		}
+		if s > 0 {
+			m.spam[token] = s
+		}
+		if h > 0 {
+			m.ham[token] = h
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("spam/repo: failed reading tokens: %w", err)
+	}
+	return m, nil
+}
+
+// Adds the labeled document to the stored model.
+func (r *Repo) Learn(ctx context.Context, tokens []string, isSpam bool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("spam/repo: failed starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	spam, ham := 0, 1
+	if isSpam {
+		spam, ham = 1, 0
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO spam_model(id, spam_docs, ham_docs) VALUES(1, $1, $2)
+		 ON CONFLICT (id) DO UPDATE SET spam_docs=spam_model.spam_docs+$1, ham_docs=spam_model.ham_docs+$2`,
+		spam, ham)
+	if err != nil {
+		return fmt.Errorf("spam/repo: failed updating model: %w", err)
+	}
+	for _, t := range tokens {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO spam_tokens(token, spam, ham) VALUES($1, $2, $3)
+			 ON CONFLICT (token) DO UPDATE SET spam=spam_tokens.spam+$2, ham=spam_tokens.ham+$3`,
+			t, spam, ham)
+		if err != nil {
+			return fmt.Errorf("spam/repo: failed updating token: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("spam/repo: failed commiting model: %w", err)
+	}
+	return nil
+}
+
+// Replaces the stored model with the retrained one.
+func (r *Repo) Save(ctx context.Context, m *Model) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("spam/repo: failed starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM spam_tokens"); err != nil {
+		return fmt.Errorf("spam/repo: failed clearing tokens: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO spam_model(id, spam_docs, ham_docs) VALUES(1, $1, $2)
+		 ON CONFLICT (id) DO UPDATE SET spam_docs=$1, ham_docs=$2`,
+		m.spamDocs, m.hamDocs)
+	if err != nil {
+		return fmt.Errorf("spam/repo: failed saving model: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO spam_tokens(token, spam, ham) VALUES($1, $2, $3)")
+	if err != nil {
+		return fmt.Errorf("spam/repo: failed preparing insert: %w", err)
+	}
+	defer stmt.Close()
+	for t, n := range m.tokenCounts() {
+		if _, err := stmt.ExecContext(ctx, t, n[0], n[1]); err != nil {
+			return fmt.Errorf("spam/repo: failed saving token: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("spam/repo: failed commiting model: %w", err)
+	}
+	return nil
+}
+
+// Spam and ham counts per token.
+func (m *Model) tokenCounts() map[string][2]int {
+	counts := make(map[string][2]int, len(m.spam)+len(m.ham))
+	for t, n := range m.spam {
+		counts[t] = [2]int{n, 0}
+	}
+	for t, n := range m.ham {
+		c := counts[t]
+		c[1] = n
+		counts[t] = c
+	}
+	return counts
+}
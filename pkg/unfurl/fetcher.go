@@ -0,0 +1,226 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBlocked     = errors.New("unfurl: address is not allowed")
+	ErrNotHTML     = errors.New("unfurl: page is not html")
+	ErrBadResponse = errors.New("unfurl: bad response")
+)
+
+// Config limits the fetching of the linked pages.
+type Config struct {
+	// Timeout of the whole fetch including the redirects.
+	Timeout time.Duration
+	// Only the start of the bigger pages is read.
+	MaxSize int64
+	// Fetched previews are reused for the same URL during this time.
+	CacheTTL  time.Duration
+	CacheSize int
+}
+
+var DefaultConfig = Config{
+	Timeout:   10 * time.Second,
+	MaxSize:   1 << 20,
+	CacheTTL:  24 * time.Hour,
+	CacheSize: 10000,
+}
+
+const maxRedirects = 5
+
+// Fetcher loads the linked pages and extracts their previews.
+type Fetcher struct {
+	client *http.Client
+	cfg    Config
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	preview *Preview
+	expires time.Time
+}
+
+// Returns the fetcher connecting only to public addresses.
+func NewFetcher(cfg Config) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: blockPrivate,
+	}
+	transport := &http.Transport{
+		// No proxy from the environment, it would connect on our behalf unchecked.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Minute,
+	}
+	return NewFetcherWithClient(&http.Client{Transport: transport}, cfg)
+}
+
+// Returns the fetcher using the client as is, e.g. to reach a local stand-in in tests.
+func NewFetcherWithClient(client *http.Client, cfg Config) *Fetcher {
+	c := *client
+	c.Timeout = cfg.Timeout
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("%w: too many redirects", ErrBadResponse)
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("%w: redirect to %s", ErrBlocked, req.URL.Scheme)
+		}
+		return nil
+	}
+	return &Fetcher{
+		client: &c,
+		cfg:    cfg,
+		cache:  map[string]cached{},
+	}
+}
+
+// Checks the resolved address right before connecting, so the DNS answers
+// pointing to the internal network are refused too.
+func blockPrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlocked, address)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrBlocked, host)
+	}
+	return nil
+}
+
+var nonPublicNets = func() []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, cidr := range []string{
+		"0.0.0.0/8",       // "this" network
+		"100.64.0.0/10",   // carrier-grade NAT
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // documentation
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // documentation
+		"203.0.113.0/24",  // documentation
+		"240.0.0.0/4",     // reserved
+		"64:ff9b::/96",    // NAT64, may reach the internal IPv4 network
+		"2001:db8::/32",   // documentation
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+func isPublic(ip net.IP) bool {
+	// IPv4-mapped IPv6 addresses are checked as IPv4.
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the preview of the page, cached for the same URL.
+func (f *Fetcher) Fetch(ctx context.Context, pageURL string) (*Preview, error) {
+	now := time.Now()
+	if p := f.cached(pageURL, now); p != nil {
+		return p, nil
+	}
+	p, err := f.fetch(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	p.FetchedAt = now
+	f.store(pageURL, p, now)
+	copied := *p
+	return &copied, nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, pageURL string) (*Preview, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%w: %q", ErrBlocked, pageURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unfurl: can't create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "crud-unfurl/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unfurl: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrBadResponse, resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, f.cfg.MaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("unfurl: failed reading page: %w", err)
+	}
+	// The page may have been redirected, relative URLs are resolved against the final one.
+	return Parse(page, resp.Request.URL), nil
+}
+
+func (f *Fetcher) cached(pageURL string, now time.Time) *Preview {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.cache[pageURL]
+	if !ok || now.After(c.expires) {
+		return nil
+	}
+	copied := *c.preview
+	return &copied
+}
+
+func (f *Fetcher) store(pageURL string, p *Preview, now time.Time) {
+	if f.cfg.CacheTTL <= 0 || f.cfg.CacheSize <= 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.cache) >= f.cfg.CacheSize {
+		for k, c := range f.cache {
+			if now.After(c.expires) {
+				delete(f.cache, k)
+			}
+		}
+		// Still full, drops an arbitrary entry.
+		for k := range f.cache {
+			if len(f.cache) < f.cfg.CacheSize {
+				break
+			}
+			delete(f.cache, k)
+		}
+	}
+	f.cache[pageURL] = cached{preview: p, expires: now.Add(f.cfg.CacheTTL)}
+}
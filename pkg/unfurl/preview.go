@@ -0,0 +1,101 @@
+package unfurl
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Preview is the OpenGraph summary of the linked page.
+type Preview struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	SiteName    string    `json:"siteName,omitempty"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+	// The page couldn't be fetched, the preview is empty.
+	Failed bool `json:"failed,omitempty"`
+}
+
+const (
+	maxTitleLen       = 300
+	maxDescriptionLen = 1000
+	maxImageURLLen    = 2048
+)
+
+var (
+	metaPattern  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern  = regexp.MustCompile(`(?is)([a-z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	spaces       = regexp.MustCompile(`\s+`)
+)
+
+// Extracts the OpenGraph tags from the page, falling back to the title
+// and description meta tags. Relative image URLs are resolved against the page URL.
+func Parse(page []byte, pageURL *url.URL) *Preview {
+	og := map[string]string{}
+	for _, tag := range metaPattern.FindAll(page, -1) {
+		attrs := map[string]string{}
+		for _, m := range attrPattern.FindAllSubmatch(tag, -1) {
+			attrs[strings.ToLower(string(m[1]))] = string(m[2]) + string(m[3]) + string(m[4])
+		}
+		key := strings.ToLower(attrs["property"])
+		if key == "" {
+			key = strings.ToLower(attrs["name"])
+		}
+		if _, seen := og[key]; key != "" && !seen {
+			og[key] = attrs["content"]
+		}
+	}
+
+	p := &Preview{
+		Title:       clean(first(og["og:title"], og["twitter:title"]), maxTitleLen),
+		Description: clean(first(og["og:description"], og["twitter:description"], og["description"]), maxDescriptionLen),
+		SiteName:    clean(og["og:site_name"], maxTitleLen),
+		Image:       imageURL(first(og["og:image"], og["og:image:url"], og["twitter:image"]), pageURL),
+	}
+	if p.Title == "" {
+		if m := titlePattern.FindSubmatch(page); m != nil {
+			p.Title = clean(string(m[1]), maxTitleLen)
+		}
+	}
+	return p
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Unescapes the text, collapses the whitespace and cuts it to max runes.
+func clean(s string, max int) string {
+	s = strings.TrimSpace(spaces.ReplaceAllString(html.UnescapeString(s), " "))
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) > max {
+		s = string([]rune(s)[:max-1]) + "…"
+	}
+	return s
+}
+
+// Only absolute http(s) image URLs are kept.
+func imageURL(raw string, pageURL *url.URL) string {
+	raw = strings.TrimSpace(html.UnescapeString(raw))
+	if raw == "" {
+		return ""
+	}
+	u, err := pageURL.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	if s := u.String(); len(s) <= maxImageURLLen {
+		return s
+	}
+	return ""
+}
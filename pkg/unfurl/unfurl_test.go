@@ -0,0 +1,135 @@
+package unfurl
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const page = `<!doctype html><html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="Go 1.19 &amp; generics">
+<meta content='Release notes' property='og:description'>
+<meta name="description" content="ignored">
+<META PROPERTY="og:image" CONTENT="/img/cover.png">
+<meta property="og:site_name" content="The Go Blog">
+</head><body>hello</body></html>`
+
+func TestParse(t *testing.T) {
+	pageURL, _ := url.Parse("https://go.dev/blog/go1.19")
+
+	t.Run("should extract the OpenGraph tags", func(t *testing.T) {
+		p := Parse([]byte(page), pageURL)
+		assert.Equal(t, &Preview{
+			Title:       "Go 1.19 & generics",
+			Description: "Release notes",
+			Image:       "https://go.dev/img/cover.png",
+			SiteName:    "The Go Blog",
+		}, p)
+	})
+
+	t.Run("should fall back to the title and description tags", func(t *testing.T) {
+		p := Parse([]byte(`<title>
+			Plain   page </title><meta name="description" content="About">
+			<meta property="og:image" content="javascript:alert(1)">`), pageURL)
+		assert.Equal(t, &Preview{Title: "Plain page", Description: "About"}, p)
+	})
+
+	t.Run("should cut long texts", func(t *testing.T) {
+		p := Parse([]byte(`<meta property="og:title" content="`+strings.Repeat("й", 500)+`">`), pageURL)
+		assert.Equal(t, maxTitleLen, len([]rune(p.Title)))
+	})
+}
+
+func TestIsPublic(t *testing.T) {
+	for ip, public := range map[string]bool{
+		"8.8.8.8": true, "2606:4700::1111": true,
+		"127.0.0.1": false, "10.1.2.3": false, "172.16.0.1": false, "192.168.1.1": false,
+		"169.254.169.254": false, "100.64.0.1": false, "0.0.0.0": false, "::1": false,
+		"fe80::1": false, "fc00::1": false, "::ffff:127.0.0.1": false, "64:ff9b::a00:1": false,
+	} {
+		assert.Equal(t, public, isPublic(net.ParseIP(ip)), ip)
+	}
+}
+
+func standin(t *testing.T) (*httptest.Server, *int32) {
+	var hits int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/file.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write([]byte("PK"))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(strings.Repeat(" ", 2048) + `<title>too far</title>`))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	srv, hits := standin(t)
+	cfg := DefaultConfig
+	cfg.MaxSize = 1024
+	f := NewFetcherWithClient(srv.Client(), cfg)
+
+	t.Run("should fetch and cache the preview", func(t *testing.T) {
+		p, err := f.Fetch(ctx, srv.URL+"/page")
+		assert.Nil(t, err)
+		assert.Equal(t, "Go 1.19 & generics", p.Title)
+		assert.Equal(t, srv.URL+"/img/cover.png", p.Image)
+		assert.False(t, p.FetchedAt.IsZero())
+
+		_, err = f.Fetch(ctx, srv.URL+"/page")
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+	})
+
+	t.Run("should follow redirects", func(t *testing.T) {
+		p, err := f.Fetch(ctx, srv.URL+"/moved")
+		assert.Nil(t, err)
+		assert.Equal(t, "The Go Blog", p.SiteName)
+	})
+
+	t.Run("should refuse bad pages", func(t *testing.T) {
+		_, err := f.Fetch(ctx, srv.URL+"/file.zip")
+		assert.ErrorIs(t, err, ErrNotHTML)
+		_, err = f.Fetch(ctx, srv.URL+"/missing")
+		assert.ErrorIs(t, err, ErrBadResponse)
+		_, err = f.Fetch(ctx, srv.URL+"/loop")
+		assert.ErrorIs(t, err, ErrBadResponse)
+	})
+
+	t.Run("should read only the start of the page", func(t *testing.T) {
+		p, err := f.Fetch(ctx, srv.URL+"/huge")
+		assert.Nil(t, err)
+		assert.Equal(t, "", p.Title)
+	})
+
+	t.Run("should refuse private addresses", func(t *testing.T) {
+		_, err := NewFetcher(DefaultConfig).Fetch(ctx, srv.URL+"/page")
+		assert.ErrorIs(t, err, ErrBlocked)
+		_, err = NewFetcher(DefaultConfig).Fetch(ctx, "file:///etc/passwd")
+		assert.ErrorIs(t, err, ErrBlocked)
+	})
+}
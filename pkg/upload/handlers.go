@@ -0,0 +1,176 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"crud/pkg/blob"
+	. "crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+)
+
+type IUploadRepo interface {
+	Add(context.Context, *Image) error
+}
+
+type UploadHandler struct {
+	Repo   IUploadRepo
+	Store  blob.Store
+	Config Config
+	// Holds a token while the image is processed.
+	processing chan struct{}
+}
+
+func NewUploadHandler(repo IUploadRepo, store blob.Store, cfg Config) *UploadHandler {
+	if cfg.MaxProcessing <= 0 {
+		cfg.MaxProcessing = 1
+	}
+	return &UploadHandler{
+		Repo:       repo,
+		Store:      store,
+		Config:     cfg,
+		processing: make(chan struct{}, cfg.MaxProcessing),
+	}
+}
+
+// Accepts the image in the "file" field of the multipart form.
+// The returned image id is used to create the post of type "image".
+func (uh *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	uploader, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+	if err := uploader.CanWrite(time.Now()); err != nil {
+		WriteMsg(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	data, err := uh.readFile(w, r)
+	if errors.Is(err, ErrTooLarge) {
+		WriteMsg(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	processed, err := uh.process(r.Context(), data)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		WriteMsg(w, "request cancelled", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, ErrTooLarge) {
+		WriteMsg(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, ErrUnsupported) {
+		WriteMsg(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't process uploaded image: %v", err)
+		WriteMsg(w, "failed processing image", http.StatusInternalServerError)
+		return
+	}
+
+	img := &Image{
+		Id:          RandStringRunes(16),
+		OwnerId:     uploader.Id,
+		ContentType: processed.ContentType,
+		Size:        int64(len(processed.Data)),
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Created:     time.Now(),
+	}
+	img.Key = "images/" + img.Id + processed.Ext
+	img.ThumbKey = "images/" + img.Id + "_thumb" + processed.Ext
+	img.setURLs()
+
+	if err := uh.store(r.Context(), img, processed); err != nil {
+		logger.Log(r.Context()).Errorf("can't store uploaded image: %v", err)
+		WriteMsg(w, "failed storing image", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	WriteRespJSON(w, img)
+}
+
+// Waits for a free processing slot, so only Config.MaxProcessing decoded images are in memory.
+func (uh *UploadHandler) process(ctx context.Context, data []byte) (*Processed, error) {
+	select {
+	case uh.processing <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-uh.processing }()
+	return Process(data, uh.Config)
+}
+
+// Reads the "file" part of the multipart body up to the max size.
+func (uh *UploadHandler) readFile(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	// Leaves room for the multipart headers and the other fields.
+	r.Body = http.MaxBytesReader(w, r.Body, uh.Config.MaxSize+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("expected multipart/form-data body")
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("file field is missing")
+		}
+		if err != nil {
+			if strings.Contains(err.Error(), "request body too large") {
+				return nil, ErrTooLarge
+			}
+			return nil, errors.New("bad multipart body")
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, uh.Config.MaxSize+1))
+		if err != nil {
+			if strings.Contains(err.Error(), "request body too large") {
+				return nil, ErrTooLarge
+			}
+			return nil, errors.New("bad multipart body")
+		}
+		if int64(len(data)) > uh.Config.MaxSize {
+			return nil, ErrTooLarge
+		}
+		return data, nil
+	}
+}
+
+// Saves the files and then the record, removing the files if anything fails.
+func (uh *UploadHandler) store(ctx context.Context, img *Image, processed *Processed) error {
+	err := uh.Store.Put(ctx, img.Key, bytes.NewReader(processed.Data), int64(len(processed.Data)), img.ContentType)
+	if err != nil {
+		return err
+	}
+	err = uh.Store.Put(ctx, img.ThumbKey, bytes.NewReader(processed.Thumb), int64(len(processed.Thumb)), img.ContentType)
+	if err == nil {
+		err = uh.Repo.Add(ctx, img)
+	}
+	if err != nil {
+		if cleanupErr := uh.Store.Delete(ctx, img.Key); cleanupErr != nil {
+			logger.Log(ctx).Warnf("can't delete orphaned upload %s: %v", img.Key, cleanupErr)
+		}
+		if cleanupErr := uh.Store.Delete(ctx, img.ThumbKey); cleanupErr != nil {
+			logger.Log(ctx).Warnf("can't delete orphaned upload %s: %v", img.ThumbKey, cleanupErr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported image")
+	ErrTooLarge    = errors.New("image is too large")
+)
+
+// Config limits the uploaded images.
+type Config struct {
+	// Max file size in bytes.
+	MaxSize int64
+	// Max width*height, guards against decompression bombs.
+	// Each pixel takes 4 bytes and the rotation allocates a second buffer.
+	MaxPixels int
+	// Thumbnails fit into the square of this side.
+	ThumbSize int
+	// Max images processed at the same time, bounds the memory taken by the decoded images.
+	MaxProcessing int
+}
+
+var DefaultConfig = Config{
+	MaxSize:       10 << 20,
+	MaxPixels:     16_000_000,
+	ThumbSize:     320,
+	MaxProcessing: 2,
+}
+
+// Processed is the cleaned up image ready to be stored.
+type Processed struct {
+	Data        []byte
+	Thumb       []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// Checks the image and re-encodes it, dropping EXIF and any other metadata.
+// JPEGs are rotated according to their EXIF orientation first,
+// GIFs are stored as PNGs of their first frame.
+func Process(data []byte, cfg Config) (*Processed, error) {
+	if int64(len(data)) > cfg.MaxSize {
+		return nil, fmt.Errorf("%w: max size is %d bytes", ErrTooLarge, cfg.MaxSize)
+	}
+	sniffed := http.DetectContentType(data)
+	switch sniffed {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, fmt.Errorf("%w: only jpeg, png and gif are allowed, got %s", ErrUnsupported, sniffed)
+	}
+
+	imgCfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if imgCfg.Width <= 0 || imgCfg.Height <= 0 || imgCfg.Width*imgCfg.Height > cfg.MaxPixels {
+		return nil, fmt.Errorf("%w: max %d pixels allowed", ErrTooLarge, cfg.MaxPixels)
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	img := toRGBA(decoded)
+	if sniffed == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	res := &Processed{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+	encode := func(img image.Image) ([]byte, error) {
+		var buf bytes.Buffer
+		var err error
+		if sniffed == "image/jpeg" {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, img)
+		}
+		return buf.Bytes(), err
+	}
+	if sniffed == "image/jpeg" {
+		res.ContentType, res.Ext = "image/jpeg", ".jpg"
+	} else {
+		res.ContentType, res.Ext = "image/png", ".png"
+	}
+	if res.Data, err = encode(img); err != nil {
+		return nil, fmt.Errorf("upload/image: failed encoding image: %w", err)
+	}
+	if res.Thumb, err = encode(thumbnail(img, cfg.ThumbSize)); err != nil {
+		return nil, fmt.Errorf("upload/image: failed encoding thumbnail: %w", err)
+	}
+	return res, nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// Applies the EXIF orientation (1-8) to the image.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // flipped
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counterclockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
+
+// Returns the EXIF orientation of the JPEG, 1 (normal) if there is none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// The image data starts, no metadata after it.
+		if marker == 0xDA {
+			return 1
+		}
+		segLen := int(binary.BigEndian.Uint16(data[i+2:]))
+		if segLen < 2 || i+2+segLen > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+segLen]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return exifOrientation(seg[6:])
+		}
+		i += 2 + segLen
+	}
+	return 1
+}
+
+// Finds the orientation tag in the first IFD of the EXIF TIFF structure.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int64(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > int64(len(tiff)) {
+		return 1
+	}
+	ifd := tiff[offset:]
+	entries := int(order.Uint16(ifd))
+	for k := 0; k < entries; k++ {
+		entry := 2 + k*12
+		if entry+12 > len(ifd) {
+			return 1
+		}
+		if order.Uint16(ifd[entry:]) == 0x0112 {
+			if v := int(order.Uint16(ifd[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// Downscales the image to fit into the size x size square averaging
+// the source pixels, smaller images are returned as is.
+func thumbnail(src *image.RGBA, size int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	tw, th := size, size
+	if w >= h {
+		th = h * size / w
+	} else {
+		tw = w * size / h
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, (ty+1)*h/th
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, (tx+1)*w/tw
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				px := src.Pix[src.PixOffset(x0, y):]
+				for x := 0; x < x1-x0; x++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(px[x*4+c])
+					}
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			out := dst.Pix[dst.PixOffset(tx, ty):]
+			for c := 0; c < 4; c++ {
+				out[c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,143 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	// Marks the top left corner to check the orientation.
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	return img
+}
+
+// Inserts the APP1 EXIF segment with the orientation and a GPS-like string after SOI.
+func withExif(jpg []byte, orientation uint16) []byte {
+	tiff := new(bytes.Buffer)
+	tiff.WriteString("II")
+	binary.Write(tiff, binary.LittleEndian, uint16(42))
+	binary.Write(tiff, binary.LittleEndian, uint32(8))
+	binary.Write(tiff, binary.LittleEndian, uint16(1))
+	binary.Write(tiff, binary.LittleEndian, []uint16{0x0112, 3})
+	binary.Write(tiff, binary.LittleEndian, uint32(1))
+	binary.Write(tiff, binary.LittleEndian, []uint16{orientation, 0})
+	binary.Write(tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString("GPS 52.5200N 13.4050E")
+
+	seg := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	header := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(seg)+2))
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, header...)
+	out = append(out, seg...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	cfg := Config{MaxSize: 1 << 20, MaxPixels: 1_000_000, ThumbSize: 32}
+
+	t.Run("should strip EXIF and apply the orientation", func(t *testing.T) {
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, testImage(120, 60), nil)
+		data := withExif(buf.Bytes(), 6)
+		assert.Equal(t, 6, jpegOrientation(data))
+
+		res, err := Process(data, cfg)
+		assert.Nil(t, err)
+		assert.Equal(t, "image/jpeg", res.ContentType)
+		assert.Equal(t, 60, res.Width)
+		assert.Equal(t, 120, res.Height)
+		assert.NotContains(t, string(res.Data), "Exif")
+		assert.NotContains(t, string(res.Data), "GPS")
+		assert.Equal(t, 1, jpegOrientation(res.Data))
+
+		rotated, err := jpeg.Decode(bytes.NewReader(res.Data))
+		assert.Nil(t, err)
+		r, _, _, _ := rotated.At(56, 3).RGBA()
+		assert.Greater(t, r>>8, uint32(150), "the top left corner should be rotated to the top right")
+
+		thumb, _, err := image.DecodeConfig(bytes.NewReader(res.Thumb))
+		assert.Nil(t, err)
+		assert.Equal(t, image.Config{ColorModel: thumb.ColorModel, Width: 16, Height: 32}, thumb)
+	})
+
+	t.Run("should keep small PNGs as they are", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, testImage(20, 10))
+		res, err := Process(buf.Bytes(), cfg)
+		assert.Nil(t, err)
+		assert.Equal(t, "image/png", res.ContentType)
+		assert.Equal(t, ".png", res.Ext)
+		assert.Equal(t, res.Data, res.Thumb)
+	})
+
+	t.Run("should reject unsupported files", func(t *testing.T) {
+		for _, data := range [][]byte{
+			[]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"),
+			[]byte("\x89PNG\r\n\x1a\nbroken"),
+			{},
+		} {
+			_, err := Process(data, cfg)
+			assert.ErrorIs(t, err, ErrUnsupported)
+		}
+	})
+
+	t.Run("should reject too large images", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2000, 1000)))
+		_, err := Process(buf.Bytes(), cfg)
+		assert.ErrorIs(t, err, ErrTooLarge)
+
+		_, err = Process(make([]byte, cfg.MaxSize+1), cfg)
+		assert.ErrorIs(t, err, ErrTooLarge)
+	})
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Pix[0] = 1 // red of (0, 0)
+	for orientation, want := range map[int]image.Point{
+		1: {0, 0}, 2: {1, 0}, 3: {1, 0}, 4: {0, 0},
+		5: {0, 0}, 6: {0, 0}, 7: {0, 1}, 8: {0, 1},
+	} {
+		dst := orient(src, orientation)
+		assert.Equal(t, uint8(1), dst.Pix[dst.PixOffset(want.X, want.Y)], orientation)
+	}
+}
+
+func TestProcessingLimit(t *testing.T) {
+	uh := NewUploadHandler(nil, nil, Config{MaxSize: 1 << 20, MaxPixels: 1_000_000, ThumbSize: 32, MaxProcessing: 1})
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(10, 10))
+
+	uh.processing <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := uh.process(ctx, buf.Bytes())
+	assert.ErrorIs(t, err, context.Canceled, "should wait for the busy slot")
+
+	<-uh.processing
+	res, err := uh.process(context.Background(), buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, 10, res.Width)
+	assert.Empty(t, uh.processing, "should free the slot")
+}
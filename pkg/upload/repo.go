@@ -0,0 +1,73 @@
+package upload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNotFound = errors.New("upload not found")
+
+// Served by the uploads handler under this path.
+const PublicPath = "/uploads/"
+
+// Image is the uploaded image attached to the posts of type "image".
+type Image struct {
+	Id          string    `json:"id"`
+	URL         string    `json:"url"`
+	ThumbURL    string    `json:"thumbUrl"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Created     time.Time `json:"created"`
+
+	OwnerId  string `json:"-" bson:"-"`
+	Key      string `json:"-" bson:"-"`
+	ThumbKey string `json:"-" bson:"-"`
+}
+
+type Repo struct {
+	db *sql.DB
+}
+
+func NewUploadRepo(db *sql.DB) *Repo {
+	return &Repo{
+		db: db,
+	}
+}
+
+func (r *Repo) Add(ctx context.Context, img *Image) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO uploads(id, user_id, key, thumb_key, content_type, size, width, height, created)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		img.Id, img.OwnerId, img.Key, img.ThumbKey, img.ContentType, img.Size, img.Width, img.Height, img.Created)
+	if err != nil {
+		return fmt.Errorf("upload/repo: failed adding upload: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) GetById(ctx context.Context, id string) (*Image, error) {
+	img := &Image{Id: id}
+	row := r.db.QueryRowContext(ctx,
+		`SELECT user_id, key, thumb_key, content_type, size, width, height, created
+		 FROM uploads WHERE id=$1`, id)
+	err := row.Scan(&img.OwnerId, &img.Key, &img.ThumbKey, &img.ContentType,
+		&img.Size, &img.Width, &img.Height, &img.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("upload/repo: could not scan row: %w", err)
+	}
+	img.setURLs()
+	return img, nil
+}
+
+func (img *Image) setURLs() {
+	img.URL = PublicPath + img.Key
+	img.ThumbURL = PublicPath + img.ThumbKey
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/user"
+)
+
+type (
+	SanctionRepo interface {
+		Apply(context.Context, *user.Sanction) error
+		GetUserSanctions(context.Context, string) ([]*user.Sanction, error)
+	}
+
+	AdminHandler struct {
+		Sanctions SanctionRepo
+	}
+
+	HttpSanction struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until,omitempty"`
+	}
+)
+
+func NewAdminHandler(sr SanctionRepo) *AdminHandler {
+	return &AdminHandler{
+		Sanctions: sr,
+	}
+}
+
+// Suspends, bans, shadowbans the user or lifts the sanctions
+// depending on the `action` path variable.
+func (ah AdminHandler) Sanction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	httpSanction := new(HttpSanction)
+	if err := common.ParseReqBody(r.Body, httpSanction); err != nil {
+		logger.Log(r.Context()).Errorf("can't parse sanction: %v", err)
+		common.WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+
+	s := &user.Sanction{
+		UserId:  vars["user_id"],
+		ActorId: admin.Id,
+		Kind:    user.SanctionKind(vars["action"]),
+		Reason:  httpSanction.Reason,
+		Until:   httpSanction.Until,
+		Created: time.Now(),
+	}
+	if s.UserId == admin.Id {
+		common.WriteMsg(w, "can't sanction yourself", http.StatusBadRequest)
+		return
+	}
+	if err := s.Validate(s.Created); err != nil {
+		common.WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.Sanctions.Apply(r.Context(), s); err != nil {
+		logger.Log(r.Context()).Errorf("can't apply sanction to user %s: %v", s.UserId, err)
+		if errors.Is(err, user.ErrBadSanction) {
+			common.WriteMsg(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, user.ErrUserNotFound) {
+			common.WriteMsg(w, "user not found", http.StatusNotFound)
+			return
+		}
+		common.WriteMsg(w, "failed applying sanction", http.StatusInternalServerError)
+		return
+	}
+	logger.Log(r.Context()).Infof("admin %s applied %s to user %s: %s", admin.Id, s.Kind, s.UserId, s.Reason)
+
+	w.WriteHeader(http.StatusCreated)
+	common.WriteRespJSON(w, s)
+}
+
+func (ah AdminHandler) ListSanctions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	userId := mux.Vars(r)["user_id"]
+	sanctions, err := ah.Sanctions.GetUserSanctions(r.Context(), userId)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load sanctions of user %s: %v", userId, err)
+		common.WriteMsg(w, "failed loading sanctions", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteRespJSON(w, sanctions)
+}
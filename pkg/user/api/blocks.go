@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+type (
+	BlockRepo interface {
+		Add(context.Context, *user.Block) error
+		Delete(context.Context, string, string) error
+		GetUserBlocks(context.Context, string) ([]*user.Block, error)
+	}
+
+	BlockHandler struct {
+		Blocks BlockRepo
+	}
+)
+
+func NewBlockHandler(br BlockRepo) *BlockHandler {
+	return &BlockHandler{
+		Blocks: br,
+	}
+}
+
+func (bh BlockHandler) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	blocks, err := bh.Blocks.GetUserBlocks(r.Context(), authUser.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load blocks of user %s: %v", authUser.Id, err)
+		common.WriteMsg(w, "failed loading blocks", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteRespJSON(w, blocks)
+}
+
+// Blocks or mutes the user, body: `{"targetId": "2", "kind": "block|mute"}`.
+func (bh BlockHandler) Add(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	block := new(user.Block)
+	if err := common.ParseReqBody(r.Body, block); err != nil {
+		logger.Log(r.Context()).Errorf("can't parse block: %v", err)
+		common.WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	block.UserId = authUser.Id
+	block.Created = time.Now()
+	if err := block.Validate(); err != nil {
+		common.WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := bh.Blocks.Add(r.Context(), block); err != nil {
+		logger.Log(r.Context()).Errorf("can't add block: %v", err)
+		if errors.Is(err, user.ErrUserNotFound) {
+			common.WriteMsg(w, "user not found", http.StatusNotFound)
+			return
+		}
+		common.WriteMsg(w, "failed blocking user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	common.WriteRespJSON(w, block)
+}
+
+func (bh BlockHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetId := mux.Vars(r)["user_id"]
+	if err := bh.Blocks.Delete(r.Context(), authUser.Id, targetId); err != nil {
+		logger.Log(r.Context()).Errorf("can't remove block of %s: %v", targetId, err)
+		common.WriteMsg(w, "failed unblocking user", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteMsg(w, "success", http.StatusOK)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+type (
+	FollowRepo interface {
+		Follow(ctx context.Context, userId, targetId string) error
+		Unfollow(ctx context.Context, userId, targetId string) error
+		GetFollowing(context.Context, string) ([]*user.User, error)
+		GetFollowers(context.Context, string) ([]*user.User, error)
+	}
+
+	SubscriptionRepo interface {
+		GetSubscriptions(context.Context, string) ([]string, error)
+	}
+
+	// The cached home feeds built from the follows.
+	FeedCache interface {
+		Invalidate(userId string)
+	}
+
+	FollowHandler struct {
+		Follows       FollowRepo
+		Subscriptions SubscriptionRepo
+		Feed          FeedCache
+	}
+)
+
+func NewFollowHandler(fr FollowRepo, sr SubscriptionRepo, feed FeedCache) *FollowHandler {
+	return &FollowHandler{
+		Follows:       fr,
+		Subscriptions: sr,
+		Feed:          feed,
+	}
+}
+
+func (fh FollowHandler) ListFollowing(w http.ResponseWriter, r *http.Request) {
+	fh.listUsers(w, r, fh.Follows.GetFollowing)
+}
+
+func (fh FollowHandler) ListFollowers(w http.ResponseWriter, r *http.Request) {
+	fh.listUsers(w, r, fh.Follows.GetFollowers)
+}
+
+func (fh FollowHandler) listUsers(w http.ResponseWriter, r *http.Request,
+	get func(context.Context, string) ([]*user.User, error),
+) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := get(r.Context(), authUser.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load follows of user %s: %v", authUser.Id, err)
+		common.WriteMsg(w, "failed loading follows", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteRespJSON(w, users)
+}
+
+// Follows the user, body: `{"targetId": "2"}`.
+func (fh FollowHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	target := struct {
+		TargetId string `json:"targetId"`
+	}{}
+	if err := common.ParseReqBody(r.Body, &target); err != nil {
+		logger.Log(r.Context()).Errorf("can't parse follow target: %v", err)
+		common.WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	if target.TargetId == "" || target.TargetId == authUser.Id {
+		common.WriteMsg(w, "bad target user", http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.Follows.Follow(r.Context(), authUser.Id, target.TargetId); err != nil {
+		logger.Log(r.Context()).Errorf("can't follow user %s: %v", target.TargetId, err)
+		if errors.Is(err, user.ErrUserNotFound) {
+			common.WriteMsg(w, "user not found", http.StatusNotFound)
+			return
+		}
+		common.WriteMsg(w, "failed following user", http.StatusInternalServerError)
+		return
+	}
+	fh.Feed.Invalidate(authUser.Id)
+
+	common.WriteMsg(w, "success", http.StatusCreated)
+}
+
+func (fh FollowHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetId := mux.Vars(r)["user_id"]
+	if err := fh.Follows.Unfollow(r.Context(), authUser.Id, targetId); err != nil {
+		logger.Log(r.Context()).Errorf("can't unfollow user %s: %v", targetId, err)
+		common.WriteMsg(w, "failed unfollowing user", http.StatusInternalServerError)
+		return
+	}
+	fh.Feed.Invalidate(authUser.Id)
+
+	common.WriteMsg(w, "success", http.StatusOK)
+}
+
+func (fh FollowHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	categories, err := fh.Subscriptions.GetSubscriptions(r.Context(), authUser.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load subscriptions of user %s: %v", authUser.Id, err)
+		common.WriteMsg(w, "failed loading subscriptions", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteRespJSON(w, categories)
+}
@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"crud/pkg/common"
 	"crud/pkg/logger"
+	"crud/pkg/sessions"
 	"crud/pkg/user"
 )
 
@@ -22,21 +24,34 @@ type (
 		CleanupUserSessions(userId string) error
 	}
 
+	InviteRepo interface {
+		Create(context.Context, string) (*user.Invite, error)
+		GetAll(context.Context) ([]*user.Invite, error)
+		Claim(context.Context, string, string) error
+		Release(context.Context, string) error
+	}
+
 	UserHandler struct {
 		Repo           UserRepo
 		SessionManager SessionManager
+		Invites        InviteRepo
+		Policy         *user.Policy
 	}
 
 	HttpUser struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
+		// Required only when the registration is invite-only.
+		Invite string `json:"invite,omitempty"`
 	}
 )
 
-func NewUserHanler(r UserRepo, sm SessionManager) *UserHandler {
+func NewUserHanler(r UserRepo, sm SessionManager, invites InviteRepo, policy *user.Policy) *UserHandler {
 	return &UserHandler{
 		Repo:           r,
 		SessionManager: sm,
+		Invites:        invites,
+		Policy:         policy,
 	}
 }
 
@@ -81,30 +96,126 @@ func (uh UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := uh.validate(httpUser); err != nil {
+		logger.Log(r.Context()).Infof("registration of `%s` rejected: %v", httpUser.Username, err)
+		common.WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Check if user already exists
 	if uh.Repo.UserExists(httpUser.Username) {
-		msg := fmt.Sprintf(`user "%s" already exists`, httpUser.Username)
-		logger.Log(r.Context()).Error(msg)
-		common.WriteMsg(w, msg, http.StatusConflict)
+		uh.userExists(w, r, httpUser.Username)
 		return
 	}
 
+	if uh.Policy.InviteOnly {
+		err := uh.Invites.Claim(r.Context(), httpUser.Invite, httpUser.Username)
+		if errors.Is(err, user.ErrInviteInvalid) {
+			common.WriteMsg(w, "invite code is invalid", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			logger.Log(r.Context()).Errorf("can't claim invite: %v", err)
+			common.WriteMsg(w, "can't add user", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	salt := common.RandStringRunes(8)
 	pass := common.HashPass(httpUser.Password, salt)
-	user := &user.User{
+	newUser := &user.User{
 		Username: httpUser.Username,
 		Password: pass,
 		// Id is handled below
 	}
-	id, err := uh.Repo.Add(user)
+	id, err := uh.Repo.Add(newUser)
 	if err != nil {
+		uh.releaseInvite(r.Context(), httpUser.Invite)
+		if errors.Is(err, user.ErrUserExists) {
+			uh.userExists(w, r, httpUser.Username)
+			return
+		}
+		logger.Log(r.Context()).Errorf("can't add user: %v", err)
 		common.WriteMsg(w, "can't add user", http.StatusInternalServerError)
 		return
 	}
-	user.Id = id
+	newUser.Id = id
 
 	w.WriteHeader(http.StatusCreated)
-	uh.sendToken(w, user)
+	uh.sendToken(w, newUser)
+}
+
+// Creates an invite code. Available for admins only.
+func (uh UserHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	invite, err := uh.Invites.Create(r.Context(), admin.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't create invite: %v", err)
+		common.WriteMsg(w, "failed creating invite", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	common.WriteRespJSON(w, invite)
+}
+
+func (uh UserHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	invites, err := uh.Invites.GetAll(r.Context())
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load invites: %v", err)
+		common.WriteMsg(w, "failed loading invites", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteRespJSON(w, invites)
+}
+
+func (uh *UserHandler) validate(httpUser *HttpUser) error {
+	if err := uh.Policy.ValidateUsername(httpUser.Username); err != nil {
+		return err
+	}
+	return uh.Policy.ValidatePassword(httpUser.Password, httpUser.Username)
+}
+
+func (uh *UserHandler) userExists(w http.ResponseWriter, r *http.Request, username string) {
+	msg := fmt.Sprintf(`user "%s" already exists`, username)
+	logger.Log(r.Context()).Error(msg)
+	common.WriteMsg(w, msg, http.StatusConflict)
+}
+
+func (uh *UserHandler) releaseInvite(ctx context.Context, code string) {
+	if !uh.Policy.InviteOnly {
+		return
+	}
+	if err := uh.Invites.Release(ctx, code); err != nil {
+		logger.Log(ctx).Errorf("can't release invite: %v", err)
+	}
+}
+
+// Returns the authenticated user if it's an admin, otherwise writes the error response.
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*user.User, bool) {
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if !authUser.Admin {
+		common.WriteMsg(w, "admin access required", http.StatusForbidden)
+		return nil, false
+	}
+	return authUser, true
 }
 
 func (uh *UserHandler) sendToken(w http.ResponseWriter, user *user.User) {
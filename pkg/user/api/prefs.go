@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"crud/pkg/common"
+	"crud/pkg/logger"
+	"crud/pkg/sessions"
+	"crud/pkg/user"
+)
+
+type (
+	PrefsRepo interface {
+		GetContentPrefs(context.Context, string) (user.ContentPrefs, error)
+		SaveContentPrefs(context.Context, string, user.ContentPrefs) error
+	}
+
+	PrefsHandler struct {
+		Prefs PrefsRepo
+	}
+)
+
+func NewPrefsHandler(pr PrefsRepo) *PrefsHandler {
+	return &PrefsHandler{
+		Prefs: pr,
+	}
+}
+
+func (ph PrefsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs, err := ph.Prefs.GetContentPrefs(r.Context(), authUser.Id)
+	if err != nil {
+		logger.Log(r.Context()).Errorf("can't load preferences of user %s: %v", authUser.Id, err)
+		common.WriteMsg(w, "failed loading preferences", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteRespJSON(w, prefs)
+}
+
+// Replaces the content preferences, body: `{"nsfw": "show|blur|hide", "spoiler": "show|blur|hide"}`.
+func (ph PrefsHandler) Save(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	authUser, err := sessions.GetAuthUser(r.Context())
+	if err != nil {
+		common.WriteMsg(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs := user.DefaultContentPrefs()
+	if err := common.ParseReqBody(r.Body, &prefs); err != nil {
+		common.WriteMsg(w, "bad request format", http.StatusBadRequest)
+		return
+	}
+	if err := prefs.Validate(); err != nil {
+		common.WriteMsg(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ph.Prefs.SaveContentPrefs(r.Context(), authUser.Id, prefs); err != nil {
+		logger.Log(r.Context()).Errorf("can't save preferences of user %s: %v", authUser.Id, err)
+		common.WriteMsg(w, "failed saving preferences", http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteRespJSON(w, prefs)
+}
@@ -0,0 +1,126 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"crud/pkg/common"
+)
+
+type BlockKind string
+
+const (
+	// Blocked users can't comment on the blocker's posts
+	// and their content is hidden from the blocker.
+	BlockFull BlockKind = "block"
+	// Content of muted users is only hidden from the muter.
+	BlockMute BlockKind = "mute"
+)
+
+var ErrBadBlock = errors.New("user/block: invalid block")
+
+type Block struct {
+	UserId   string    `json:"userId"`
+	TargetId string    `json:"targetId"`
+	Kind     BlockKind `json:"kind"`
+	Created  time.Time `json:"created"`
+}
+
+func (b *Block) Validate() error {
+	if b.TargetId == "" || b.TargetId == b.UserId {
+		return fmt.Errorf("%w: bad target user", ErrBadBlock)
+	}
+	if b.Kind == "" {
+		b.Kind = BlockFull
+	}
+	if b.Kind != BlockFull && b.Kind != BlockMute {
+		return fmt.Errorf("%w: unknown kind %q", ErrBadBlock, b.Kind)
+	}
+	return nil
+}
+
+type BlockRepo struct {
+	db *sql.DB
+}
+
+func NewBlockRepo(db *sql.DB) *BlockRepo {
+	return &BlockRepo{
+		db: db,
+	}
+}
+
+// Adds the block or changes the kind of the existing one.
+func (r *BlockRepo) Add(ctx context.Context, b *Block) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_blocks(user_id, target_id, kind, created) VALUES($1, $2, $3, $4)
+		 ON CONFLICT (user_id, target_id) DO UPDATE SET kind=EXCLUDED.kind`,
+		b.UserId, b.TargetId, b.Kind, b.Created)
+	if err != nil {
+		if common.IsForeignKeyViolation(err) {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, b.TargetId)
+		}
+		return fmt.Errorf("user/block: failed adding block: %w", err)
+	}
+	return nil
+}
+
+func (r *BlockRepo) Delete(ctx context.Context, userId, targetId string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM user_blocks WHERE user_id=$1 AND target_id=$2", userId, targetId)
+	if err != nil {
+		return fmt.Errorf("user/block: failed deleting block: %w", err)
+	}
+	return nil
+}
+
+func (r *BlockRepo) GetUserBlocks(ctx context.Context, userId string) ([]*Block, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT user_id, target_id, kind, created FROM user_blocks WHERE user_id=$1 ORDER BY created DESC", userId)
+	if err != nil {
+		return nil, fmt.Errorf("user/block: failed querying blocks: %w", err)
+	}
+	defer rows.Close()
+
+	blocks := []*Block{}
+	for rows.Next() {
+		b := new(Block)
+		if err := rows.Scan(&b.UserId, &b.TargetId, &b.Kind, &b.Created); err != nil {
+			return nil, fmt.Errorf("user/block: could not scan row: %w", err)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// Reports if the blocker has fully blocked the user (mutes don't count).
+func (r *BlockRepo) IsBlocked(ctx context.Context, blockerId, userId string) (bool, error) {
+	var exists bool
+	row := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM user_blocks WHERE user_id=$1 AND target_id=$2 AND kind=$3)",
+		blockerId, userId, BlockFull)
+	if err := row.Scan(&exists); err != nil {
+		return false, fmt.Errorf("user/block: could not scan row: %w", err)
+	}
+	return exists, nil
+}
+
+// Returns Ids of the blocked and muted users whose content must be hidden from the user.
+func (r *BlockRepo) FilteredIds(ctx context.Context, userId string) (map[string]struct{}, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT target_id FROM user_blocks WHERE user_id=$1", userId)
+	if err != nil {
+		return nil, fmt.Errorf("user/block: failed querying blocks: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[string]struct{}{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("user/block: could not scan row: %w", err)
+		}
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+}
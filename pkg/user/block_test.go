@@ -0,0 +1,48 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	. "crud/pkg/common"
+)
+
+func TestBlockAdd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	r := NewBlockRepo(db)
+	b := &Block{UserId: userID, TargetId: "2", Kind: BlockFull, Created: time.Now()}
+
+	t.Run("should add block", func(t *testing.T) {
+		mock.
+			ExpectExec("INSERT INTO user_blocks").
+			WithArgs(b.UserId, b.TargetId, b.Kind, b.Created).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, r.Add(context.TODO(), b))
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+		}
+	})
+
+	t.Run("should return ErrUserNotFound for unknown target", func(t *testing.T) {
+		mock.
+			ExpectExec("INSERT INTO user_blocks").
+			WithArgs(b.UserId, b.TargetId, b.Kind, b.Created).
+			WillReturnError(&pgconn.PgError{Code: PgForeignKeyViolation})
+
+		err := r.Add(context.TODO(), b)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+		}
+	})
+}
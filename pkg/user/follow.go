@@ -0,0 +1,70 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"crud/pkg/common"
+)
+
+type FollowRepo struct {
+	db *sql.DB
+}
+
+func NewFollowRepo(db *sql.DB) *FollowRepo {
+	return &FollowRepo{
+		db: db,
+	}
+}
+
+func (r *FollowRepo) Follow(ctx context.Context, userId, targetId string) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO follows(user_id, target_id) VALUES($1, $2) ON CONFLICT DO NOTHING", userId, targetId)
+	if err != nil {
+		if common.IsForeignKeyViolation(err) {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, targetId)
+		}
+		return fmt.Errorf("user/follow: failed following user: %w", err)
+	}
+	return nil
+}
+
+func (r *FollowRepo) Unfollow(ctx context.Context, userId, targetId string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM follows WHERE user_id=$1 AND target_id=$2", userId, targetId)
+	if err != nil {
+		return fmt.Errorf("user/follow: failed unfollowing user: %w", err)
+	}
+	return nil
+}
+
+// Returns users followed by the user.
+func (r *FollowRepo) GetFollowing(ctx context.Context, userId string) ([]*User, error) {
+	return r.queryUsers(ctx,
+		"SELECT u.id, u.username FROM follows f JOIN users u ON u.id = f.target_id WHERE f.user_id=$1 ORDER BY u.username",
+		userId)
+}
+
+func (r *FollowRepo) GetFollowers(ctx context.Context, userId string) ([]*User, error) {
+	return r.queryUsers(ctx,
+		"SELECT u.id, u.username FROM follows f JOIN users u ON u.id = f.user_id WHERE f.target_id=$1 ORDER BY u.username",
+		userId)
+}
+
+func (r *FollowRepo) queryUsers(ctx context.Context, query string, args ...interface{}) ([]*User, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("user/follow: failed querying follows: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		u := new(User)
+		if err := rows.Scan(&u.Id, &u.Username); err != nil {
+			return nil, fmt.Errorf("user/follow: could not scan row: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
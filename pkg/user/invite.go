@@ -0,0 +1,104 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInviteInvalid = errors.New("user/invite: invite code is invalid or already used")
+
+type Invite struct {
+	Code      string     `json:"code"`
+	CreatedBy string     `json:"createdBy"`
+	UsedBy    *string    `json:"usedBy,omitempty"`
+	Created   time.Time  `json:"created"`
+	Used      *time.Time `json:"used,omitempty"`
+}
+
+type InviteRepo struct {
+	db *sql.DB
+}
+
+func NewInviteRepo(db *sql.DB) *InviteRepo {
+	return &InviteRepo{
+		db: db,
+	}
+}
+
+func (r *InviteRepo) Create(ctx context.Context, createdBy string) (*Invite, error) {
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	inv := &Invite{
+		Code:      code,
+		CreatedBy: createdBy,
+		Created:   time.Now(),
+	}
+	_, err = r.db.ExecContext(ctx, "INSERT INTO invites(code, created_by, created) VALUES($1, $2, $3)",
+		inv.Code, inv.CreatedBy, inv.Created)
+	if err != nil {
+		return nil, fmt.Errorf("user/invite: failed creating invite: %w", err)
+	}
+	return inv, nil
+}
+
+// The invite codes are the only credential for signing up, so they are
+// generated with crypto/rand to be unguessable.
+func newInviteCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("user/invite: failed generating invite code: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (r *InviteRepo) GetAll(ctx context.Context) ([]*Invite, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT code, created_by, used_by, created, used FROM invites ORDER BY created DESC")
+	if err != nil {
+		return nil, fmt.Errorf("user/invite: failed querying invites: %w", err)
+	}
+	defer rows.Close()
+
+	invites := []*Invite{}
+	for rows.Next() {
+		inv := new(Invite)
+		if err := rows.Scan(&inv.Code, &inv.CreatedBy, &inv.UsedBy, &inv.Created, &inv.Used); err != nil {
+			return nil, fmt.Errorf("user/invite: could not scan row: %w", err)
+		}
+		invites = append(invites, inv)
+	}
+	return invites, nil
+}
+
+// Marks the invite as used by the username. Only one registration
+// can claim the code, concurrent ones get ErrInviteInvalid.
+func (r *InviteRepo) Claim(ctx context.Context, code, username string) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE invites SET used_by=$2, used=NOW() WHERE code=$1 AND used_by IS NULL", code, username)
+	if err != nil {
+		return fmt.Errorf("user/invite: failed claiming invite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("user/invite: failed claiming invite: %w", err)
+	}
+	if n == 0 {
+		return ErrInviteInvalid
+	}
+	return nil
+}
+
+// Makes the claimed invite available again, e.g. when the registration failed.
+func (r *InviteRepo) Release(ctx context.Context, code string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE invites SET used_by=NULL, used=NULL WHERE code=$1", code)
+	if err != nil {
+		return fmt.Errorf("user/invite: failed releasing invite: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package user
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInviteCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	r := NewInviteRepo(db)
+
+	codes := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		mock.
+			ExpectExec("INSERT INTO invites").
+			WithArgs(sqlmock.AnyArg(), userID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		inv, err := r.Create(context.TODO(), userID)
+		assert.Nil(t, err)
+		_, err = hex.DecodeString(inv.Code)
+		assert.Nil(t, err, "the code is hex")
+		assert.Len(t, inv.Code, 16)
+		codes[inv.Code] = true
+	}
+	assert.Len(t, codes, 2, "the codes are random")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations unfulfilled: %s", err)
+	}
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var ErrPolicy = errors.New("registration policy violated")
+
+// Policy describes which usernames and passwords are accepted on registration.
+type Policy struct {
+	UsernameMinLen  int
+	UsernameMaxLen  int
+	UsernamePattern *regexp.Regexp
+	ReservedNames   map[string]struct{}
+
+	PasswordMinLen int
+	// Minimum number of character classes (lower, upper, digit, other)
+	// the password must contain.
+	PasswordMinClasses int
+	// Lowercased passwords known from public breaches.
+	BreachedPasswords map[string]struct{}
+
+	// Registration requires a valid invite code when enabled.
+	InviteOnly bool
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		UsernameMinLen:  3,
+		UsernameMaxLen:  32,
+		UsernamePattern: regexp.MustCompile(`^[a-zA-Z0-9_-]+$`),
+		ReservedNames: map[string]struct{}{
+			"admin":     {},
+			"root":      {},
+			"moderator": {},
+			"system":    {},
+			"api":       {},
+			"me":        {},
+		},
+		PasswordMinLen:     8,
+		PasswordMinClasses: 2,
+		BreachedPasswords:  map[string]struct{}{},
+	}
+}
+
+func (p *Policy) AddReservedNames(names ...string) {
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n != "" {
+			p.ReservedNames[n] = struct{}{}
+		}
+	}
+}
+
+// Reads breached passwords from the file, one password per line.
+func (p *Policy) LoadBreachedPasswords(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("user/policy: can't open breached passwords file: %w", err)
+	}
+	defer f.Close()
+	return p.ReadBreachedPasswords(f)
+}
+
+func (p *Policy) ReadBreachedPasswords(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		pass := strings.TrimSpace(scanner.Text())
+		if pass != "" {
+			p.BreachedPasswords[strings.ToLower(pass)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("user/policy: failed reading breached passwords: %w", err)
+	}
+	return nil
+}
+
+func (p *Policy) ValidateUsername(username string) error {
+	n := len([]rune(username))
+	if n < p.UsernameMinLen || n > p.UsernameMaxLen {
+		return fmt.Errorf("%w: username must be %d to %d characters long",
+			ErrPolicy, p.UsernameMinLen, p.UsernameMaxLen)
+	}
+	if p.UsernamePattern != nil && !p.UsernamePattern.MatchString(username) {
+		return fmt.Errorf("%w: username contains forbidden characters", ErrPolicy)
+	}
+	if _, reserved := p.ReservedNames[strings.ToLower(username)]; reserved {
+		return fmt.Errorf("%w: username is reserved", ErrPolicy)
+	}
+	return nil
+}
+
+func (p *Policy) ValidatePassword(password, username string) error {
+	if len([]rune(password)) < p.PasswordMinLen {
+		return fmt.Errorf("%w: password must be at least %d characters long", ErrPolicy, p.PasswordMinLen)
+	}
+	if passwordClasses(password) < p.PasswordMinClasses {
+		return fmt.Errorf("%w: password is too weak, mix letters, digits and symbols", ErrPolicy)
+	}
+	lowered := strings.ToLower(password)
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		return fmt.Errorf("%w: password must not contain the username", ErrPolicy)
+	}
+	if _, breached := p.BreachedPasswords[lowered]; breached {
+		return fmt.Errorf("%w: password is known from a data breach", ErrPolicy)
+	}
+	return nil
+}
+
+// Counts character classes used in the password: lower, upper, digit and other.
+func passwordClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
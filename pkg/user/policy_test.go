@@ -0,0 +1,45 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUsername(t *testing.T) {
+	p := DefaultPolicy()
+
+	t.Run("should accept valid username", func(t *testing.T) {
+		assert.Nil(t, p.ValidateUsername("pike_42"))
+	})
+
+	t.Run("should reject bad usernames", func(t *testing.T) {
+		for _, name := range []string{"", "ab", strings.Repeat("a", 33), "with space", "юзер", "Admin"} {
+			assert.ErrorIs(t, p.ValidateUsername(name), ErrPolicy, name)
+		}
+	})
+
+	t.Run("should reject configured reserved names", func(t *testing.T) {
+		p.AddReservedNames(" Support ")
+		assert.ErrorIs(t, p.ValidateUsername("support"), ErrPolicy)
+	})
+}
+
+func TestValidatePassword(t *testing.T) {
+	p := DefaultPolicy()
+	err := p.ReadBreachedPasswords(strings.NewReader("Password1\nqwerty123\n"))
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	t.Run("should accept strong password", func(t *testing.T) {
+		assert.Nil(t, p.ValidatePassword("correct-horse", "pike"))
+	})
+
+	t.Run("should reject weak passwords", func(t *testing.T) {
+		for _, pass := range []string{"", "short1", "onlyletters", "pike-is-cool", "password1", "QWERTY123"} {
+			assert.ErrorIs(t, p.ValidatePassword(pass, "pike"), ErrPolicy, pass)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"crud/pkg/common"
+)
+
+type PostMarkKind string
+
+const (
+	// Bookmarked posts, listed by `GET /api/me/saved`.
+	MarkSaved PostMarkKind = "saved"
+	// Posts excluded from the user's listings.
+	MarkHidden PostMarkKind = "hidden"
+)
+
+type PostMarkRepo struct {
+	db *sql.DB
+}
+
+func NewPostMarkRepo(db *sql.DB) *PostMarkRepo {
+	return &PostMarkRepo{
+		db: db,
+	}
+}
+
+func (r *PostMarkRepo) Mark(ctx context.Context, userId, postId string, kind PostMarkKind) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO post_marks(user_id, post_id, kind) VALUES($1, $2, $3) ON CONFLICT DO NOTHING",
+		userId, postId, kind)
+	if err != nil {
+		return fmt.Errorf("user/postmark: failed marking post: %w", err)
+	}
+	return nil
+}
+
+func (r *PostMarkRepo) Unmark(ctx context.Context, userId, postId string, kind PostMarkKind) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM post_marks WHERE user_id=$1 AND post_id=$2 AND kind=$3", userId, postId, kind)
+	if err != nil {
+		return fmt.Errorf("user/postmark: failed unmarking post: %w", err)
+	}
+	return nil
+}
+
+// Returns Ids of the posts marked by the user, the latest first.
+func (r *PostMarkRepo) GetMarked(ctx context.Context, userId string, kind PostMarkKind, page common.Page) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT post_id FROM post_marks WHERE user_id=$1 AND kind=$2
+		 ORDER BY created DESC LIMIT $3 OFFSET $4`,
+		userId, kind, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("user/postmark: failed querying marks: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("user/postmark: could not scan row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Returns Ids of all the posts hidden by the user.
+func (r *PostMarkRepo) HiddenIds(ctx context.Context, userId string) (map[string]struct{}, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT post_id FROM post_marks WHERE user_id=$1 AND kind=$2", userId, MarkHidden)
+	if err != nil {
+		return nil, fmt.Errorf("user/postmark: failed querying marks: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[string]struct{}{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("user/postmark: could not scan row: %w", err)
+		}
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+}
@@ -0,0 +1,78 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ContentMode tells how the listings show the sensitive posts.
+type ContentMode string
+
+const (
+	ContentShow ContentMode = "show"
+	// The posts are listed with `"blurred": true` for the client to blur them.
+	ContentBlur ContentMode = "blur"
+	ContentHide ContentMode = "hide"
+)
+
+var ErrBadPrefs = errors.New("user/prefs: invalid preferences")
+
+// ContentPrefs is how the user wants to see the NSFW and spoiler posts.
+type ContentPrefs struct {
+	NSFW    ContentMode `json:"nsfw"`
+	Spoiler ContentMode `json:"spoiler"`
+}
+
+// Safe preferences of the anonymous viewers and the users who haven't set theirs.
+func DefaultContentPrefs() ContentPrefs {
+	return ContentPrefs{
+		NSFW:    ContentHide,
+		Spoiler: ContentBlur,
+	}
+}
+
+func (p *ContentPrefs) Validate() error {
+	for _, m := range []ContentMode{p.NSFW, p.Spoiler} {
+		if m != ContentShow && m != ContentBlur && m != ContentHide {
+			return fmt.Errorf("%w: mode must be show, blur or hide, got %q", ErrBadPrefs, m)
+		}
+	}
+	return nil
+}
+
+type PrefsRepo struct {
+	db *sql.DB
+}
+
+func NewPrefsRepo(db *sql.DB) *PrefsRepo {
+	return &PrefsRepo{
+		db: db,
+	}
+}
+
+// Returns the user preferences, the default ones if they aren't set.
+func (r *PrefsRepo) GetContentPrefs(ctx context.Context, userId string) (ContentPrefs, error) {
+	prefs := DefaultContentPrefs()
+	row := r.db.QueryRowContext(ctx, "SELECT nsfw, spoiler FROM user_prefs WHERE user_id=$1", userId)
+	err := row.Scan(&prefs.NSFW, &prefs.Spoiler)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultContentPrefs(), nil
+	}
+	if err != nil {
+		return prefs, fmt.Errorf("user/prefs: could not scan row: %w", err)
+	}
+	return prefs, nil
+}
+
+func (r *PrefsRepo) SaveContentPrefs(ctx context.Context, userId string, prefs ContentPrefs) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_prefs(user_id, nsfw, spoiler) VALUES($1, $2, $3)
+		 ON CONFLICT (user_id) DO UPDATE SET nsfw=EXCLUDED.nsfw, spoiler=EXCLUDED.spoiler`,
+		userId, prefs.NSFW, prefs.Spoiler)
+	if err != nil {
+		return fmt.Errorf("user/prefs: failed saving preferences: %w", err)
+	}
+	return nil
+}
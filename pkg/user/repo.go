@@ -3,7 +3,6 @@ package user
 import (
 	"bytes"
 	"context"
-	"crud/pkg/common"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -11,6 +10,13 @@ import (
 	"strconv"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
+
+	"crud/pkg/common"
+)
+
+var (
+	ErrUserExists   = errors.New("user/repo: user already exists")
+	ErrUserNotFound = errors.New("user/repo: user not found")
 )
 
 type UserRepo struct {
@@ -26,6 +32,10 @@ func NewUserRepo(db *sql.DB) *UserRepo {
 func (r *UserRepo) Add(u *User) (string, error) {
 	result, err := r.db.Exec("INSERT INTO users(username, password) VALUES($1, $2)", u.Username, u.Password)
 	if err != nil {
+		// The username may be taken between UserExists and Add.
+		if common.IsUniqueViolation(err) {
+			return ``, ErrUserExists
+		}
 		return ``, err
 	}
 	userID, lastIdErr := result.LastInsertId()
@@ -63,9 +73,11 @@ func (r *UserRepo) UserExists(uname string) bool {
 }
 
 func (r *UserRepo) GetById(ctx context.Context, uid string) (*User, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT id, username FROM users where id=$1", uid)
+	row := r.db.QueryRowContext(ctx,
+		"SELECT id, username, is_admin, created, banned, shadowbanned, suspended_until FROM users where id=$1", uid)
 	u := new(User)
-	if err := row.Scan(&u.Id, &u.Username); err != nil {
+	err := row.Scan(&u.Id, &u.Username, &u.Admin, &u.Created, &u.Banned, &u.Shadowbanned, &u.SuspendedUntil)
+	if err != nil {
 		return u, fmt.Errorf("user/repo: could not scan row: %w", err)
 	}
 	return u, nil
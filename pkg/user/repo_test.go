@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
 	"github.com/stretchr/testify/assert"
 
 	. "crud/pkg/common"
@@ -29,13 +31,13 @@ func TestGetById(t *testing.T) {
 	r := NewUserRepo(db)
 
 	t.Run("should return user", func(t *testing.T) {
-		expect := &User{Id: userID, Username: username}
+		expect := &User{Id: userID, Username: username, Created: time.Now()}
 
-		rows := sqlmock.NewRows([]string{"id", "username"})
-		rows.AddRow(expect.Id, expect.Username)
+		rows := sqlmock.NewRows([]string{"id", "username", "is_admin", "created", "banned", "shadowbanned", "suspended_until"})
+		rows.AddRow(expect.Id, expect.Username, expect.Admin, expect.Created, expect.Banned, expect.Shadowbanned, nil)
 
 		mock.
-			ExpectQuery("SELECT id, username FROM users where").
+			ExpectQuery("SELECT id, username, is_admin, created, banned, shadowbanned, suspended_until FROM users where").
 			WithArgs(userID).
 			WillReturnRows(rows)
 
@@ -54,7 +56,7 @@ func TestGetById(t *testing.T) {
 	t.Run("should return DB error", func(t *testing.T) {
 		expectedErr := fmt.Errorf("mock_db_error")
 		mock.
-			ExpectQuery("SELECT id, username FROM users where").
+			ExpectQuery("SELECT id, username, is_admin, created, banned, shadowbanned, suspended_until FROM users where").
 			WithArgs(userID).
 			WillReturnError(expectedErr)
 		_, err = r.GetById(context.TODO(), userID)
@@ -107,6 +109,19 @@ func TestRepoAdd(t *testing.T) {
 		}
 	})
 
+	t.Run("should return ErrUserExists on unique violation", func(t *testing.T) {
+		mock.
+			ExpectExec("INSERT INTO users").
+			WithArgs(username, hashedPass).
+			WillReturnError(&pgconn.PgError{Code: PgUniqueViolation})
+		_, err = repo.Add(testUser)
+		assert.ErrorIs(t, err, ErrUserExists)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+
 	t.Run("should return result error", func(t *testing.T) {
 		expectedErr := fmt.Errorf("bad_result")
 		mock.
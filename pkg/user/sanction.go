@@ -0,0 +1,148 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SanctionKind string
+
+const (
+	SanctionSuspend   SanctionKind = "suspend"
+	SanctionBan       SanctionKind = "ban"
+	SanctionShadowban SanctionKind = "shadowban"
+	// Lifts all the active sanctions.
+	SanctionLift SanctionKind = "lift"
+)
+
+var ErrBadSanction = errors.New("user/sanction: invalid sanction")
+
+// Sanction is a record of the moderation action applied to the user.
+type Sanction struct {
+	Id      int64        `json:"id"`
+	UserId  string       `json:"userId"`
+	ActorId string       `json:"actorId"`
+	Kind    SanctionKind `json:"kind"`
+	Reason  string       `json:"reason"`
+	Until   *time.Time   `json:"until,omitempty"`
+	Created time.Time    `json:"created"`
+}
+
+func (s *Sanction) Validate(now time.Time) error {
+	if s.Reason == "" {
+		return fmt.Errorf("%w: reason is required", ErrBadSanction)
+	}
+	switch s.Kind {
+	case SanctionSuspend:
+		if s.Until == nil || !s.Until.After(now) {
+			return fmt.Errorf("%w: suspension must end in the future", ErrBadSanction)
+		}
+	case SanctionBan, SanctionShadowban, SanctionLift:
+		s.Until = nil
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrBadSanction, s.Kind)
+	}
+	return nil
+}
+
+type SanctionRepo struct {
+	db *sql.DB
+}
+
+func NewSanctionRepo(db *sql.DB) *SanctionRepo {
+	return &SanctionRepo{
+		db: db,
+	}
+}
+
+// Applies the sanction to the user and records it in one transaction.
+func (r *SanctionRepo) Apply(ctx context.Context, s *Sanction) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("user/sanction: failed starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var update string
+	var args []interface{}
+	switch s.Kind {
+	case SanctionSuspend:
+		update, args = "UPDATE users SET suspended_until=$2 WHERE id=$1", []interface{}{s.UserId, s.Until}
+	case SanctionBan:
+		update, args = "UPDATE users SET banned=TRUE WHERE id=$1", []interface{}{s.UserId}
+	case SanctionShadowban:
+		update, args = "UPDATE users SET shadowbanned=TRUE WHERE id=$1", []interface{}{s.UserId}
+	case SanctionLift:
+		update = "UPDATE users SET banned=FALSE, shadowbanned=FALSE, suspended_until=NULL WHERE id=$1"
+		args = []interface{}{s.UserId}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrBadSanction, s.Kind)
+	}
+
+	res, err := tx.ExecContext(ctx, update, args...)
+	if err != nil {
+		return fmt.Errorf("user/sanction: failed updating user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("user/sanction: failed updating user: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, s.UserId)
+	}
+
+	row := tx.QueryRowContext(ctx,
+		"INSERT INTO user_sanctions(user_id, actor_id, kind, reason, until, created) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+		s.UserId, s.ActorId, s.Kind, s.Reason, s.Until, s.Created)
+	if err := row.Scan(&s.Id); err != nil {
+		return fmt.Errorf("user/sanction: failed recording sanction: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("user/sanction: failed commiting sanction: %w", err)
+	}
+	return nil
+}
+
+// Returns the sanctions history of the user, newest first.
+func (r *SanctionRepo) GetUserSanctions(ctx context.Context, userId string) ([]*Sanction, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, user_id, actor_id, kind, reason, until, created FROM user_sanctions WHERE user_id=$1 ORDER BY created DESC",
+		userId)
+	if err != nil {
+		return nil, fmt.Errorf("user/sanction: failed querying sanctions: %w", err)
+	}
+	defer rows.Close()
+
+	sanctions := []*Sanction{}
+	for rows.Next() {
+		s := new(Sanction)
+		if err := rows.Scan(&s.Id, &s.UserId, &s.ActorId, &s.Kind, &s.Reason, &s.Until, &s.Created); err != nil {
+			return nil, fmt.Errorf("user/sanction: could not scan row: %w", err)
+		}
+		sanctions = append(sanctions, s)
+	}
+	return sanctions, nil
+}
+
+// Returns Ids of all shadowbanned users. Their content is visible only to themselves.
+func (r *SanctionRepo) ShadowbannedIds(ctx context.Context) (map[string]struct{}, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM users WHERE shadowbanned")
+	if err != nil {
+		return nil, fmt.Errorf("user/sanction: failed querying shadowbanned users: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[string]struct{}{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("user/sanction: could not scan row: %w", err)
+		}
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+}
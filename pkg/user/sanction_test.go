@@ -0,0 +1,79 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanctionApply(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+	r := NewSanctionRepo(db)
+	now := time.Now()
+
+	t.Run("should ban user and record the sanction", func(t *testing.T) {
+		s := &Sanction{UserId: "2", ActorId: userID, Kind: SanctionBan, Reason: "spam", Created: now}
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("UPDATE users SET banned=TRUE").
+			WithArgs(s.UserId).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.
+			ExpectQuery("INSERT INTO user_sanctions").
+			WithArgs(s.UserId, s.ActorId, s.Kind, s.Reason, s.Until, s.Created).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectCommit()
+
+		err := r.Apply(context.TODO(), s)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), s.Id)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+
+	t.Run("should rollback when user not found", func(t *testing.T) {
+		s := &Sanction{UserId: "404", ActorId: userID, Kind: SanctionShadowban, Reason: "spam", Created: now}
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("UPDATE users SET shadowbanned=TRUE").
+			WithArgs(s.UserId).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := r.Apply(context.TODO(), s)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations unfulfilled: %s", err)
+			return
+		}
+	})
+
+	t.Run("should return DB error", func(t *testing.T) {
+		expectedErr := fmt.Errorf("mock_db_error")
+		s := &Sanction{UserId: "2", ActorId: userID, Kind: SanctionLift, Reason: "appeal", Created: now}
+		mock.ExpectBegin().WillReturnError(expectedErr)
+
+		err := r.Apply(context.TODO(), s)
+		assert.ErrorIs(t, err, expectedErr)
+	})
+}
+
+func TestSanctionValidate(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	assert.Nil(t, (&Sanction{Kind: SanctionSuspend, Reason: "rude", Until: &future}).Validate(now))
+	assert.ErrorIs(t, (&Sanction{Kind: SanctionSuspend, Reason: "rude", Until: &past}).Validate(now), ErrBadSanction)
+	assert.ErrorIs(t, (&Sanction{Kind: SanctionBan}).Validate(now), ErrBadSanction)
+	assert.ErrorIs(t, (&Sanction{Kind: "mute", Reason: "rude"}).Validate(now), ErrBadSanction)
+}
@@ -0,0 +1,53 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SubscriptionRepo stores the categories the users are subscribed to.
+type SubscriptionRepo struct {
+	db *sql.DB
+}
+
+func NewSubscriptionRepo(db *sql.DB) *SubscriptionRepo {
+	return &SubscriptionRepo{
+		db: db,
+	}
+}
+
+func (r *SubscriptionRepo) Subscribe(ctx context.Context, userId, category string) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO subscriptions(user_id, category) VALUES($1, $2) ON CONFLICT DO NOTHING", userId, category)
+	if err != nil {
+		return fmt.Errorf("user/subscription: failed subscribing: %w", err)
+	}
+	return nil
+}
+
+func (r *SubscriptionRepo) Unsubscribe(ctx context.Context, userId, category string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM subscriptions WHERE user_id=$1 AND category=$2", userId, category)
+	if err != nil {
+		return fmt.Errorf("user/subscription: failed unsubscribing: %w", err)
+	}
+	return nil
+}
+
+func (r *SubscriptionRepo) GetSubscriptions(ctx context.Context, userId string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT category FROM subscriptions WHERE user_id=$1 ORDER BY category", userId)
+	if err != nil {
+		return nil, fmt.Errorf("user/subscription: failed querying subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []string{}
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, fmt.Errorf("user/subscription: could not scan row: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
@@ -1,12 +1,42 @@
 package user
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrBanned    = errors.New("user is banned")
+	ErrSuspended = errors.New("user is suspended")
+)
+
 type User struct {
 	Username string `json:"username"`
 	Password []byte `json:"-"`
 	Id       string `json:"id"`
+	Admin    bool   `json:"admin,omitempty"`
+	// Registration time.
+	Created time.Time `json:"-"`
+
+	// Moderation state, see Sanction.
+	Banned         bool       `json:"-"`
+	Shadowbanned   bool       `json:"-"`
+	SuspendedUntil *time.Time `json:"-"`
 }
 
 type UserFromToken struct {
 	Username string `json:"username"`
 	Id       string `json:"id"`
 }
+
+// Returns an error if the user is not allowed to create content, vote etc.
+func (u *User) CanWrite(now time.Time) error {
+	if u.Banned {
+		return ErrBanned
+	}
+	if u.SuspendedUntil != nil && now.Before(*u.SuspendedUntil) {
+		return fmt.Errorf("%w until %s", ErrSuspended, u.SuspendedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -1,5 +1,178 @@
 CREATE TABLE IF NOT EXISTS users(
   id SERIAL PRIMARY KEY,
   username VARCHAR(128) NOT NULL UNIQUE,
-  password BYTEA NOT NULL
+  password BYTEA NOT NULL,
+  is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  banned BOOLEAN NOT NULL DEFAULT FALSE,
+  shadowbanned BOOLEAN NOT NULL DEFAULT FALSE,
+  suspended_until TIMESTAMP
+);
+
+-- The columns added after the table was created, for the existing databases.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS shadowbanned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS invites(
+  code VARCHAR(32) PRIMARY KEY,
+  created_by INTEGER NOT NULL REFERENCES users(id),
+  used_by VARCHAR(128),
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  used TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_sanctions(
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  actor_id INTEGER NOT NULL REFERENCES users(id),
+  kind VARCHAR(16) NOT NULL,
+  reason TEXT NOT NULL,
+  until TIMESTAMP,
+  created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_blocks(
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  target_id INTEGER NOT NULL REFERENCES users(id),
+  kind VARCHAR(8) NOT NULL,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, target_id)
+);
+
+CREATE TABLE IF NOT EXISTS follows(
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  target_id INTEGER NOT NULL REFERENCES users(id),
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, target_id)
+);
+
+CREATE TABLE IF NOT EXISTS categories(
+  slug VARCHAR(32) PRIMARY KEY,
+  title VARCHAR(128) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  rules JSONB NOT NULL DEFAULT '[]',
+  creator_id INTEGER NOT NULL REFERENCES users(id),
+  created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS category_moderators(
+  slug VARCHAR(32) NOT NULL REFERENCES categories(slug),
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  PRIMARY KEY (slug, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions(
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  category VARCHAR(32) NOT NULL REFERENCES categories(slug),
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, category)
+);
+
+CREATE TABLE IF NOT EXISTS category_bans(
+  category VARCHAR(32) NOT NULL REFERENCES categories(slug),
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  actor_id INTEGER NOT NULL REFERENCES users(id),
+  reason TEXT NOT NULL,
+  until TIMESTAMP,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (category, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS modlog(
+  id SERIAL PRIMARY KEY,
+  category VARCHAR(32) NOT NULL REFERENCES categories(slug),
+  actor_id INTEGER NOT NULL REFERENCES users(id),
+  action VARCHAR(32) NOT NULL,
+  target_type VARCHAR(16) NOT NULL,
+  target_id VARCHAR(64) NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS modlog_category_created ON modlog(category, created DESC);
+
+CREATE TABLE IF NOT EXISTS reports(
+  id SERIAL PRIMARY KEY,
+  category VARCHAR(32) NOT NULL REFERENCES categories(slug),
+  target_type VARCHAR(16) NOT NULL,
+  target_id VARCHAR(64) NOT NULL,
+  post_id VARCHAR(64) NOT NULL,
+  -- NULL for reports filed by AutoModerator
+  reporter_id INTEGER REFERENCES users(id),
+  reason VARCHAR(32) NOT NULL,
+  text TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  resolution VARCHAR(16),
+  resolver_id INTEGER REFERENCES users(id),
+  resolved TIMESTAMP,
+  UNIQUE (target_type, target_id, reporter_id)
+);
+CREATE INDEX IF NOT EXISTS reports_open ON reports(category) WHERE resolution IS NULL;
+
+CREATE TABLE IF NOT EXISTS automod_rules(
+  category VARCHAR(32) PRIMARY KEY REFERENCES categories(slug),
+  rules JSONB NOT NULL,
+  updated_by INTEGER NOT NULL REFERENCES users(id),
+  updated TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS automod_log(
+  id SERIAL PRIMARY KEY,
+  category VARCHAR(32) NOT NULL REFERENCES categories(slug),
+  rule VARCHAR(128) NOT NULL,
+  action VARCHAR(32) NOT NULL,
+  target_type VARCHAR(16) NOT NULL,
+  target_id VARCHAR(64) NOT NULL,
+  author_id INTEGER NOT NULL REFERENCES users(id),
+  created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Naive Bayes spam model, see pkg/spam.
+CREATE TABLE IF NOT EXISTS spam_model(
+  id SMALLINT PRIMARY KEY CHECK (id = 1),
+  spam_docs INTEGER NOT NULL DEFAULT 0,
+  ham_docs INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS spam_tokens(
+  token VARCHAR(300) PRIMARY KEY,
+  spam INTEGER NOT NULL DEFAULT 0,
+  ham INTEGER NOT NULL DEFAULT 0
+);
+
+-- Posts saved or hidden by the users, post_id is the MongoDB post id.
+CREATE TABLE IF NOT EXISTS post_marks(
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  post_id VARCHAR(64) NOT NULL,
+  kind VARCHAR(16) NOT NULL,
+  created TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, post_id, kind)
+);
+CREATE INDEX IF NOT EXISTS post_marks_user_kind ON post_marks(user_id, kind, created DESC);
+
+CREATE TABLE IF NOT EXISTS uploads(
+  id VARCHAR(32) PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id),
+  key VARCHAR(512) NOT NULL,
+  thumb_key VARCHAR(512) NOT NULL,
+  content_type VARCHAR(64) NOT NULL,
+  size BIGINT NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_prefs(
+  user_id INTEGER PRIMARY KEY REFERENCES users(id),
+  nsfw VARCHAR(8) NOT NULL DEFAULT 'hide',
+  spoiler VARCHAR(8) NOT NULL DEFAULT 'blur'
+);
+
+CREATE TABLE IF NOT EXISTS category_flair(
+  category VARCHAR(32) PRIMARY KEY REFERENCES categories(slug),
+  flair JSONB NOT NULL,
+  updated_by INTEGER NOT NULL REFERENCES users(id),
+  updated TIMESTAMP NOT NULL DEFAULT NOW()
 );